@@ -0,0 +1,668 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/AlexNabokikh/tfsort/internal/config"
+	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+	"github.com/AlexNabokikh/tfsort/internal/ignore"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/spf13/cobra"
+)
+
+// configResolver resolves the configuration, Ingestor and ignore rules applying to each processed path.
+// Without an explicit configuration file every directory uses the files merged from its parents.
+type configResolver struct {
+	// ctx bounds the comparisons of WebAssembly sorting rules.
+	ctx context.Context
+	// wasmRules holds the WebAssembly sorting rules loaded for ingestors, which are released by Close.
+	wasmRules  []*hclsort.WasmComparator
+	loader     *config.Loader
+	explicit   *config.Config
+	ignores    *ignore.Matcher
+	registered map[*config.Config]bool
+	ingestors  map[*config.Config]*hclsort.Ingestor
+	// includeGenerated disables the detection of generated files.
+	includeGenerated bool
+	// allowPlugins runs the plugins declared by configuration files, which are ignored otherwise.
+	allowPlugins bool
+	// sortBlocks overrides the sort_blocks setting of every configuration when not nil.
+	sortBlocks []string
+	// excludedBlocks lists block types that are never sorted.
+	excludedBlocks map[string]bool
+	// sections overrides the sections setting of every configuration when not nil.
+	sections *bool
+	// groupByBlankLines overrides the group_by_blank_lines setting of every configuration when not nil.
+	groupByBlankLines *bool
+	// sectionHeaders overrides the section_headers setting of every configuration when not nil.
+	sectionHeaders *bool
+	// sortNestedBlocks overrides the sort_nested_blocks setting of every configuration when not nil.
+	sortNestedBlocks *bool
+	// sortDependsOn overrides the sort_depends_on setting of every configuration when not nil.
+	sortDependsOn *bool
+	// sortMapKeys overrides the sort_map_keys setting of every configuration when not nil.
+	sortMapKeys *bool
+	// sortObjectTypes overrides the sort_object_types setting of every configuration when not nil.
+	sortObjectTypes *bool
+	// sortVariableDefaults overrides the sort_variable_defaults setting of every configuration when not nil.
+	sortVariableDefaults *bool
+	// localsDepth overrides the locals_depth setting of every configuration when not nil.
+	localsDepth *int
+	// localsOrder overrides the locals_order setting of every configuration when not nil.
+	localsOrder *string
+	// groupResources overrides the group_resources setting of every configuration when not nil.
+	groupResources *string
+	// blankLines overrides the blank_lines setting of every configuration when not nil.
+	blankLines *int
+	// typeBlankLines overrides the type_blank_lines setting of every configuration when not nil.
+	typeBlankLines *int
+	// providerSource overrides the provider_source setting of every configuration when not nil.
+	providerSource *string
+	// dialect overrides the dialect setting of every configuration when not nil.
+	dialect *string
+	// overrideFiles overrides the override_files setting of every configuration when not nil.
+	overrideFiles *string
+	// alignEquals overrides the align_equals setting of every configuration when not nil.
+	alignEquals *string
+	// minimalMoves overrides the minimal_moves setting of every configuration when not nil.
+	minimalMoves *bool
+	// preserveFormatting overrides the preserve_formatting setting of every configuration when not nil.
+	preserveFormatting *bool
+	// sortTfvarsValues overrides the sort_tfvars_values setting of every configuration when not nil.
+	sortTfvarsValues *bool
+	// normalizeVersions overrides the normalize_versions setting of every configuration when not nil.
+	normalizeVersions *bool
+	// blockOrder overrides the block_order setting of every configuration when not nil.
+	blockOrder []string
+	// mergeTerraform overrides the merge_terraform_blocks setting of every configuration when not nil.
+	mergeTerraform *bool
+	// flagSort holds the sort settings passed on the command line, which override every configuration.
+	flagSort config.SortRule
+}
+
+// newConfigResolver loads the configuration applying to the working directory and applies its
+// settings to opts. Flags that were set explicitly on the command line take precedence.
+func newConfigResolver(cmd *cobra.Command, opts *runOptions) (*configResolver, error) {
+	resolver := &configResolver{
+		ctx:              cmd.Context(),
+		loader:           config.NewLoader(),
+		registered:       map[*config.Config]bool{},
+		ingestors:        map[*config.Config]*hclsort.Ingestor{},
+		includeGenerated: opts.includeGenerated,
+		allowPlugins:     opts.allowPlugins,
+	}
+
+	var (
+		cfg *config.Config
+		err error
+	)
+	if opts.configPath != "" {
+		cfg, err = config.Load(opts.configPath)
+		resolver.explicit = cfg
+	} else {
+		wd, wdErr := os.Getwd()
+		if wdErr != nil {
+			return nil, fmt.Errorf("failed to get working directory: %w", wdErr)
+		}
+		cfg, err = resolver.loader.ForDir(wd)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Flags().Changed("types") {
+		if resolver.sortBlocks, err = blockTypes("types", opts.types); err != nil {
+			return nil, err
+		}
+	}
+	if err = resolver.setLayoutOverrides(cmd, opts); err != nil {
+		return nil, err
+	}
+	if cmd.Flags().Changed("sort-strategy") {
+		resolver.flagSort.Strategy = &opts.sortStrategy
+	}
+	if cmd.Flags().Changed("ignore-case") {
+		resolver.flagSort.IgnoreCase = &opts.ignoreCase
+	}
+	if cmd.Flags().Changed("collation") {
+		resolver.flagSort.Collation = &opts.collation
+	}
+	if _, err = defaultSortSettings().with(resolver.flagSort).comparator(resolver.wasmComparator); err != nil {
+		return nil, err
+	}
+	excluded, err := blockTypes("exclude-types", opts.excludeTypes)
+	if err != nil {
+		return nil, err
+	}
+	if len(excluded) > 0 {
+		resolver.excludedBlocks = make(map[string]bool, len(excluded))
+		for _, blockType := range excluded {
+			resolver.excludedBlocks[blockType] = true
+		}
+	}
+
+	applyBool(cmd, "write", &opts.write, cfg.Write)
+	applyBool(cmd, "diff", &opts.diff, cfg.Diff)
+	applyBool(cmd, "list", &opts.list, cfg.List)
+	applyBool(cmd, "recursive", &opts.recursive, cfg.Recursive)
+	if cfg.Gitignore != nil && !cmd.Flags().Changed("no-gitignore") {
+		opts.noGitignore = !*cfg.Gitignore
+	}
+
+	ignoreFiles := []string{ignore.GitIgnoreFile, ignore.TfsortIgnoreFile}
+	if opts.noGitignore {
+		ignoreFiles = []string{ignore.TfsortIgnoreFile}
+	}
+	resolver.ignores = ignore.NewMatcher(ignoreFiles...)
+
+	return resolver, nil
+}
+
+// setLayoutOverrides records the layout settings passed on the command line, which override every
+// configuration.
+func (r *configResolver) setLayoutOverrides(cmd *cobra.Command, opts *runOptions) error {
+	r.sections = changed(cmd, "sections", &opts.sections)
+	r.groupByBlankLines = changed(cmd, "group-by-blank-lines", &opts.groupByBlankLines)
+	r.sectionHeaders = changed(cmd, "section-headers", &opts.sectionHeaders)
+	r.sortNestedBlocks = changed(cmd, "sort-nested-blocks", &opts.sortNestedBlocks)
+	r.sortDependsOn = changed(cmd, "sort-depends-on", &opts.sortDependsOn)
+	r.sortMapKeys = changed(cmd, "sort-map-keys", &opts.sortMapKeys)
+	r.sortObjectTypes = changed(cmd, "sort-object-types", &opts.sortObjectTypes)
+	r.sortVariableDefaults = changed(cmd, "sort-variable-defaults", &opts.sortVariableDefaults)
+	r.mergeTerraform = changed(cmd, "merge-terraform-blocks", &opts.mergeTerraform)
+	r.minimalMoves = changed(cmd, "minimal-moves", &opts.minimalMoves)
+	r.preserveFormatting = changed(cmd, "preserve-formatting", &opts.preserveFormatting)
+	r.sortTfvarsValues = changed(cmd, "sort-tfvars-values", &opts.sortTfvarsValues)
+	r.normalizeVersions = changed(cmd, "normalize-versions", &opts.normalizeVersions)
+	if cmd.Flags().Changed("locals-depth") {
+		if opts.localsDepth < 0 {
+			return fmt.Errorf("--locals-depth must not be negative, got %d", opts.localsDepth)
+		}
+		r.localsDepth = &opts.localsDepth
+	}
+	if cmd.Flags().Changed("blank-lines") {
+		if opts.blankLines < 1 {
+			return fmt.Errorf("--blank-lines must be at least 1, got %d", opts.blankLines)
+		}
+		r.blankLines = &opts.blankLines
+	}
+	if cmd.Flags().Changed("type-blank-lines") {
+		if opts.typeBlankLines < 1 {
+			return fmt.Errorf("--type-blank-lines must be at least 1, got %d", opts.typeBlankLines)
+		}
+		r.typeBlankLines = &opts.typeBlankLines
+	}
+	if cmd.Flags().Changed("locals-order") {
+		if err := hclsort.ValidateLocalsOrder(opts.localsOrder); err != nil {
+			return fmt.Errorf("invalid --locals-order: %w", err)
+		}
+		r.localsOrder = &opts.localsOrder
+	}
+	if cmd.Flags().Changed("group-resources") {
+		if err := hclsort.ValidateResourceGrouping(opts.groupResources); err != nil {
+			return fmt.Errorf("invalid --group-resources: %w", err)
+		}
+		r.groupResources = &opts.groupResources
+	}
+	if cmd.Flags().Changed("provider-source") {
+		if err := hclsort.ValidateProviderSource(opts.providerSource); err != nil {
+			return fmt.Errorf("invalid --provider-source: %w", err)
+		}
+		r.providerSource = &opts.providerSource
+	}
+	if cmd.Flags().Changed("dialect") {
+		if err := hclsort.ValidateDialect(opts.dialect); err != nil {
+			return fmt.Errorf("invalid --dialect: %w", err)
+		}
+		r.dialect = &opts.dialect
+	}
+	if cmd.Flags().Changed("override-files") {
+		if err := hclsort.ValidateOverrideFiles(opts.overrideFiles); err != nil {
+			return fmt.Errorf("invalid --override-files: %w", err)
+		}
+		r.overrideFiles = &opts.overrideFiles
+	}
+	if cmd.Flags().Changed("align-equals") {
+		if err := hclsort.ValidateAlignment(opts.alignEquals); err != nil {
+			return fmt.Errorf("invalid --align-equals: %w", err)
+		}
+		r.alignEquals = &opts.alignEquals
+	}
+	if cmd.Flags().Changed("block-order") {
+		order, err := blockTypes("block-order", opts.blockOrder)
+		if err != nil {
+			return err
+		}
+		r.blockOrder = order
+	}
+	return nil
+}
+
+// blockTypes trims the block types passed to the named flag and rejects empty ones.
+func blockTypes(flag string, values []string) ([]string, error) {
+	types := make([]string, 0, len(values))
+	for _, blockType := range values {
+		if blockType = strings.TrimSpace(blockType); blockType == "" {
+			return nil, fmt.Errorf("--%s must not contain empty block types", flag)
+		}
+		types = append(types, blockType)
+	}
+	return types, nil
+}
+
+// changed returns value when the named flag was set explicitly, and nil otherwise.
+func changed[T any](cmd *cobra.Command, flag string, value *T) *T {
+	if !cmd.Flags().Changed(flag) {
+		return nil
+	}
+	return value
+}
+
+// applyBool sets target from a configuration value unless the named flag was set explicitly.
+func applyBool(cmd *cobra.Command, flag string, target *bool, value *bool) {
+	if value != nil && !cmd.Flags().Changed(flag) {
+		*target = *value
+	}
+}
+
+// enabled reports whether a setting is on. The override passed on the command line takes precedence
+// over the configured value, and unset settings are off.
+func enabled(override, value *bool) bool {
+	if override != nil {
+		return *override
+	}
+	return value != nil && *value
+}
+
+// configFor returns the configuration applying to files in dir.
+// The ignore patterns of every configuration file involved are registered with the ignore matcher.
+func (r *configResolver) configFor(dir string) (*config.Config, error) {
+	cfg := r.explicit
+	sources := []*config.Config{r.explicit}
+	if cfg == nil {
+		var err error
+		if cfg, err = r.loader.ForDir(dir); err != nil {
+			return nil, err
+		}
+		sources = cfg.Sources
+	}
+
+	for _, source := range sources {
+		if r.registered[source] {
+			continue
+		}
+		r.registered[source] = true
+		if len(source.Ignore) == 0 {
+			continue
+		}
+		if err := r.ignores.AddPatterns(source.Dir, source.Ignore); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// ingestorFor returns an Ingestor configured for the file at path.
+func (r *configResolver) ingestorFor(path string) (*hclsort.Ingestor, error) {
+	dir := filepath.Dir(path)
+	if path == hclsort.StdInPathIdentifier {
+		dir = "."
+	}
+	return r.ingestorForDir(dir)
+}
+
+// ingestorForDir returns an Ingestor configured for the files in dir.
+func (r *configResolver) ingestorForDir(dir string) (*hclsort.Ingestor, error) {
+	cfg, err := r.configFor(dir)
+	if err != nil {
+		return nil, err
+	}
+	if ingestor, ok := r.ingestors[cfg]; ok {
+		return ingestor, nil
+	}
+
+	sortBlocks := cfg.SortBlocks
+	if r.sortBlocks != nil {
+		sortBlocks = r.sortBlocks
+	}
+
+	ingestor := hclsort.NewIngestor()
+	if sortBlocks != nil {
+		ingestor.AllowedBlocks = make(map[string]bool, len(sortBlocks))
+		for _, blockType := range sortBlocks {
+			ingestor.AllowedBlocks[blockType] = true
+		}
+	}
+	ingestor.ExcludedBlocks = r.excludedBlocks
+
+	if err = r.applySortSettings(ingestor, cfg); err != nil {
+		return nil, err
+	}
+	switch {
+	case r.includeGenerated || (cfg.GeneratedPattern != nil && *cfg.GeneratedPattern == ""):
+		ingestor.GeneratedPattern = nil
+	case cfg.GeneratedPattern != nil:
+		// The pattern was validated when the configuration file was loaded.
+		ingestor.GeneratedPattern = regexp.MustCompile(*cfg.GeneratedPattern)
+	}
+
+	if err = r.applyLayoutSettings(ingestor, cfg); err != nil {
+		return nil, err
+	}
+	if err = r.applySectionHeaders(ingestor, cfg, sortBlocks); err != nil {
+		return nil, err
+	}
+
+	r.ingestors[cfg] = ingestor
+	return ingestor, nil
+}
+
+// applySortSettings sets the comparators and sort key expressions of ingestor from the sort settings
+// of cfg and the command line.
+func (r *configResolver) applySortSettings(ingestor *hclsort.Ingestor, cfg *config.Config) error {
+	base := defaultSortSettings().with(config.SortRule{
+		Strategy:   cfg.SortStrategy,
+		Collation:  cfg.Collation,
+		IgnoreCase: cfg.IgnoreCase,
+	})
+	base = base.with(r.flagSort)
+	var err error
+	if ingestor.Compare, err = base.comparator(r.wasmComparator); err != nil {
+		return fmt.Errorf("invalid sort settings in config file '%s': %w", cfg.Path, err)
+	}
+	for _, rule := range cfg.Sort {
+		if ingestor.BlockCompare == nil {
+			ingestor.BlockCompare = map[string]hclsort.Comparator{}
+		}
+		if rule.Key != nil {
+			if ingestor.KeyExprs == nil {
+				ingestor.KeyExprs = map[string]hcl.Expression{}
+			}
+			ingestor.KeyExprs[rule.BlockType] = rule.Key
+		} else {
+			// Later rules replace earlier ones for the same block type as a whole.
+			delete(ingestor.KeyExprs, rule.BlockType)
+		}
+		settings := base.with(*rule).with(r.flagSort)
+		if ingestor.BlockCompare[rule.BlockType], err = settings.comparator(r.wasmComparator); err != nil {
+			return fmt.Errorf(
+				"invalid sort rule for '%s' blocks in config file '%s': %w",
+				rule.BlockType,
+				cfg.Path,
+				err,
+			)
+		}
+	}
+	return nil
+}
+
+// wasmComparator loads the WebAssembly sorting rule at path, which is released when r is closed.
+func (r *configResolver) wasmComparator(path string) (hclsort.Comparator, error) {
+	rule, err := hclsort.NewWasmComparator(r.ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	r.wasmRules = append(r.wasmRules, rule)
+	return rule.Compare, nil
+}
+
+// Close releases the WebAssembly sorting rules loaded by r. The ingestors of r compare names by their
+// bytes afterwards, so resolvers are closed once they are replaced or no longer used.
+func (r *configResolver) Close() error {
+	errs := make([]error, 0, len(r.wasmRules))
+	for _, rule := range r.wasmRules {
+		errs = append(errs, rule.Close())
+	}
+	r.wasmRules = nil
+	return errors.Join(errs...)
+}
+
+// applyLayoutSettings sets the options of ingestor that control sections and the contents of block
+// bodies from cfg and the command line.
+func (r *configResolver) applyLayoutSettings(ingestor *hclsort.Ingestor, cfg *config.Config) error {
+	if enabled(r.sections, cfg.Sections) {
+		pattern := hclsort.DefaultSectionPattern
+		if cfg.SectionPattern != nil {
+			pattern = *cfg.SectionPattern
+		}
+		// Configured patterns were validated when the configuration file was loaded.
+		ingestor.SectionPattern = regexp.MustCompile(pattern)
+	}
+	ingestor.GroupByBlankLines = enabled(r.groupByBlankLines, cfg.GroupByBlankLines)
+	ingestor.SortNestedBlocks = enabled(r.sortNestedBlocks, cfg.SortNestedBlocks)
+	ingestor.SortDependsOn = enabled(r.sortDependsOn, cfg.SortDependsOn)
+	ingestor.SortWorkspaceTags = enabled(nil, cfg.SortWorkspaceTags)
+	ingestor.SortObjectTypes = enabled(r.sortObjectTypes, cfg.SortObjectTypes)
+	ingestor.SortVariableDefaults = enabled(r.sortVariableDefaults, cfg.SortVariableDefaults)
+	ingestor.MergeTerraformBlocks = enabled(r.mergeTerraform, cfg.MergeTerraformBlocks)
+	ingestor.MinimalMoves = enabled(r.minimalMoves, cfg.MinimalMoves)
+	ingestor.PreserveFormatting = enabled(r.preserveFormatting, cfg.PreserveFormatting)
+	ingestor.SortTfvarsValues = enabled(r.sortTfvarsValues, cfg.SortTfvarsValues)
+	ingestor.NormalizeVersions = enabled(r.normalizeVersions, cfg.NormalizeVersions)
+	switch {
+	case r.localsDepth != nil:
+		ingestor.LocalsDepth = *r.localsDepth
+	case cfg.LocalsDepth != nil:
+		ingestor.LocalsDepth = *cfg.LocalsDepth
+	}
+	switch {
+	case r.blankLines != nil:
+		ingestor.BlankLines = *r.blankLines
+	case cfg.BlankLines != nil:
+		ingestor.BlankLines = *cfg.BlankLines
+	}
+	switch {
+	case r.typeBlankLines != nil:
+		ingestor.TypeBlankLines = *r.typeBlankLines
+	case cfg.TypeBlankLines != nil:
+		ingestor.TypeBlankLines = *cfg.TypeBlankLines
+	}
+	switch {
+	case r.localsOrder != nil:
+		ingestor.LocalsOrder = *r.localsOrder
+	case cfg.LocalsOrder != nil:
+		if err := hclsort.ValidateLocalsOrder(*cfg.LocalsOrder); err != nil {
+			return fmt.Errorf("invalid locals_order in config file '%s': %w", cfg.Path, err)
+		}
+		ingestor.LocalsOrder = *cfg.LocalsOrder
+	}
+	switch {
+	case r.groupResources != nil:
+		ingestor.ResourceGrouping = *r.groupResources
+	case cfg.GroupResources != nil:
+		if err := hclsort.ValidateResourceGrouping(*cfg.GroupResources); err != nil {
+			return fmt.Errorf("invalid group_resources in config file '%s': %w", cfg.Path, err)
+		}
+		ingestor.ResourceGrouping = *cfg.GroupResources
+	}
+	switch {
+	case r.providerSource != nil:
+		ingestor.ProviderSource = *r.providerSource
+	case cfg.ProviderSource != nil:
+		if err := hclsort.ValidateProviderSource(*cfg.ProviderSource); err != nil {
+			return fmt.Errorf("invalid provider_source in config file '%s': %w", cfg.Path, err)
+		}
+		ingestor.ProviderSource = *cfg.ProviderSource
+	}
+	switch {
+	case r.dialect != nil:
+		ingestor.Dialect = *r.dialect
+	case cfg.Dialect != nil:
+		if err := hclsort.ValidateDialect(*cfg.Dialect); err != nil {
+			return fmt.Errorf("invalid dialect in config file '%s': %w", cfg.Path, err)
+		}
+		ingestor.Dialect = *cfg.Dialect
+	}
+	if ingestor.Dialect == hclsort.DialectNomad {
+		ingestor.AllowedTypes["nomad"] = true
+	}
+	switch {
+	case r.overrideFiles != nil:
+		ingestor.OverrideFiles = *r.overrideFiles
+	case cfg.OverrideFiles != nil:
+		if err := hclsort.ValidateOverrideFiles(*cfg.OverrideFiles); err != nil {
+			return fmt.Errorf("invalid override_files in config file '%s': %w", cfg.Path, err)
+		}
+		ingestor.OverrideFiles = *cfg.OverrideFiles
+	}
+	switch {
+	case r.alignEquals != nil:
+		ingestor.Alignment = *r.alignEquals
+	case cfg.AlignEquals != nil:
+		if err := hclsort.ValidateAlignment(*cfg.AlignEquals); err != nil {
+			return fmt.Errorf("invalid align_equals in config file '%s': %w", cfg.Path, err)
+		}
+		ingestor.Alignment = *cfg.AlignEquals
+	}
+	ingestor.BlockOrder = cfg.BlockOrder
+	if r.blockOrder != nil {
+		ingestor.BlockOrder = r.blockOrder
+	}
+	ingestor.ListPaths = cfg.SortLists
+	ingestor.Targets = targets(cfg)
+	ingestor.BlockSchemas = blockSchemas(cfg)
+	ingestor.Plugins = plugins(cfg)
+	if !r.allowPlugins {
+		// Plugins run commands named by configuration files, which may come from untrusted repositories.
+		for _, plugin := range ingestor.Plugins {
+			fmt.Fprintf(
+				os.Stderr,
+				"Warning: plugin '%s' of the configuration is not run; pass --allow-plugins to run it\n",
+				plugin.Name,
+			)
+		}
+		ingestor.Plugins = nil
+	}
+	for _, plugin := range ingestor.Plugins {
+		for _, fileType := range plugin.FileTypes {
+			ingestor.AllowedTypes[fileType] = true
+		}
+	}
+	if enabled(r.sortMapKeys, cfg.SortMapKeys) {
+		attributes := hclsort.DefaultMapAttributes
+		if cfg.MapAttributes != nil {
+			attributes = cfg.MapAttributes
+		}
+		ingestor.MapAttributes = make(map[string]bool, len(attributes))
+		for _, name := range attributes {
+			ingestor.MapAttributes[name] = true
+		}
+	}
+	return nil
+}
+
+// targets converts the targets of cfg into the Targets of an Ingestor.
+func targets(cfg *config.Config) []hclsort.Target {
+	result := make([]hclsort.Target, 0, len(cfg.Targets))
+	for _, target := range cfg.Targets {
+		converted := hclsort.Target{
+			BlockType:    target.BlockType,
+			SortKeys:     target.SortKeys,
+			SortElements: target.SortElements,
+			Ignore:       target.Ignore,
+		}
+		if target.Label != nil {
+			// Labels were validated when the configuration file was loaded.
+			converted.Label = regexp.MustCompile(*target.Label)
+		}
+		if target.Path != "" {
+			converted.Path = strings.Split(target.Path, ".")
+		}
+		if target.SortBy != nil {
+			converted.SortBy = *target.SortBy
+		}
+		result = append(result, converted)
+	}
+	return result
+}
+
+// blockSchemas converts the block declarations of cfg into the BlockSchemas of an Ingestor.
+func blockSchemas(cfg *config.Config) map[string]hclsort.BlockSchema {
+	if len(cfg.Blocks) == 0 {
+		return nil
+	}
+	result := make(map[string]hclsort.BlockSchema, len(cfg.Blocks))
+	for _, block := range cfg.Blocks {
+		keyLabels := block.KeyLabels
+		if keyLabels == nil {
+			keyLabels = []int{0}
+		}
+		result[block.BlockType] = hclsort.BlockSchema{KeyLabels: keyLabels, Pinned: block.Pinned}
+	}
+	return result
+}
+
+// plugins converts the plugins of cfg into the Plugins of an Ingestor. Plugins declared again by a
+// nearer configuration file replace the outer declaration.
+func plugins(cfg *config.Config) []hclsort.Plugin {
+	var names []string
+	declared := map[string]*config.Plugin{}
+	for _, plugin := range cfg.Plugins {
+		if declared[plugin.Name] == nil {
+			names = append(names, plugin.Name)
+		}
+		declared[plugin.Name] = plugin
+	}
+
+	result := make([]hclsort.Plugin, 0, len(names))
+	for _, name := range names {
+		plugin := declared[name]
+		command := slices.Clone(plugin.Command)
+		if strings.ContainsAny(command[0], `/\`) && !filepath.IsAbs(command[0]) {
+			command[0] = filepath.Join(plugin.Dir, command[0])
+		}
+		result = append(result, hclsort.Plugin{
+			Name:       name,
+			Command:    command,
+			BlockTypes: plugin.BlockTypes,
+			FileTypes:  plugin.FileTypes,
+		})
+	}
+	return result
+}
+
+// applySectionHeaders renders the section headers of the sorted block types when enabled.
+func (r *configResolver) applySectionHeaders(
+	ingestor *hclsort.Ingestor,
+	cfg *config.Config,
+	sortBlocks []string,
+) error {
+	if !enabled(r.sectionHeaders, cfg.SectionHeaders) {
+		return nil
+	}
+
+	header := hclsort.DefaultSectionHeader
+	if cfg.SectionHeader != nil {
+		header = *cfg.SectionHeader
+	}
+	if sortBlocks == nil {
+		sortBlocks = slices.Sorted(maps.Keys(ingestor.AllowedBlocks))
+	}
+	blockTypes := slices.DeleteFunc(slices.Clone(sortBlocks), func(blockType string) bool {
+		return r.excludedBlocks[blockType]
+	})
+
+	var err error
+	if ingestor.SectionHeaders, err = hclsort.RenderSectionHeaders(header, blockTypes, cfg.SectionTitles); err != nil {
+		return fmt.Errorf("invalid section headers in config file '%s': %w", cfg.Path, err)
+	}
+	return nil
+}
+
+// ignored reports whether path is excluded by ignore files or configured ignore patterns.
+func (r *configResolver) ignored(path string, isDir bool) (bool, error) {
+	if _, err := r.configFor(filepath.Dir(path)); err != nil {
+		return false, err
+	}
+
+	return r.ignores.Ignored(path, isDir)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// newConsolidateCommand returns the consolidate subcommand, which gathers the variables and outputs
+// spread across the files of a module into one file each.
+func newConsolidateCommand(opts *runOptions) *cobra.Command {
+	var variablesFile, outputsFile string
+
+	cmd := &cobra.Command{
+		Use:   "consolidate [flags] <module directory>",
+		Short: "Gather the variables and outputs of a module into one sorted file each.",
+		Long: "Move the variable and output blocks spread across the Terraform files of a module directory " +
+			"into a single variables file and outputs file, and sort those files. Files left empty are " +
+			"removed. Nothing is moved when a variable or output is defined more than once. The changes are " +
+			"printed as unified diffs unless --write is set.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			destinations := map[string]string{"variable": variablesFile, "output": outputsFile}
+			for blockType, name := range destinations {
+				if ext := filepath.Ext(name); filepath.Base(name) != name || (ext != ".tf" && ext != ".tofu") {
+					return fmt.Errorf(
+						"the %s file must be a .tf or .tofu file name without directories, got '%s'",
+						blockType,
+						name,
+					)
+				}
+			}
+
+			resolver, err := newConfigResolver(cmd, opts)
+			if err != nil {
+				return err
+			}
+			defer resolver.Close()
+			ingestor, err := resolver.ingestorForDir(args[0])
+			if err != nil {
+				return err
+			}
+
+			results, err := ingestor.Consolidate(args[0], destinations)
+			if err != nil {
+				return err
+			}
+			return applyModuleChanges(results, *opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&variablesFile, "variables-file", "variables.tf", "file the variables are gathered in.")
+	cmd.Flags().StringVar(&outputsFile, "outputs-file", "outputs.tf", "file the outputs are gathered in.")
+	return cmd
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/AlexNabokikh/tfsort/internal/daemon"
+	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+	"github.com/spf13/cobra"
+)
+
+// defaultSocketPath returns the path of the unix socket the daemon listens on by default, which is
+// private to the current user.
+func defaultSocketPath() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("tfsort-%d.sock", os.Getuid()))
+}
+
+// newDaemonCommand returns the daemon subcommand, which answers sort and check requests over a unix
+// socket, keeping configuration and sorted files in memory between requests.
+func newDaemonCommand(opts *runOptions) *cobra.Command {
+	var socket string
+	cmd := &cobra.Command{
+		Use:   "daemon [flags]",
+		Short: "Run a daemon answering sort and check requests over a unix socket with JSON-RPC.",
+		Long: "Run a daemon answering JSON-RPC 2.0 requests over a unix socket, one JSON message per line. " +
+			"The sort method sorts a file or the given source, the check method reports the unsorted files " +
+			"of files and directories, reload reads configuration files again and shutdown stops the daemon. " +
+			"Files are sorted with the flags of the command and the .tfsort.hcl files found above them.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			backend := &daemonBackend{cmd: cmd, opts: opts}
+			if err := backend.Reload(); err != nil {
+				return err
+			}
+			defer func() {
+				// Reloading replaces the resolver.
+				_ = backend.resolver.Close()
+			}()
+
+			listener, err := daemon.Listen(socket)
+			if err != nil {
+				return err
+			}
+			defer os.Remove(socket)
+			fmt.Fprintf(os.Stderr, "Listening on %s\n", socket)
+			return daemon.NewServer(backend).Serve(cmd.Context(), listener)
+		},
+	}
+	cmd.Flags().StringVar(&socket, "socket", defaultSocketPath(), "path of the unix socket to listen on.")
+	return cmd
+}
+
+// daemonBackend sorts the files of daemon requests as configured by the flags of the daemon command.
+type daemonBackend struct {
+	cmd      *cobra.Command
+	opts     *runOptions
+	resolver *configResolver
+}
+
+// Sort implements daemon.Backend.
+func (b *daemonBackend) Sort(ctx context.Context, path string, src []byte) (*hclsort.Result, error) {
+	ingestor, err := b.resolver.ingestorFor(path)
+	if err != nil {
+		return nil, err
+	}
+	return ingestor.SortSourceContext(ctx, path, src)
+}
+
+// Files implements daemon.Backend. Directories are walked like with --recursive.
+func (b *daemonBackend) Files(ctx context.Context, path string) ([]string, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat path: %w", err)
+	}
+	if !stat.IsDir() {
+		if err = hclsort.ValidateFilePath(path); err != nil {
+			return nil, err
+		}
+		return []string{path}, nil
+	}
+
+	var files []string
+	// Files are selected like by the walks of the CLI, without printing progress.
+	walkOpts := runOptions{recursive: true}
+	err = filepath.WalkDir(path, sortableFilesWalker(ctx, b.resolver, path, walkOpts, func(file string) error {
+		files = append(files, file)
+		return nil
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("error walking directory '%s': %w", path, err)
+	}
+	return files, nil
+}
+
+// Reload implements daemon.Backend. The previous configuration is released once it is replaced.
+func (b *daemonBackend) Reload() error {
+	resolver, err := newConfigResolver(b.cmd, b.opts)
+	if err != nil {
+		return err
+	}
+	if b.resolver != nil {
+		_ = b.resolver.Close()
+	}
+	b.resolver = resolver
+	return nil
+}
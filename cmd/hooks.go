@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Modes of the hooks installed by the install-hooks subcommand.
+const (
+	hookModeCheck = "check"
+	hookModeFix   = "fix"
+)
+
+// hookMarker identifies the pre-commit hooks installed by tfsort, which can be replaced without --force.
+const hookMarker = "# Installed by tfsort install-hooks."
+
+// hookTemplate is the pre-commit hook running tfsort on the staged content of every staged Terraform
+// file, rather than on the working tree, so unstaged changes are neither checked nor committed. It is
+// formatted with a description of the hook and the commands run for each file. The tfsort command can
+// be overridden with the TFSORT environment variable.
+const hookTemplate = `#!/bin/sh
+` + hookMarker + `
+# It %s
+set -f
+files=$(git -c core.quotePath=false diff --cached --name-only --diff-filter=ACMR -- '*.tf' '*.tofu' '*.tfvars')
+[ -z "$files" ] && exit 0
+
+tfsort=${TFSORT:-tfsort}
+sorted=$(mktemp) || exit 1
+trap 'rm -f "$sorted"' EXIT
+status=0
+IFS='
+'
+for file in $files; do
+%s
+done
+exit $status
+`
+
+// hookCheckCommands fail the check hook when the staged content of $file is not sorted.
+const hookCheckCommands = `	git show ":$file" | "$tfsort" --check --stdin-filename "$file" - || status=1`
+
+// hookFixCommands sort the staged content of $file and stage the result. The working tree is only
+// sorted too when it matches the index; otherwise its unstaged changes are left as they are.
+const hookFixCommands = `	if ! git show ":$file" | "$tfsort" --stdin-filename "$file" - >"$sorted"; then
+		status=1
+		continue
+	fi
+	git show ":$file" | cmp -s - "$sorted" && continue
+
+	if git diff --quiet -- "$file"; then
+		cat "$sorted" >"$file" && git add -- "$file" || status=1
+		continue
+	fi
+	mode=$(git ls-files --stage -- "$file" | cut -d ' ' -f 1)
+	if blob=$(git hash-object -w --stdin <"$sorted") && git update-index --cacheinfo "$mode,$blob,$file"; then
+		echo "Sorted the staged content of $file; its unstaged changes were left as they are." >&2
+	else
+		status=1
+	fi`
+
+// hookScript returns the pre-commit hook running tfsort on the staged Terraform files in mode.
+func hookScript(mode string) string {
+	if mode == hookModeFix {
+		return fmt.Sprintf(hookTemplate, "sorts the staged Terraform files and stages the result.", hookFixCommands)
+	}
+	return fmt.Sprintf(
+		hookTemplate,
+		"fails the commit when a staged Terraform file is not sorted.",
+		hookCheckCommands,
+	)
+}
+
+// newInstallHooksCommand returns the install-hooks subcommand, which installs a git pre-commit hook
+// running tfsort on the staged Terraform files, or prints the configuration of the pre-commit framework.
+func newInstallHooksCommand(version string) *cobra.Command {
+	var (
+		mode        string
+		force       bool
+		printConfig bool
+	)
+	cmd := &cobra.Command{
+		Use:   "install-hooks [flags]",
+		Short: "Install a git pre-commit hook running tfsort on the staged Terraform files.",
+		Long: "Install a git pre-commit hook in the current repository that runs tfsort on the staged .tf, " +
+			".tofu and .tfvars files, either failing the commit when they are not sorted (--mode check) or " +
+			"sorting and staging them (--mode fix). Files are sorted as configured by the .tfsort.hcl files " +
+			"of the repository. With --print-config the configuration of the pre-commit framework is " +
+			"printed instead.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if mode != hookModeCheck && mode != hookModeFix {
+				return fmt.Errorf("invalid hook mode '%s': must be %s or %s", mode, hookModeCheck, hookModeFix)
+			}
+			if printConfig {
+				fmt.Print(preCommitConfig(mode, version))
+				return nil
+			}
+
+			path, err := installPreCommitHook(hookScript(mode), force)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Installed %s\n", path)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(
+		&mode,
+		"mode",
+		hookModeCheck,
+		fmt.Sprintf(
+			"what the hook does: %s fails commits with unsorted files, %s sorts and stages them.",
+			hookModeCheck,
+			hookModeFix,
+		),
+	)
+	cmd.Flags().BoolVar(&force, "force", false, "replace an existing pre-commit hook not installed by tfsort.")
+	cmd.Flags().BoolVar(
+		&printConfig,
+		"print-config",
+		false,
+		"print the .pre-commit-config.yaml entry for the pre-commit framework instead of installing a hook.",
+	)
+	return cmd
+}
+
+// installPreCommitHook writes script as the pre-commit hook of the git repository containing the working
+// directory, and returns its path. Hooks not installed by tfsort are only replaced when force is set.
+func installPreCommitHook(script string, force bool) (string, error) {
+	// Asking git for the hooks directory respects core.hooksPath and worktrees.
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("failed to find the git hooks directory: %s", bytes.TrimSpace(exitErr.Stderr))
+		}
+		return "", fmt.Errorf("failed to run git: %w", err)
+	}
+	dir := strings.TrimSpace(string(out))
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory '%s': %w", dir, err)
+	}
+
+	path := filepath.Join(dir, "pre-commit")
+	existing, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return "", fmt.Errorf("failed to read file '%s': %w", path, err)
+	case !force && !bytes.Contains(existing, []byte(hookMarker)):
+		return "", fmt.Errorf("a pre-commit hook already exists at '%s'; use --force to replace it", path)
+	}
+
+	//nolint:gosec // Hooks must be executable.
+	if err = os.WriteFile(path, []byte(script), 0755); err != nil {
+		return "", fmt.Errorf("failed to write file '%s': %w", path, err)
+	}
+	// WriteFile keeps the permissions of existing files, which may not be executable.
+	if err = os.Chmod(path, 0755); err != nil {
+		return "", fmt.Errorf("failed to make '%s' executable: %w", path, err)
+	}
+	return path, nil
+}
+
+// preCommitConfig returns the .pre-commit-config.yaml entry running the published hook for mode, pinned to
+// the release version of tfsort when it is known: tfsort-check checks files, tfsort sorts them in place.
+func preCommitConfig(mode, version string) string {
+	rev := "v" + strings.TrimPrefix(version, "v")
+	if version == "" || version == "dev" {
+		rev = "main # pin to a release tag"
+	}
+	id := "tfsort-check"
+	if mode == hookModeFix {
+		id = "tfsort"
+	}
+	return fmt.Sprintf(`repos:
+  - repo: https://github.com/AlexNabokikh/tfsort
+    rev: %s
+    hooks:
+      - id: %s
+`, rev, id)
+}
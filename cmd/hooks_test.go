@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the tfsort command instead of the tests when TFSORT_TEST_MAIN is set, so hooks can
+// run the test binary as tfsort.
+func TestMain(m *testing.M) {
+	if os.Getenv("TFSORT_TEST_MAIN") == "1" {
+		Execute("dev", "none", "unknown")
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestPreCommitConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		version string
+		want    []string
+	}{
+		{
+			name:    "Release check",
+			mode:    hookModeCheck,
+			version: "1.2.0",
+			want:    []string{"rev: v1.2.0", "- id: tfsort-check\n"},
+		},
+		{name: "Tagged fix", mode: hookModeFix, version: "v1.2.0", want: []string{"rev: v1.2.0", "- id: tfsort\n"}},
+		{name: "Development build", mode: hookModeCheck, version: "dev", want: []string{"rev: main"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := preCommitConfig(tt.mode, tt.version)
+			if !strings.HasPrefix(got, "repos:\n  - repo: https://github.com/AlexNabokikh/tfsort\n") {
+				t.Errorf("preCommitConfig() = %q, want the tfsort repository", got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("preCommitConfig() = %q, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+// gitRepo creates a git repository with an unsorted staged file and unstaged changes to it, and a staged
+// unsorted tfvars file.
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	git(t, dir, "init", "-q")
+	writeTestFile(t, filepath.Join(dir, "main.tf"), "variable \"b\" {}\nvariable \"a\" {}\n")
+	writeTestFile(t, filepath.Join(dir, "prod.tfvars"), "b = 1\na = 2\n")
+	writeTestFile(t, filepath.Join(dir, "README.md"), "b\na\n")
+	git(t, dir, "add", "-A")
+	writeTestFile(t, filepath.Join(dir, "main.tf"), "variable \"b\" {}\nvariable \"a\" {}\n# unstaged\n")
+	return dir
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// runHook runs the hook installed for mode in dir with the test binary as tfsort.
+func runHook(t *testing.T, dir, mode string) (string, error) {
+	t.Helper()
+	hook := filepath.Join(dir, "hook.sh")
+	writeTestFile(t, hook, hookScript(mode))
+	cmd := exec.Command("sh", hook)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "TFSORT="+os.Args[0], "TFSORT_TEST_MAIN=1")
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestHookScriptCheck(t *testing.T) {
+	dir := gitRepo(t)
+	out, err := runHook(t, dir, hookModeCheck)
+	if err == nil {
+		t.Fatalf("check hook succeeded, want a failure for unsorted staged files:\n%s", out)
+	}
+	for _, want := range []string{"main.tf is not sorted", "prod.tfvars is not sorted"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("check hook output = %q, want it to contain %q", out, want)
+		}
+	}
+
+	// Only the staged content is checked, whatever the working tree holds.
+	git(t, dir, "reset", "-q")
+	writeTestFile(t, filepath.Join(dir, "main.tf"), "variable \"a\" {}\n\nvariable \"b\" {}\n")
+	git(t, dir, "add", "main.tf")
+	writeTestFile(t, filepath.Join(dir, "main.tf"), "variable \"b\" {}\nvariable \"a\" {}\n")
+	if out, err = runHook(t, dir, hookModeCheck); err != nil {
+		t.Errorf("check hook error = %v, want none for sorted staged content:\n%s", err, out)
+	}
+}
+
+func TestHookScriptFix(t *testing.T) {
+	dir := gitRepo(t)
+	if out, err := runHook(t, dir, hookModeFix); err != nil {
+		t.Fatalf("fix hook error = %v:\n%s", err, out)
+	}
+
+	if got, want := git(t, dir, "show", ":main.tf"), "variable \"a\" {}\n\nvariable \"b\" {}\n"; got != want {
+		t.Errorf("staged main.tf = %q, want %q", got, want)
+	}
+	if got, want := git(t, dir, "show", ":prod.tfvars"), "a = 2\nb = 1\n"; got != want {
+		t.Errorf("staged prod.tfvars = %q, want %q", got, want)
+	}
+	// Files with unstaged changes keep them out of the index and in the working tree.
+	content, err := os.ReadFile(filepath.Join(dir, "main.tf"))
+	if want := "variable \"b\" {}\nvariable \"a\" {}\n# unstaged\n"; err != nil || string(content) != want {
+		t.Errorf("main.tf = %q (%v), want %q", content, err, want)
+	}
+	// Fully staged files are sorted in the working tree too.
+	content, err = os.ReadFile(filepath.Join(dir, "prod.tfvars"))
+	if want := "a = 2\nb = 1\n"; err != nil || string(content) != want {
+		t.Errorf("prod.tfvars = %q (%v), want %q", content, err, want)
+	}
+}
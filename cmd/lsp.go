@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/AlexNabokikh/tfsort/internal/config"
+	"github.com/AlexNabokikh/tfsort/internal/lsp"
+	"github.com/spf13/cobra"
+)
+
+// newLSPCommand returns the lsp subcommand, which runs a Language Server Protocol server over stdio
+// so editors can sort files on save without starting tfsort for every change.
+func newLSPCommand(opts *runOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "lsp [flags]",
+		Short: "Run a Language Server Protocol server formatting files with tfsort over stdio.",
+		Long: "Run a Language Server Protocol server over stdin and stdout, providing the " +
+			"textDocument/formatting and textDocument/rangeFormatting requests. Documents are sorted " +
+			"with the flags of the command and the .tfsort.hcl files found above them, which are read " +
+			"again for every request and are not formatted themselves. Ranges are extended to whole " +
+			"lines, which must hold whole blocks.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			server := lsp.NewServer(func(path string, src []byte) ([]byte, error) {
+				// Configuration files are skipped like when sorting directories, so they get no edits.
+				if filepath.Base(path) == config.FileName {
+					return src, nil
+				}
+				// Configuration files are loaded again, so edits to them apply without restarting the server.
+				resolver, err := newConfigResolver(cmd, opts)
+				if err != nil {
+					return nil, err
+				}
+				defer resolver.Close()
+				ingestor, err := resolver.ingestorFor(path)
+				if err != nil {
+					return nil, err
+				}
+				result, err := ingestor.SortSourceContext(cmd.Context(), path, src)
+				if err != nil {
+					return nil, err
+				}
+				return result.Sorted, nil
+			}, cmd.Root().Version)
+			return server.Serve(cmd.Context(), os.Stdin, os.Stdout)
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lspMessage returns msg encoded as a JSON-RPC message with a Content-Length header.
+func lspMessage(t *testing.T, msg map[string]any) string {
+	t.Helper()
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Failed to encode message: %v", err)
+	}
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func TestLSPSkipsConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	configURI := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dir, ".tfsort.hcl"))}).String()
+	mainURI := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dir, "main.tf"))}).String()
+	unsorted := "variable \"b\" {}\nvariable \"a\" {}\n"
+	messages := []map[string]any{
+		{"id": 1, "method": "initialize", "params": map[string]any{}},
+		{"method": "textDocument/didOpen", "params": map[string]any{
+			"textDocument": map[string]any{"uri": configURI, "text": "wrap = 80\nindent = 2\n"},
+		}},
+		{"method": "textDocument/didOpen", "params": map[string]any{
+			"textDocument": map[string]any{"uri": mainURI, "text": unsorted},
+		}},
+		{"id": 2, "method": "textDocument/formatting", "params": map[string]any{
+			"textDocument": map[string]any{"uri": configURI},
+		}},
+		{"id": 3, "method": "textDocument/formatting", "params": map[string]any{
+			"textDocument": map[string]any{"uri": mainURI},
+		}},
+		{"id": 4, "method": "shutdown"},
+		{"method": "exit"},
+	}
+	var in strings.Builder
+	for _, msg := range messages {
+		in.WriteString(lspMessage(t, msg))
+	}
+
+	cmd := exec.Command(os.Args[0], "lsp")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "TFSORT_TEST_MAIN=1")
+	cmd.Stdin = strings.NewReader(in.String())
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("tfsort lsp failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), `"id":2,"result":[]`) {
+		t.Errorf("tfsort lsp output = %s, want no edits for the configuration file", out)
+	}
+	if !strings.Contains(string(out), `"id":3,"result":[{`) {
+		t.Errorf("tfsort lsp output = %s, want edits sorting main.tf", out)
+	}
+}
@@ -1,27 +1,102 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
 
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 
+	"github.com/AlexNabokikh/tfsort/internal/config"
 	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/spf13/cobra"
 )
 
+// exitCodeUnsorted is returned by --check when at least one input is not sorted.
+const exitCodeUnsorted = 3
+
+// exitCodeInterrupted is returned when a run is cancelled by SIGINT or SIGTERM, like shells report
+// processes killed by SIGINT.
+const exitCodeInterrupted = 130
+
+// errUnsorted signals that --check found inputs that would be changed by sorting.
+var errUnsorted = errors.New("some inputs are not sorted") //nolint:gochecknoglobals // Sentinel error
+
+// runOptions holds the flags that control how inputs are processed and written.
+type runOptions struct {
+	outputPath  string
+	dryRun      bool
+	write       bool
+	check       bool
+	diff        bool
+	list        bool
+	recursive   bool
+	noGitignore bool
+	configPath  string
+	// stdinFilename names the file whose content is read from stdin.
+	stdinFilename string
+	// allowPlugins runs the plugins declared by configuration files.
+	allowPlugins bool
+
+	includeGenerated     bool
+	sections             bool
+	groupByBlankLines    bool
+	sectionHeaders       bool
+	sortNestedBlocks     bool
+	sortDependsOn        bool
+	sortMapKeys          bool
+	sortObjectTypes      bool
+	sortVariableDefaults bool
+	localsDepth          int
+	localsOrder          string
+	mergeTerraform       bool
+	groupResources       string
+	alignEquals          string
+	providerSource       string
+	dialect              string
+	overrideFiles        string
+	blankLines           int
+	typeBlankLines       int
+	blockOrder           []string
+	minimalMoves         bool
+	preserveFormatting   bool
+	normalizeVersions    bool
+	sortTfvarsValues     bool
+	types                []string
+	excludeTypes         []string
+	sortStrategy         string
+	ignoreCase           bool
+	collation            string
+}
+
+// quiet reports whether progress messages should be suppressed.
+// Only in-place rewrites report progress, so stdout stays clean for the sorted content.
+func (o runOptions) quiet() bool {
+	return !o.write
+}
+
+// inspectOnly reports whether inputs are only compared against their sorted form and never written.
+func (o runOptions) inspectOnly() bool {
+	return o.check || o.diff || o.list
+}
+
+// toStdout reports whether sorted content should be printed instead of written to a file.
+func (o runOptions) toStdout() bool {
+	return o.dryRun || (!o.write && o.outputPath == "")
+}
+
 // Execute is the entry point for the CLI.
 func Execute(version, commit, date string) {
-	var (
-		outputPath string
-		dryRun     bool
-	)
+	var opts runOptions
 
 	rootCmd := &cobra.Command{
-		Use:   "tfsort [flags] [files...]",
+		Use:   "tfsort [flags] [files or globs...]",
 		Short: "A utility to sort Terraform variables and outputs.",
 		Args:  cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -29,13 +104,18 @@ func Execute(version, commit, date string) {
 				return cmd.Help()
 			}
 
-			ingestor := hclsort.NewIngestor()
+			resolver, err := newConfigResolver(cmd, &opts)
+			if err != nil {
+				return err
+			}
+			defer resolver.Close()
+
 			paths, err := argsToPaths(args)
 			if err != nil {
 				return err
 			}
 
-			return processPaths(ingestor, paths, dryRun, outputPath)
+			return processPaths(cmd.Context(), resolver, paths, opts)
 		},
 	}
 
@@ -62,20 +142,322 @@ func Execute(version, commit, date string) {
 	}
 
 	rootCmd.PersistentFlags().StringVarP(
-		&outputPath,
+		&opts.outputPath,
 		"out",
 		"o",
 		"",
 		"path to the output file (cannot be used when path is used as an argument)",
 	)
 	rootCmd.PersistentFlags().BoolVarP(
-		&dryRun,
+		&opts.write,
+		"write",
+		"w", false,
+		"write the sorted content back to the original file(s) instead of stdout.",
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&opts.dryRun,
 		"dry-run",
 		"d", false,
-		"preview the changes without altering the original file(s).",
+		"preview the changes on stdout without altering the original file(s) (default unless --write is set).",
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&opts.check,
+		"check",
+		"c", false,
+		fmt.Sprintf(
+			"check whether the file(s) are sorted without altering them; exits with status %d if not.",
+			exitCodeUnsorted,
+		),
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&opts.recursive,
+		"recursive",
+		"r", false,
+		"process directories recursively, including all of their subdirectories.",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.diff,
+		"diff",
+		false,
+		"print a unified diff of the changes instead of the sorted content; can be combined with --check.",
+	)
+	rootCmd.PersistentFlags().BoolVarP(
+		&opts.list,
+		"list",
+		"l", false,
+		"list only the files whose content would change, one per line; can be combined with --check.",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.noGitignore,
+		"no-gitignore",
+		false,
+		"do not skip paths matched by .gitignore files when walking directories.",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&opts.configPath,
+		"config",
+		"",
+		fmt.Sprintf(
+			"path to the configuration file (default: nearest %s in the working directory or its parents).",
+			config.FileName,
+		),
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.allowPlugins,
+		"allow-plugins",
+		false,
+		"run the plugin commands declared by configuration files; only use it for trusted repositories.",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&opts.stdinFilename,
+		"stdin-filename",
+		"",
+		"name of the file read from stdin, e.g. prod.tfvars; it selects how the content is sorted "+
+			"and which configuration applies.",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.includeGenerated,
+		"include-generated",
+		false,
+		"sort generated files, detected by a \"Code generated ... DO NOT EDIT\" header comment, "+
+			"instead of skipping them.",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.sections,
+		"sections",
+		false,
+		"sort blocks only within sections delimited by banner comments, e.g. \"### Networking ###\".",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.groupByBlankLines,
+		"group-by-blank-lines",
+		false,
+		"sort blocks only within groups of adjacent blocks, never moving a block across a blank line.",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.sectionHeaders,
+		"section-headers",
+		false,
+		"insert a header comment, e.g. \"# --- Variables ---\", above the blocks of each sorted block type.",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.sortNestedBlocks,
+		"sort-nested-blocks",
+		false,
+		"sort the nested blocks of resource, data and module bodies by type and labels.",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.sortDependsOn,
+		"sort-depends-on",
+		false,
+		"sort the references in depends_on lists of resources, data sources, modules and outputs.",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.sortMapKeys,
+		"sort-map-keys",
+		false,
+		fmt.Sprintf(
+			"sort the keys of map literals assigned to %s, or the map_attributes of the configuration file.",
+			strings.Join(hclsort.DefaultMapAttributes, ", "),
+		),
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.sortObjectTypes,
+		"sort-object-types",
+		false,
+		"sort the attributes of object type constraints in the types of variables, e.g. object({ a = string }).",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.sortVariableDefaults,
+		"sort-variable-defaults",
+		false,
+		"sort the keys of map and object literals in the defaults of variables, including nested ones.",
+	)
+	rootCmd.PersistentFlags().IntVar(
+		&opts.localsDepth,
+		"locals-depth",
+		0,
+		"sort the keys of object literals assigned to locals, up to this many levels of nesting.",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&opts.localsOrder,
+		"locals-order",
+		hclsort.LocalsOrderAlphabetical,
+		fmt.Sprintf(
+			"order of the assignments in locals blocks: %s, or %s to define locals before their uses.",
+			hclsort.LocalsOrderAlphabetical,
+			hclsort.LocalsOrderTopological,
+		),
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.mergeTerraform,
+		"merge-terraform-blocks",
+		false,
+		"merge the terraform blocks of each file into one, unless their settings conflict.",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&opts.groupResources,
+		"group-resources",
+		"",
+		fmt.Sprintf(
+			"group sorted resources by the %s prefix of their type or by %s, separating groups by blank lines.",
+			hclsort.ResourceGroupingProvider,
+			hclsort.ResourceGroupingType,
+		),
+	)
+	rootCmd.PersistentFlags().StringSliceVar(
+		&opts.types,
+		"types",
+		nil,
+		"comma-separated list of top-level block types sorted by their labels (default: variable,output).",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.minimalMoves,
+		"minimal-moves",
+		false,
+		"move only the blocks that are out of order, keeping the blank lines around the others.",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.preserveFormatting,
+		"preserve-formatting",
+		false,
+		"reorder blocks and attributes without formatting files, keeping their original spacing.",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.sortTfvarsValues,
+		"sort-tfvars-values",
+		false,
+		"sort the keys of map and object literals assigned in .tfvars files, including nested ones.",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.normalizeVersions,
+		"normalize-versions",
+		false,
+		"normalize the spacing and order of version constraints in terraform blocks and module calls.",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&opts.providerSource,
+		"provider-source",
+		"",
+		fmt.Sprintf(
+			"rewrite provider source addresses in lower case, without (%s) or with (%s) the registry hostname.",
+			hclsort.ProviderSourceShort,
+			hclsort.ProviderSourceQualified,
+		),
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&opts.dialect,
+		"dialect",
+		"",
+		fmt.Sprintf(
+			"dialect of the sorted files: %s (default), %s job specifications, %s policies, %s, %s or %s "+
+				"configurations, or %s for the block types declared in the configuration file.",
+			hclsort.DialectTerraform,
+			hclsort.DialectNomad,
+			hclsort.DialectVault,
+			hclsort.DialectVaultServer,
+			hclsort.DialectConsul,
+			hclsort.DialectWaypoint,
+			hclsort.DialectGeneric,
+		),
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&opts.overrideFiles,
+		"override-files",
+		"",
+		fmt.Sprintf(
+			"handling of override.tf and *_override.tf files: %s (default) sorts within blocks only, %s leaves them "+
+				"untouched, %s sorts them like other files.",
+			hclsort.OverrideFilesContents,
+			hclsort.OverrideFilesSkip,
+			hclsort.OverrideFilesSort,
+		),
+	)
+	rootCmd.PersistentFlags().IntVar(
+		&opts.blankLines,
+		"blank-lines",
+		1,
+		"number of blank lines between top-level blocks.",
+	)
+	rootCmd.PersistentFlags().IntVar(
+		&opts.typeBlankLines,
+		"type-blank-lines",
+		0,
+		"number of blank lines between top-level blocks of different types (default: --blank-lines).",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&opts.alignEquals,
+		"align-equals",
+		"",
+		fmt.Sprintf(
+			"alignment of the equals signs of attributes: %s (default), %s aligns none, %s keeps them as written.",
+			hclsort.AlignmentAlign,
+			hclsort.AlignmentNever,
+			hclsort.AlignmentPreserve,
+		),
+	)
+	rootCmd.PersistentFlags().StringSliceVar(
+		&opts.blockOrder,
+		"block-order",
+		nil,
+		"comma-separated list of block types in the order they appear in files, e.g. terraform,provider,variable.",
+	)
+	rootCmd.PersistentFlags().StringSliceVar(
+		&opts.excludeTypes,
+		"exclude-types",
+		nil,
+		"comma-separated list of block types that are never sorted, e.g. locals; applied after --types.",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&opts.sortStrategy,
+		"sort-strategy",
+		hclsort.StrategyLexical,
+		fmt.Sprintf(
+			"how names are compared: %s (byte order) or %s (numbers by value, e.g. subnet_2 before subnet_10).",
+			hclsort.StrategyLexical,
+			hclsort.StrategyNatural,
+		),
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.ignoreCase,
+		"ignore-case",
+		false,
+		"compare names case-insensitively; names differing only in case keep a deterministic order.",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&opts.collation,
+		"collation",
+		"",
+		"compare names using the collation rules of a locale, e.g. da or de-DE, instead of byte order.",
+	)
+	rootCmd.AddCommand(
+		newSplitCommand(&opts),
+		newConsolidateCommand(&opts),
+		newLSPCommand(&opts),
+		newDaemonCommand(&opts),
+		newWatchCommand(&opts),
+		newInstallHooksCommand(version),
 	)
 
-	if err := rootCmd.Execute(); err != nil {
+	rootCmd.MarkFlagsMutuallyExclusive("check", "dry-run", "write")
+	rootCmd.MarkFlagsMutuallyExclusive("diff", "list", "dry-run", "write")
+	rootCmd.MarkFlagsMutuallyExclusive("recursive", "out")
+	rootCmd.MarkFlagsMutuallyExclusive("check", "out")
+	rootCmd.MarkFlagsMutuallyExclusive("diff", "out")
+	rootCmd.MarkFlagsMutuallyExclusive("list", "out")
+	rootCmd.MarkFlagsMutuallyExclusive("write", "out")
+
+	// Interrupting a run stops it before the next file is sorted, leaving every file either sorted or untouched.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		switch {
+		case errors.Is(err, errUnsorted):
+			os.Exit(exitCodeUnsorted)
+		case errors.Is(err, context.Canceled):
+			os.Exit(exitCodeInterrupted)
+		}
 		os.Exit(1)
 	}
 }
@@ -92,23 +474,75 @@ func argsToPaths(args []string) ([]string, error) {
 		}
 	}
 
-	return args, nil
+	return expandGlobs(args)
+}
+
+// expandGlobs expands glob patterns, including "**" doublestar patterns, in args.
+// Globbing is done here rather than relying on the shell so behavior is consistent across platforms.
+// Arguments that name an existing path or contain no glob syntax are passed through unchanged, while
+// matches in skipped directories and lock files are left out.
+func expandGlobs(args []string) ([]string, error) {
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, "*?[{") {
+			paths = append(paths, arg)
+			continue
+		}
+		if _, err := os.Stat(arg); err == nil {
+			paths = append(paths, arg)
+			continue
+		}
+
+		matches, err := doublestar.FilepathGlob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern '%s': %w", arg, err)
+		}
+
+		matched := false
+		for _, match := range matches {
+			if !inSkippedDir(match) && !hclsort.IsSkippedFile(filepath.Base(match)) {
+				paths = append(paths, match)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no files match pattern '%s'", arg)
+		}
+	}
+
+	return paths, nil
 }
 
 // processPaths processes the provided paths, handling both files and directories.
-// It will walk through directories recursively.
+// Directories are walked recursively only when opts.recursive is set.
 func processPaths(
-	ingestor *hclsort.Ingestor,
+	ctx context.Context,
+	resolver *configResolver,
 	paths []string,
-	dryRun bool,
-	outputPath string,
+	opts runOptions,
 ) error {
+	unsorted := 0
+
 	if len(paths) == 1 && paths[0] == hclsort.StdInPathIdentifier {
-		return ingestor.Parse(paths[0], outputPath, dryRun, true)
+		path := paths[0]
+		if opts.stdinFilename != "" {
+			path = opts.stdinFilename
+		}
+		changed, err := processFile(ctx, resolver, path, opts, true)
+		if err != nil {
+			return err
+		}
+		if changed {
+			unsorted++
+		}
+		return unsortedError(opts, unsorted)
 	}
 
 	pathErrors := []error{}
 	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("sorting interrupted: %w", err)
+		}
 		stat, statErr := os.Stat(path)
 		if statErr != nil {
 			pathErrors = append(pathErrors, fmt.Errorf("failed to stat path: %w", statErr))
@@ -116,8 +550,7 @@ func processPaths(
 		}
 
 		if stat.IsDir() {
-			// Recursive
-			err := filepath.WalkDir(path, newWalkDirCallback(ingestor, dryRun))
+			err := filepath.WalkDir(path, newWalkDirCallback(ctx, resolver, path, opts, &unsorted))
 			if err != nil {
 				pathErrors = append(pathErrors, fmt.Errorf("error walking directory '%s': %w", path, err))
 			}
@@ -128,13 +561,19 @@ func processPaths(
 				continue
 			}
 
-			err := ingestor.Parse(path, outputPath, dryRun, false)
+			changed, err := processFile(ctx, resolver, path, opts, false)
 			if err != nil {
 				pathErrors = append(pathErrors, fmt.Errorf("error processing file '%s': %w", path, err))
 			}
+			if changed {
+				unsorted++
+			}
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("sorting interrupted: %w", err)
+	}
 	if len(pathErrors) > 0 {
 		errStrings := make([]string, len(pathErrors))
 		for i, e := range pathErrors {
@@ -143,15 +582,108 @@ func processPaths(
 		return fmt.Errorf("could not process all paths:\n%s", strings.Join(errStrings, "\n"))
 	}
 
-	return nil
+	return unsortedError(opts, unsorted)
+}
+
+// processFile sorts a single input according to opts.
+// In check, diff and list modes it reports whether the input would change instead of writing it.
+func processFile(
+	ctx context.Context,
+	resolver *configResolver,
+	path string,
+	opts runOptions,
+	isStdin bool,
+) (bool, error) {
+	ingestor, err := resolver.ingestorFor(path)
+	if err != nil {
+		return false, err
+	}
+
+	if !opts.inspectOnly() {
+		return false, ingestor.ParseContext(ctx, path, opts.outputPath, opts.toStdout(), isStdin)
+	}
+
+	result, err := ingestor.SortContext(ctx, path, isStdin)
+	if err != nil {
+		return false, err
+	}
+	if result.Skipped && opts.check && !opts.diff && !opts.list {
+		fmt.Printf("%s skipped\n", path)
+	}
+	if !result.Changed() {
+		return false, nil
+	}
+
+	switch {
+	case opts.diff:
+		fmt.Print(hclsort.UnifiedDiff(path, result.Original, result.Sorted))
+	case opts.list:
+		fmt.Println(path)
+	default:
+		fmt.Printf("%s is not sorted\n", path)
+	}
+
+	return true, nil
+}
+
+// unsortedError returns errUnsorted when check mode found unsorted inputs.
+func unsortedError(opts runOptions, unsorted int) error {
+	if !opts.check || unsorted == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d file(s) would be changed", errUnsorted, unsorted)
 }
 
 // newWalkDirCallback creates a callback function for filepath.WalkDir.
+// Subdirectories of root are only entered when opts.recursive is set,
+// and paths excluded by ignore files are skipped. The walk stops once ctx is done.
 func newWalkDirCallback(
-	ingestor *hclsort.Ingestor,
-	isDryRun bool,
+	ctx context.Context,
+	resolver *configResolver,
+	root string,
+	opts runOptions,
+	unsorted *int,
+) fs.WalkDirFunc {
+	return sortableFilesWalker(ctx, resolver, root, opts, func(currentPath string) error {
+		if !opts.quiet() {
+			fmt.Printf("Processing %s...\n", currentPath)
+		}
+		opts.outputPath = ""
+		changed, err := processFile(ctx, resolver, currentPath, opts, false)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			fmt.Fprintf(
+				os.Stderr,
+				"Error sorting file %s: %v\n",
+				currentPath,
+				err,
+			)
+		}
+		if changed {
+			*unsorted++
+		}
+		return nil
+	})
+}
+
+// sortableFilesWalker creates a callback function for filepath.WalkDir calling visit with every file
+// under root that is sorted: files of an allowed type that are neither ignored nor configuration
+// files. Subdirectories of root are only entered when opts.recursive is set. The walk stops once ctx
+// is done or visit fails.
+func sortableFilesWalker(
+	ctx context.Context,
+	resolver *configResolver,
+	root string,
+	opts runOptions,
+	visit func(path string) error,
 ) fs.WalkDirFunc {
 	return func(currentPath string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		if err != nil {
 			fmt.Fprintf(
 				os.Stderr,
@@ -167,38 +699,55 @@ func newWalkDirCallback(
 		}
 
 		if d.IsDir() {
-			dirName := d.Name()
-			if dirName == ".git" ||
-				dirName == ".terraform" ||
-				dirName == ".terragrunt-cache" {
-				if !isDryRun {
+			if hclsort.IsSkippedDir(d.Name()) {
+				if !opts.quiet() {
 					fmt.Printf("Skipping directory: %s\n", currentPath)
 				}
 				return filepath.SkipDir
 			}
 
-			return nil
+			if !opts.recursive && currentPath != root {
+				return filepath.SkipDir
+			}
 		}
 
-		fileExtension := strings.TrimPrefix(filepath.Ext(currentPath), ".")
-		if !ingestor.AllowedTypes[fileExtension] {
-			return nil
+		if currentPath != root {
+			ignored, ignoreErr := resolver.ignored(currentPath, d.IsDir())
+			if ignoreErr != nil {
+				return ignoreErr
+			}
+			if ignored {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 		}
 
-		if !isDryRun {
-			fmt.Printf("Processing %s...\n", currentPath)
+		// Configuration and lock files share the .hcl extension but are never sorted.
+		if d.IsDir() || d.Name() == config.FileName || hclsort.IsSkippedFile(d.Name()) {
+			return nil
 		}
-		err = ingestor.Parse(currentPath, "", isDryRun, false)
+
+		ingestor, err := resolver.ingestorFor(currentPath)
 		if err != nil {
-			fmt.Fprintf(
-				os.Stderr,
-				"Error sorting file %s: %v\n",
-				currentPath,
-				err,
-			)
+			return err
+		}
+		if !ingestor.AllowedTypes[hclsort.FileType(currentPath)] {
+			return nil
+		}
+		return visit(currentPath)
+	}
+}
+
+// inSkippedDir reports whether any directory component of path is skipped by hclsort.IsSkippedDir.
+func inSkippedDir(path string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		if hclsort.IsSkippedDir(part) {
+			return true
 		}
-		return nil
 	}
+	return false
 }
 
 // useStdin determines whether to read stdin.
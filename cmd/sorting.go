@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/AlexNabokikh/tfsort/internal/config"
+	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+)
+
+// sortSettings describes how the labels and attribute names of a block type are compared.
+type sortSettings struct {
+	strategy   string
+	collation  string
+	ignoreCase bool
+	order      string
+	first      []string
+	groups     []string
+	// wasm is the path to the WebAssembly module comparing names instead of strategy and collation.
+	wasm string
+}
+
+// defaultSortSettings returns the settings used when neither flags nor configuration files set any.
+func defaultSortSettings() sortSettings {
+	return sortSettings{
+		strategy: hclsort.StrategyLexical,
+		order:    hclsort.OrderAscending,
+	}
+}
+
+// with returns a copy of s with every value set in rule applied on top of it.
+func (s sortSettings) with(rule config.SortRule) sortSettings {
+	if rule.Strategy != nil {
+		s.strategy = *rule.Strategy
+	}
+	if rule.Collation != nil {
+		s.collation = *rule.Collation
+	}
+	if rule.IgnoreCase != nil {
+		s.ignoreCase = *rule.IgnoreCase
+	}
+	if rule.Wasm != nil {
+		s.wasm = *rule.Wasm
+	}
+	if rule.Order != nil {
+		s.order = *rule.Order
+	}
+	if rule.First != nil {
+		s.first = rule.First
+	}
+	if rule.Groups != nil {
+		s.groups = rule.Groups
+	}
+	return s
+}
+
+// comparator builds the Comparator described by s, loading WebAssembly sorting rules with loadWasm.
+func (s sortSettings) comparator(loadWasm func(path string) (hclsort.Comparator, error)) (hclsort.Comparator, error) {
+	var (
+		compare hclsort.Comparator
+		err     error
+	)
+	switch {
+	case s.wasm != "":
+		compare, err = loadWasm(s.wasm)
+	case s.collation != "":
+		compare, err = hclsort.CollatorFor(s.collation, s.strategy)
+	default:
+		compare, err = hclsort.ComparatorFor(s.strategy)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.ignoreCase {
+		compare = hclsort.IgnoreCase(compare)
+	}
+
+	switch s.order {
+	case hclsort.OrderAscending:
+	case hclsort.OrderDescending:
+		compare = hclsort.Reverse(compare)
+	default:
+		return nil, fmt.Errorf(
+			"unknown sort order '%s', expected '%s' or '%s'",
+			s.order,
+			hclsort.OrderAscending,
+			hclsort.OrderDescending,
+		)
+	}
+
+	// Buckets and pinned names keep their configured order regardless of the sort order.
+	if len(s.groups) > 0 {
+		patterns := make([]*regexp.Regexp, len(s.groups))
+		for i, group := range s.groups {
+			if patterns[i], err = regexp.Compile(group); err != nil {
+				return nil, fmt.Errorf("invalid group pattern '%s': %w", group, err)
+			}
+		}
+		compare = hclsort.GroupBy(patterns, compare)
+	}
+	if len(s.first) > 0 {
+		compare = hclsort.PinFirst(s.first, compare)
+	}
+	return compare, nil
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+	"github.com/spf13/cobra"
+)
+
+// newSplitCommand returns the split subcommand, which moves the variables, outputs, terraform block
+// and providers of a module into variables.tf, outputs.tf, versions.tf and providers.tf.
+func newSplitCommand(opts *runOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "split [flags] <module directory>",
+		Short: "Move variables, outputs, terraform and provider blocks of a module into their standard files.",
+		Long: "Move the variable, output, terraform and provider blocks of the Terraform files in a module " +
+			"directory into variables.tf, outputs.tf, versions.tf and providers.tf, and sort those files. " +
+			"Files left empty are removed. The changes are printed as unified diffs unless --write is set.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resolver, err := newConfigResolver(cmd, opts)
+			if err != nil {
+				return err
+			}
+			defer resolver.Close()
+			ingestor, err := resolver.ingestorForDir(args[0])
+			if err != nil {
+				return err
+			}
+
+			results, err := ingestor.Split(args[0], hclsort.DefaultSplitFiles)
+			if err != nil {
+				return err
+			}
+			return applyModuleChanges(results, *opts)
+		},
+	}
+}
+
+// applyModuleChanges writes the files of a reorganized module and removes the files left without
+// content when --write is set. Files are written before any is removed, so blocks are never lost when
+// writing fails. Otherwise, like the sorted content of files, the changes are printed to stdout as
+// unified diffs, and --check fails when there are any.
+func applyModuleChanges(results []*hclsort.Result, opts runOptions) error {
+	if !opts.write || opts.diff || opts.dryRun {
+		for _, result := range results {
+			fmt.Print(hclsort.UnifiedDiff(result.Path, result.Original, result.Sorted))
+		}
+		return unsortedError(opts, len(results))
+	}
+
+	for _, result := range results {
+		if result.Sorted == nil {
+			continue
+		}
+		if err := os.WriteFile(result.Path, result.Sorted, 0644); err != nil {
+			return fmt.Errorf("failed to write file '%s': %w", result.Path, err)
+		}
+		verb := "Updated"
+		if result.Original == nil {
+			verb = "Created"
+		}
+		fmt.Printf("%s %s\n", verb, result.Path)
+	}
+	for _, result := range results {
+		if result.Sorted != nil {
+			continue
+		}
+		if err := os.Remove(result.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove file '%s': %w", result.Path, err)
+		}
+		fmt.Printf("Removed %s\n", result.Path)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AlexNabokikh/tfsort/internal/config"
+	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+	"github.com/AlexNabokikh/tfsort/internal/ignore"
+	"github.com/AlexNabokikh/tfsort/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+// newWatchCommand returns the watch subcommand, which sorts the files of a directory tree again every
+// time they change.
+func newWatchCommand(opts *runOptions) *cobra.Command {
+	var delay time.Duration
+	cmd := &cobra.Command{
+		Use:   "watch [flags] <directory>",
+		Short: "Sort the files of a directory tree in place every time they change.",
+		Long: "Watch a directory and its subdirectories, and sort files in place once they stay unchanged " +
+			"for --debounce after being written. Directories are skipped like with --recursive, and " +
+			"configuration and ignore files are read again when they change. With --check, --diff or --list " +
+			"changed files are reported instead of written. Stop watching with Ctrl+C.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := args[0]
+			if stat, err := os.Stat(root); err != nil {
+				return fmt.Errorf("failed to stat path: %w", err)
+			} else if !stat.IsDir() {
+				return fmt.Errorf("'%s' is not a directory", root)
+			}
+			resolver, err := newConfigResolver(cmd, opts)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				// The resolver is replaced when configuration files change.
+				_ = resolver.Close()
+			}()
+
+			watcher, err := watch.New(root, delay, func(path string) bool {
+				if hclsort.IsSkippedDir(filepath.Base(path)) {
+					return true
+				}
+				ignored, ignoreErr := resolver.ignored(path, true)
+				return ignoreErr == nil && ignored
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Watching %s for changes...\n", root)
+
+			err = watcher.Run(cmd.Context(), func(path string) {
+				switch filepath.Base(path) {
+				case config.FileName, ignore.TfsortIgnoreFile, ignore.GitIgnoreFile:
+					reloaded, reloadErr := newConfigResolver(cmd, opts)
+					if reloadErr != nil {
+						fmt.Fprintf(os.Stderr, "Error reloading configuration: %v\n", reloadErr)
+						return
+					}
+					_ = resolver.Close()
+					resolver = reloaded
+					fmt.Fprintf(os.Stderr, "Reloaded configuration after %s changed\n", path)
+					return
+				}
+				if sortErr := sortWatchedFile(cmd, resolver, root, path, *opts); sortErr != nil {
+					fmt.Fprintf(os.Stderr, "Error sorting file %s: %v\n", path, sortErr)
+				}
+			})
+			if cmd.Context().Err() != nil {
+				// Interrupting is how watching stops.
+				return nil
+			}
+			return err
+		},
+	}
+	cmd.Flags().DurationVar(
+		&delay,
+		"debounce",
+		watch.DefaultDelay,
+		"how long files must stay unchanged after being written before they are sorted.",
+	)
+	return cmd
+}
+
+// sortWatchedFile sorts the file at path under root in place when it changed, or reports it in check,
+// diff and list modes. Files that are ignored or not of a sorted type are skipped. Files are only written
+// when sorting changes them, so writing them does not trigger sorting them again.
+func sortWatchedFile(cmd *cobra.Command, resolver *configResolver, root, path string, opts runOptions) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		// Editors write through temporary files that are gone by the time they are sorted.
+		return nil
+	}
+	ignored, err := ignoredUnder(resolver, root, path)
+	if err != nil || ignored {
+		return err
+	}
+	ingestor, err := resolver.ingestorFor(path)
+	if err != nil {
+		return err
+	}
+	if !ingestor.AllowedTypes[hclsort.FileType(path)] || hclsort.IsSkippedFile(filepath.Base(path)) {
+		return nil
+	}
+
+	if opts.inspectOnly() {
+		_, err = processFile(cmd.Context(), resolver, path, opts, false)
+		return err
+	}
+	result, err := ingestor.SortContext(cmd.Context(), path, false)
+	if err != nil || !result.Changed() {
+		return err
+	}
+	if err = os.WriteFile(path, result.Sorted, 0644); err != nil {
+		return fmt.Errorf("failed to write file '%s': %w", path, err)
+	}
+	fmt.Printf("Sorted %s\n", path)
+	return nil
+}
+
+// ignoredUnder reports whether the file at path, or one of its directories below root, is excluded by
+// ignore files. Directories ignored after watching started are still watched, so their files are
+// checked here.
+func ignoredUnder(resolver *configResolver, root, path string) (bool, error) {
+	ignored, err := resolver.ignored(path, false)
+	if err != nil || ignored {
+		return ignored, err
+	}
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil || rel == "." {
+		return false, nil
+	}
+	dir := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, part)
+		if ignored, err = resolver.ignored(dir, true); err != nil || ignored {
+			return ignored, err
+		}
+	}
+	return false, nil
+}
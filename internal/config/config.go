@@ -0,0 +1,559 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsimple"
+)
+
+// FileName is the name of the project configuration file.
+const FileName = ".tfsort.hcl"
+
+// Config holds the settings read from a project configuration file.
+// Unset optional values are nil so callers can tell them apart from explicit false values.
+type Config struct {
+	// Root stops the search for configuration files in parent directories.
+	Root bool `hcl:"root,optional"`
+
+	// SortBlocks lists the top-level block types that are sorted by their labels.
+	SortBlocks []string `hcl:"sort_blocks,optional"`
+	// MinimalMoves moves only the blocks that are out of order, keeping the blank lines around the others.
+	MinimalMoves *bool `hcl:"minimal_moves,optional"`
+	// PreserveFormatting reorders without formatting the sorted files, keeping their original spacing.
+	PreserveFormatting *bool `hcl:"preserve_formatting,optional"`
+	// SortTfvarsValues sorts the keys of the map and object literals assigned in .tfvars files.
+	SortTfvarsValues *bool `hcl:"sort_tfvars_values,optional"`
+	// NormalizeVersions normalizes the spacing and order of version constraints.
+	NormalizeVersions *bool `hcl:"normalize_versions,optional"`
+	// BlockOrder lists top-level block types in the order they are placed in files.
+	BlockOrder []string `hcl:"block_order,optional"`
+	// Ignore lists gitignore-style patterns, relative to Dir, excluded from directory walks.
+	Ignore []string `hcl:"ignore,optional"`
+	// GeneratedPattern is a regular expression matching header comments of generated files.
+	// An empty pattern disables the detection of generated files.
+	GeneratedPattern *string `hcl:"generated_pattern,optional"`
+	// SortStrategy names the strategy used to compare block labels and attribute names.
+	SortStrategy *string `hcl:"sort_strategy,optional"`
+	IgnoreCase   *bool   `hcl:"ignore_case,optional"`
+	// Collation is a locale, such as "da", whose collation rules order names instead of their bytes.
+	Collation *string `hcl:"collation,optional"`
+	// Sections limits sorting to the sections of a file delimited by banner comments.
+	Sections *bool `hcl:"sections,optional"`
+	// GroupByBlankLines limits sorting to groups of blocks that are not separated by blank lines.
+	GroupByBlankLines *bool `hcl:"group_by_blank_lines,optional"`
+	// SectionPattern is a regular expression matching the banner comments that delimit sections.
+	SectionPattern *string `hcl:"section_pattern,optional"`
+	// SortNestedBlocks sorts the nested blocks of resource, data and module bodies by type and labels.
+	SortNestedBlocks *bool `hcl:"sort_nested_blocks,optional"`
+	// SortWorkspaceTags sorts lists of literal tags in the workspaces block of cloud blocks.
+	SortWorkspaceTags *bool `hcl:"sort_workspace_tags,optional"`
+	// SortDependsOn sorts the references in depends_on lists.
+	SortDependsOn *bool `hcl:"sort_depends_on,optional"`
+	// SortMapKeys sorts the keys of map literals assigned to MapAttributes.
+	SortMapKeys *bool `hcl:"sort_map_keys,optional"`
+	// MapAttributes lists the attributes whose map literals are sorted, replacing the defaults.
+	MapAttributes []string `hcl:"map_attributes,optional"`
+	// SortObjectTypes sorts the attributes of object type constraints in the types of variables.
+	SortObjectTypes *bool `hcl:"sort_object_types,optional"`
+	// SortVariableDefaults sorts the keys of the maps and objects in the defaults of variables.
+	SortVariableDefaults *bool `hcl:"sort_variable_defaults,optional"`
+	// LocalsDepth is the number of nesting levels of object literals in locals whose keys are sorted.
+	LocalsDepth *int `hcl:"locals_depth,optional"`
+	// LocalsOrder orders the assignments of locals blocks: "alphabetical" or "topological".
+	LocalsOrder *string `hcl:"locals_order,optional"`
+	// MergeTerraformBlocks consolidates the terraform blocks of a file into one.
+	MergeTerraformBlocks *bool `hcl:"merge_terraform_blocks,optional"`
+	// GroupResources groups sorted resources by "provider" prefix or by "type".
+	GroupResources *string `hcl:"group_resources,optional"`
+	// BlankLines is the number of blank lines between top-level blocks.
+	BlankLines *int `hcl:"blank_lines,optional"`
+	// TypeBlankLines is the number of blank lines between top-level blocks of different types.
+	TypeBlankLines *int `hcl:"type_blank_lines,optional"`
+	// ProviderSource writes provider source addresses without ("short") or with ("qualified") the
+	// registry hostname.
+	ProviderSource *string `hcl:"provider_source,optional"`
+	// Dialect is the dialect of the sorted files: "terraform", "nomad", "vault", "vault-server", "consul",
+	// "waypoint" or "generic".
+	Dialect *string `hcl:"dialect,optional"`
+	// OverrideFiles sorts override files within blocks only ("contents"), leaves them untouched ("skip")
+	// or sorts them like other files ("sort").
+	OverrideFiles *string `hcl:"override_files,optional"`
+	// AlignEquals aligns the equals signs of attributes ("align"), never aligns them ("never") or keeps
+	// them as written ("preserve").
+	AlignEquals *string `hcl:"align_equals,optional"`
+	// SortLists lists attribute paths, such as aws_iam_role.managed_policy_arns, whose lists of
+	// literal strings are sorted.
+	SortLists []string `hcl:"sort_lists,optional"`
+	// SectionHeaders inserts a header comment above the blocks of each sorted block type.
+	SectionHeaders *bool `hcl:"section_headers,optional"`
+	// SectionHeader is the text/template of the header comments, with the fields Type and Title.
+	SectionHeader *string `hcl:"section_header,optional"`
+	// SectionTitles overrides the titles of individual block types in header comments.
+	SectionTitles map[string]string `hcl:"section_titles,optional"`
+	// Sort holds the rules overriding how individual block types are sorted.
+	Sort []*SortRule `hcl:"sort,block"`
+	// Targets holds the sort behaviors applied to attributes and nested blocks at individual paths.
+	Targets []*Target `hcl:"target,block"`
+	// Blocks declares the block types sorted in the generic dialect.
+	Blocks []*Block `hcl:"block,block"`
+	// Plugins declares external programs sorting block types or file types.
+	Plugins []*Plugin `hcl:"plugin,block"`
+
+	Write     *bool `hcl:"write,optional"`
+	Diff      *bool `hcl:"diff,optional"`
+	List      *bool `hcl:"list,optional"`
+	Recursive *bool `hcl:"recursive,optional"`
+	Gitignore *bool `hcl:"gitignore,optional"`
+
+	// Path is the file the configuration was loaded from, empty when no file was found.
+	// For merged configurations it is the nearest file.
+	Path string
+	// Dir is the directory containing Path.
+	Dir string
+	// Sources lists the files a merged configuration was built from, outermost first.
+	Sources []*Config
+}
+
+// SortRule overrides the sort settings for a single block type, e.g.:
+//
+//	sort "variable" {
+//	  first = ["environment", "region"]
+//	  order = "descending"
+//	}
+type SortRule struct {
+	BlockType  string  `hcl:"type,label"`
+	Order      *string `hcl:"order,optional"`
+	Strategy   *string `hcl:"strategy,optional"`
+	IgnoreCase *bool   `hcl:"ignore_case,optional"`
+	Collation  *string `hcl:"collation,optional"`
+	// Wasm is the path to a WebAssembly module comparing names instead of the strategy and collation,
+	// relative to the directory of the configuration file.
+	Wasm *string `hcl:"wasm,optional"`
+	// First lists names that sort before all others, in the given order.
+	First []string `hcl:"first,optional"`
+	// Groups lists regular expressions defining buckets of names, sorted in the given order.
+	// Names matching none of them sort after all buckets.
+	Groups []string `hcl:"groups,optional"`
+	// Key is an expression computing the sort key of each block, evaluated when files are sorted.
+	// It is nil when the rule has no key.
+	Key hcl.Expression `hcl:"key,optional"`
+}
+
+// Target applies sort behaviors to the attributes or nested blocks at a path within top-level blocks,
+// e.g.:
+//
+//	target "resource" {
+//	  label   = "^aws_security_group$"
+//	  path    = "ingress"
+//	  sort_by = "description"
+//	}
+type Target struct {
+	BlockType string `hcl:"type,label"`
+	// Label is a regular expression matching the first label of the targeted blocks.
+	Label *string `hcl:"label,optional"`
+	// Path lists the dot-separated types of nested blocks leading to the targeted attributes or nested
+	// blocks, followed by their name, e.g. "ingress.cidr_blocks". It is empty for the blocks themselves.
+	Path string `hcl:"path,optional"`
+	// SortKeys sorts the keys of the map literals assigned to the targeted attributes.
+	SortKeys bool `hcl:"sort_keys,optional"`
+	// SortElements sorts the targeted attributes' lists of literal strings.
+	SortElements bool `hcl:"sort_elements,optional"`
+	// SortBy names the attribute the targeted nested blocks are sorted by.
+	SortBy *string `hcl:"sort_by,optional"`
+	// Ignore leaves the targeted items untouched.
+	Ignore bool `hcl:"ignore,optional"`
+}
+
+// Block declares how the blocks of a type are sorted in the generic dialect, e.g.:
+//
+//	block "stage" {
+//	  key_labels = [0]
+//	  pinned     = ["name"]
+//	}
+type Block struct {
+	BlockType string `hcl:"type,label"`
+	// KeyLabels lists the indexes of the labels the blocks are sorted by, the first label when omitted.
+	// An empty list keeps the blocks in place and only sorts their attributes.
+	KeyLabels []int `hcl:"key_labels,optional"`
+	// Pinned lists the attributes that keep their positions in the bodies of the blocks.
+	Pinned []string `hcl:"pinned,optional"`
+}
+
+// Plugin declares an external program sorting the contents of block types or whole files of some
+// types, e.g.:
+//
+//	plugin "acme" {
+//	  command     = ["./bin/tfsort-acme", "--strict"]
+//	  block_types = ["widget"]
+//	  file_types  = ["acme"]
+//	}
+type Plugin struct {
+	Name string `hcl:"name,label"`
+	// Command holds the program run and its arguments. Relative paths to programs, such as
+	// "./bin/tfsort-acme", are resolved against the directory of the configuration file.
+	Command    []string `hcl:"command"`
+	BlockTypes []string `hcl:"block_types,optional"`
+	FileTypes  []string `hcl:"file_types,optional"`
+	// Dir is the directory of the configuration file declaring the plugin.
+	Dir string
+}
+
+// Loader finds, loads and merges the configuration files applying to directories.
+// Results are cached, so a Loader should be reused across a single run.
+type Loader struct {
+	files  map[string]*Config
+	merged map[string]*Config
+}
+
+// NewLoader returns a new Loader with empty caches.
+func NewLoader() *Loader {
+	return &Loader{
+		files:  map[string]*Config{},
+		merged: map[string]*Config{},
+	}
+}
+
+// Load parses the configuration file at path.
+func Load(path string) (*Config, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file '%s': %w", path, err)
+	}
+
+	cfg := &Config{}
+	// The syntax is picked from the extension, so "*.json" files are decoded as HCL's JSON variant.
+	if err = hclsimple.Decode(path, src, nil, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing config file '%s': %w", path, err)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving config file '%s': %w", path, err)
+	}
+	cfg.Path = absPath
+	cfg.Dir = filepath.Dir(absPath)
+	for _, rule := range cfg.Sort {
+		rule.Key = nilIfAbsent(rule.Key)
+		if rule.Wasm != nil && *rule.Wasm != "" && !filepath.IsAbs(*rule.Wasm) {
+			wasm := filepath.Join(cfg.Dir, *rule.Wasm)
+			rule.Wasm = &wasm
+		}
+	}
+	for _, plugin := range cfg.Plugins {
+		plugin.Dir = cfg.Dir
+	}
+
+	if err = cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file '%s': %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// nilIfAbsent returns nil for the placeholder expression decoded for an omitted optional attribute.
+func nilIfAbsent(expr hcl.Expression) hcl.Expression {
+	if expr == nil {
+		return nil
+	}
+	if value, diags := expr.Value(nil); !diags.HasErrors() && value.IsNull() {
+		return nil
+	}
+	return expr
+}
+
+// ForDir returns the configuration applying to dir.
+// Configuration files from the outermost directory (the filesystem root or the nearest
+// file with root = true) down to dir are merged in order, so nearer files override
+// the settings of outer ones.
+func (l *Loader) ForDir(dir string) (*Config, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving directory '%s': %w", dir, err)
+	}
+
+	return l.forAbsDir(absDir)
+}
+
+// forAbsDir implements ForDir for an absolute directory path.
+func (l *Loader) forAbsDir(dir string) (*Config, error) {
+	if cached, ok := l.merged[dir]; ok {
+		return cached, nil
+	}
+
+	file, err := l.fileIn(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := &Config{}
+	if parentDir := filepath.Dir(dir); parentDir != dir && (file == nil || !file.Root) {
+		if parent, err = l.forAbsDir(parentDir); err != nil {
+			return nil, err
+		}
+	}
+
+	merged := parent
+	if file != nil {
+		merged = parent.merge(file)
+	}
+
+	l.merged[dir] = merged
+	return merged, nil
+}
+
+// fileIn loads the configuration file located directly in dir, or returns nil if there is none.
+func (l *Loader) fileIn(dir string) (*Config, error) {
+	if cached, ok := l.files[dir]; ok {
+		return cached, nil
+	}
+
+	var file *Config
+	candidate := filepath.Join(dir, FileName)
+	_, statErr := os.Stat(candidate)
+	switch {
+	case statErr == nil:
+		loaded, err := Load(candidate)
+		if err != nil {
+			return nil, err
+		}
+		file = loaded
+	case !errors.Is(statErr, fs.ErrNotExist):
+		return nil, fmt.Errorf("error accessing config file '%s': %w", candidate, statErr)
+	}
+
+	l.files[dir] = file
+	return file, nil
+}
+
+// merge returns a new configuration with the settings of child applied on top of c.
+// Lists set in child replace those of c rather than being appended to them.
+func (c *Config) merge(child *Config) *Config {
+	merged := *c
+	merged.Root = child.Root
+	merged.Path = child.Path
+	merged.Dir = child.Dir
+	merged.Ignore = child.Ignore
+	merged.Sources = append(append([]*Config(nil), c.Sources...), child)
+	// Sort rules from child replace those of c for the same block type as a whole, key included, so
+	// settings a child rule omits fall back to the top-level settings rather than to the parent rule.
+	overridden := make(map[string]bool, len(child.Sort))
+	for _, rule := range child.Sort {
+		overridden[rule.BlockType] = true
+	}
+	merged.Sort = nil
+	for _, rule := range c.Sort {
+		if !overridden[rule.BlockType] {
+			merged.Sort = append(merged.Sort, rule)
+		}
+	}
+	merged.Sort = append(merged.Sort, child.Sort...)
+	merged.Targets = append(append([]*Target(nil), c.Targets...), child.Targets...)
+	// Block schemas are resolved in order too, so those from child override those of c.
+	merged.Blocks = append(append([]*Block(nil), c.Blocks...), child.Blocks...)
+	// Plugins are resolved in order too, so those from child replace those of c with the same name.
+	merged.Plugins = append(append([]*Plugin(nil), c.Plugins...), child.Plugins...)
+
+	if child.SortBlocks != nil {
+		merged.SortBlocks = child.SortBlocks
+	}
+	if child.BlockOrder != nil {
+		merged.BlockOrder = child.BlockOrder
+	}
+	if child.GeneratedPattern != nil {
+		merged.GeneratedPattern = child.GeneratedPattern
+	}
+	if child.SectionPattern != nil {
+		merged.SectionPattern = child.SectionPattern
+	}
+	if child.SectionHeader != nil {
+		merged.SectionHeader = child.SectionHeader
+	}
+	if child.LocalsDepth != nil {
+		merged.LocalsDepth = child.LocalsDepth
+	}
+	if child.LocalsOrder != nil {
+		merged.LocalsOrder = child.LocalsOrder
+	}
+	if child.GroupResources != nil {
+		merged.GroupResources = child.GroupResources
+	}
+	if child.BlankLines != nil {
+		merged.BlankLines = child.BlankLines
+	}
+	if child.TypeBlankLines != nil {
+		merged.TypeBlankLines = child.TypeBlankLines
+	}
+	if child.ProviderSource != nil {
+		merged.ProviderSource = child.ProviderSource
+	}
+	if child.Dialect != nil {
+		merged.Dialect = child.Dialect
+	}
+	if child.OverrideFiles != nil {
+		merged.OverrideFiles = child.OverrideFiles
+	}
+	if child.AlignEquals != nil {
+		merged.AlignEquals = child.AlignEquals
+	}
+	if child.SortLists != nil {
+		merged.SortLists = child.SortLists
+	}
+	if child.MapAttributes != nil {
+		merged.MapAttributes = child.MapAttributes
+	}
+	if child.SectionTitles != nil {
+		merged.SectionTitles = child.SectionTitles
+	}
+	if child.SortStrategy != nil {
+		merged.SortStrategy = child.SortStrategy
+	}
+	if child.Collation != nil {
+		merged.Collation = child.Collation
+	}
+	mergeBool(&merged.IgnoreCase, child.IgnoreCase)
+	mergeBool(&merged.Sections, child.Sections)
+	mergeBool(&merged.GroupByBlankLines, child.GroupByBlankLines)
+	mergeBool(&merged.MinimalMoves, child.MinimalMoves)
+	mergeBool(&merged.PreserveFormatting, child.PreserveFormatting)
+	mergeBool(&merged.SortTfvarsValues, child.SortTfvarsValues)
+	mergeBool(&merged.NormalizeVersions, child.NormalizeVersions)
+	mergeBool(&merged.SectionHeaders, child.SectionHeaders)
+	mergeBool(&merged.SortNestedBlocks, child.SortNestedBlocks)
+	mergeBool(&merged.SortWorkspaceTags, child.SortWorkspaceTags)
+	mergeBool(&merged.SortDependsOn, child.SortDependsOn)
+	mergeBool(&merged.SortMapKeys, child.SortMapKeys)
+	mergeBool(&merged.SortObjectTypes, child.SortObjectTypes)
+	mergeBool(&merged.SortVariableDefaults, child.SortVariableDefaults)
+	mergeBool(&merged.MergeTerraformBlocks, child.MergeTerraformBlocks)
+	mergeBool(&merged.Write, child.Write)
+	mergeBool(&merged.Diff, child.Diff)
+	mergeBool(&merged.List, child.List)
+	mergeBool(&merged.Recursive, child.Recursive)
+	mergeBool(&merged.Gitignore, child.Gitignore)
+
+	return &merged
+}
+
+// mergeBool overrides target with value when value is set.
+func mergeBool(target **bool, value *bool) {
+	if value != nil {
+		*target = value
+	}
+}
+
+// validate checks the configuration for values that cannot be applied.
+func (c *Config) validate() error {
+	for _, blockType := range c.SortBlocks {
+		if blockType == "" {
+			return errors.New("sort_blocks must not contain empty block types")
+		}
+	}
+	if slices.Contains(c.BlockOrder, "") {
+		return errors.New("block_order must not contain empty block types")
+	}
+	for _, path := range c.SortLists {
+		if slices.Contains(strings.Split(path, "."), "") {
+			return fmt.Errorf(
+				"sort_lists must contain attribute paths such as 'resource_type.attribute', got '%s'",
+				path,
+			)
+		}
+	}
+	for _, rule := range c.Sort {
+		if rule.Wasm != nil && (rule.Strategy != nil || rule.Collation != nil) {
+			return fmt.Errorf(
+				"wasm of the '%s' sort rule cannot be combined with strategy or collation",
+				rule.BlockType,
+			)
+		}
+		for _, group := range rule.Groups {
+			if _, err := regexp.Compile(group); err != nil {
+				return fmt.Errorf(
+					"groups of the '%s' sort rule must be valid regular expressions: %w",
+					rule.BlockType,
+					err,
+				)
+			}
+		}
+	}
+	if c.LocalsDepth != nil && *c.LocalsDepth < 0 {
+		return errors.New("locals_depth must not be negative")
+	}
+	if c.BlankLines != nil && *c.BlankLines < 1 {
+		return errors.New("blank_lines must be at least 1")
+	}
+	if c.TypeBlankLines != nil && *c.TypeBlankLines < 1 {
+		return errors.New("type_blank_lines must be at least 1")
+	}
+	for _, target := range c.Targets {
+		if err := target.validate(); err != nil {
+			return err
+		}
+	}
+	for _, block := range c.Blocks {
+		if slices.ContainsFunc(block.KeyLabels, func(index int) bool { return index < 0 }) {
+			return fmt.Errorf("key_labels of the '%s' block must not be negative", block.BlockType)
+		}
+	}
+	for _, plugin := range c.Plugins {
+		if len(plugin.Command) == 0 || plugin.Command[0] == "" {
+			return fmt.Errorf("command of the '%s' plugin must name a program", plugin.Name)
+		}
+		if len(plugin.BlockTypes) == 0 && len(plugin.FileTypes) == 0 {
+			return fmt.Errorf("the '%s' plugin must set block_types or file_types", plugin.Name)
+		}
+	}
+	if c.GeneratedPattern != nil {
+		if _, err := regexp.Compile(*c.GeneratedPattern); err != nil {
+			return fmt.Errorf("generated_pattern is not a valid regular expression: %w", err)
+		}
+	}
+	if c.SectionPattern != nil {
+		if *c.SectionPattern == "" {
+			return errors.New("section_pattern must not be empty")
+		}
+		if _, err := regexp.Compile(*c.SectionPattern); err != nil {
+			return fmt.Errorf("section_pattern is not a valid regular expression: %w", err)
+		}
+	}
+	if c.SectionHeader != nil {
+		if _, err := template.New("section_header").Parse(*c.SectionHeader); err != nil {
+			return fmt.Errorf("section_header is not a valid template: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validate checks the target for paths and combinations of behaviors that cannot be applied.
+func (t *Target) validate() error {
+	if t.Label != nil {
+		if _, err := regexp.Compile(*t.Label); err != nil {
+			return fmt.Errorf("label of the '%s' target must be a valid regular expression: %w", t.BlockType, err)
+		}
+	}
+	if t.Path != "" && slices.Contains(strings.Split(t.Path, "."), "") {
+		return fmt.Errorf("path of the '%s' target contains an empty segment: '%s'", t.BlockType, t.Path)
+	}
+	sorted := t.SortKeys || t.SortElements || t.SortBy != nil
+	switch {
+	case t.Ignore && sorted:
+		return fmt.Errorf("the '%s' target must not both ignore and sort its items", t.BlockType)
+	case !t.Ignore && !sorted:
+		return fmt.Errorf("the '%s' target must set sort_keys, sort_elements, sort_by or ignore", t.BlockType)
+	case sorted && t.Path == "":
+		return fmt.Errorf("the '%s' target must set a path to sort", t.BlockType)
+	case t.SortBy != nil && *t.SortBy == "":
+		return fmt.Errorf("sort_by of the '%s' target must not be empty", t.BlockType)
+	}
+	return nil
+}
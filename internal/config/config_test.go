@@ -0,0 +1,490 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AlexNabokikh/tfsort/internal/config"
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory %s: %v", dir, err)
+	}
+	path := filepath.Join(dir, config.FileName)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Valid configuration", func(t *testing.T) {
+		dir := t.TempDir()
+		path := writeConfig(t, dir, `
+sort_blocks = ["variable", "output", "locals"]
+ignore      = ["examples/"]
+write       = true
+gitignore   = false
+`)
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("Load failed unexpectedly: %v", err)
+		}
+
+		if diff := cmp.Diff([]string{"variable", "output", "locals"}, cfg.SortBlocks); diff != "" {
+			t.Errorf("Unexpected sort_blocks:\n%s", diff)
+		}
+		if diff := cmp.Diff([]string{"examples/"}, cfg.Ignore); diff != "" {
+			t.Errorf("Unexpected ignore:\n%s", diff)
+		}
+		if cfg.Write == nil || !*cfg.Write {
+			t.Errorf("Expected write to be true, got %v", cfg.Write)
+		}
+		if cfg.Gitignore == nil || *cfg.Gitignore {
+			t.Errorf("Expected gitignore to be false, got %v", cfg.Gitignore)
+		}
+		if cfg.Diff != nil {
+			t.Errorf("Expected unset diff to be nil, got %v", *cfg.Diff)
+		}
+		if cfg.Dir != dir {
+			t.Errorf("Expected Dir to be %s, got %s", dir, cfg.Dir)
+		}
+	})
+
+	t.Run("Sort rules", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `
+sort "output" {
+  order    = "descending"
+  strategy = "natural"
+  first    = ["id"]
+}
+`)
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("Load failed unexpectedly: %v", err)
+		}
+		if len(cfg.Sort) != 1 || cfg.Sort[0].BlockType != "output" {
+			t.Fatalf("Expected a single sort rule for outputs, got %+v", cfg.Sort)
+		}
+		if cfg.Sort[0].Order == nil || *cfg.Sort[0].Order != "descending" {
+			t.Errorf("Expected descending order, got %v", cfg.Sort[0].Order)
+		}
+		if diff := cmp.Diff([]string{"id"}, cfg.Sort[0].First); diff != "" {
+			t.Errorf("Unexpected first:\n%s", diff)
+		}
+		if cfg.Sort[0].Key != nil {
+			t.Errorf("Expected omitted key to be nil, got %v", cfg.Sort[0].Key)
+		}
+		if cfg.Sort[0].IgnoreCase != nil {
+			t.Errorf("Expected unset ignore_case to be nil, got %v", *cfg.Sort[0].IgnoreCase)
+		}
+	})
+
+	t.Run("Sort key expression", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `
+sort "resource" {
+  key = [contains(attributes, "count") ? 0 : 1, labels[0]]
+}
+`)
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("Load failed unexpectedly: %v", err)
+		}
+		if len(cfg.Sort) != 1 || cfg.Sort[0].Key == nil {
+			t.Fatalf("Expected a sort rule with a key expression, got %+v", cfg.Sort)
+		}
+	})
+
+	t.Run("Unknown attribute", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `unknown = true`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "error parsing config file") {
+			t.Errorf("Expected parsing error, got: %v", err)
+		}
+	})
+
+	t.Run("Invalid generated pattern", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `generated_pattern = "Code generated ("`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "generated_pattern") {
+			t.Errorf("Expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("Invalid section pattern", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `section_pattern = "^### ("`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "section_pattern") {
+			t.Errorf("Expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("Invalid section header", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `section_header = "# {{.Title"`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "section_header") {
+			t.Errorf("Expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("Invalid list path", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `sort_lists = ["aws_instance..security_groups"]`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "sort_lists") {
+			t.Errorf("Expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("Negative locals depth", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `locals_depth = -1`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "locals_depth") {
+			t.Errorf("Expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("Too few blank lines", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `blank_lines = 0`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "blank_lines") {
+			t.Errorf("Expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("Targets", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `
+target "resource" {
+  label   = "^aws_security_group$"
+  path    = "ingress"
+  sort_by = "description"
+}
+`)
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("Load failed unexpectedly: %v", err)
+		}
+		if len(cfg.Targets) != 1 || cfg.Targets[0].Path != "ingress" || cfg.Targets[0].SortBy == nil {
+			t.Errorf("Expected a target sorting ingress blocks, got %+v", cfg.Targets)
+		}
+	})
+
+	t.Run("Target without behavior", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `
+target "resource" {
+  path = "tags"
+}
+`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "'resource' target") {
+			t.Errorf("Expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("Block schemas", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `
+block "stage" {
+  pinned = ["name"]
+}
+
+block "step" {
+  key_labels = []
+}
+`)
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("Load failed unexpectedly: %v", err)
+		}
+		if len(cfg.Blocks) != 2 || cfg.Blocks[0].KeyLabels != nil || cfg.Blocks[1].KeyLabels == nil {
+			t.Errorf("Expected an omitted and an empty list of key labels, got %+v", cfg.Blocks)
+		}
+	})
+
+	t.Run("Negative key labels", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `
+block "stage" {
+  key_labels = [-1]
+}
+`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "key_labels of the 'stage' block") {
+			t.Errorf("Expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("Plugins", func(t *testing.T) {
+		dir := t.TempDir()
+		path := writeConfig(t, dir, `
+plugin "acme" {
+  command     = ["./bin/tfsort-acme", "--strict"]
+  block_types = ["widget"]
+}
+`)
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("Load failed unexpectedly: %v", err)
+		}
+		if len(cfg.Plugins) != 1 || cfg.Plugins[0].Name != "acme" || cfg.Plugins[0].Dir != cfg.Dir {
+			t.Errorf("Expected the acme plugin declared in %s, got %+v", cfg.Dir, cfg.Plugins)
+		}
+	})
+
+	t.Run("Plugin without claims", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `
+plugin "acme" {
+  command = ["tfsort-acme"]
+}
+`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "the 'acme' plugin must set block_types or file_types") {
+			t.Errorf("Expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("Wasm sort rule", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `
+sort "variable" {
+  wasm = "rules/order.wasm"
+}
+`)
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("Load failed unexpectedly: %v", err)
+		}
+		if want := filepath.Join(cfg.Dir, "rules", "order.wasm"); *cfg.Sort[0].Wasm != want {
+			t.Errorf("Expected the module path %s, got %s", want, *cfg.Sort[0].Wasm)
+		}
+	})
+
+	t.Run("Wasm sort rule with strategy", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `
+sort "variable" {
+  wasm     = "rules/order.wasm"
+  strategy = "natural"
+}
+`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "cannot be combined with strategy or collation") {
+			t.Errorf("Expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("Invalid sort rule group", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `
+sort "resource" {
+  groups = ["^aws_iam_", "^aws_(s3"]
+}
+`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "groups of the 'resource' sort rule") {
+			t.Errorf("Expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("Empty block type", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `sort_blocks = ["variable", ""]`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "invalid config file") {
+			t.Errorf("Expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("Empty block type in block order", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), `block_order = ["terraform", ""]`)
+
+		_, err := config.Load(path)
+		if err == nil || !strings.Contains(err.Error(), "block_order") {
+			t.Errorf("Expected validation error, got: %v", err)
+		}
+	})
+}
+
+func TestLoaderForDirDiscovery(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, `sort_blocks = ["output"]`)
+	nested := filepath.Join(root, "modules", "network")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Failed to create directory %s: %v", nested, err)
+	}
+
+	t.Run("Configuration found in a parent directory", func(t *testing.T) {
+		cfg, err := config.NewLoader().ForDir(nested)
+		if err != nil {
+			t.Fatalf("ForDir failed unexpectedly: %v", err)
+		}
+		if cfg.Path != filepath.Join(root, config.FileName) {
+			t.Errorf("Expected configuration from %s, got %s", root, cfg.Path)
+		}
+		if diff := cmp.Diff([]string{"output"}, cfg.SortBlocks); diff != "" {
+			t.Errorf("Unexpected sort_blocks:\n%s", diff)
+		}
+	})
+
+	t.Run("No configuration file", func(t *testing.T) {
+		cfg, err := config.NewLoader().ForDir(filepath.Join(t.TempDir(), "empty"))
+		if err != nil {
+			t.Fatalf("ForDir failed unexpectedly: %v", err)
+		}
+		if len(cfg.Sources) > 0 {
+			t.Skipf("Skipping: a %s exists in a parent of the temporary directory (%s)", config.FileName, cfg.Path)
+		}
+		if cfg.Path != "" || len(cfg.SortBlocks) != 0 {
+			t.Errorf("Expected an empty configuration, got %+v", cfg)
+		}
+	})
+}
+
+func TestLoaderForDirSortRules(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, `
+sort "resource" {
+  order = "descending"
+  key   = [contains(attributes, "count") ? 0 : 1, labels[0]]
+}
+
+sort "output" {
+  order = "descending"
+}
+`)
+	nested := filepath.Join(root, "modules", "network")
+	writeConfig(t, nested, `
+sort "resource" {
+  first = ["aws_vpc"]
+}
+`)
+
+	cfg, err := config.NewLoader().ForDir(nested)
+	if err != nil {
+		t.Fatalf("ForDir failed unexpectedly: %v", err)
+	}
+	if len(cfg.Sort) != 2 || cfg.Sort[0].BlockType != "output" || cfg.Sort[1].BlockType != "resource" {
+		t.Fatalf("Expected the nested resource rule to replace the parent one, got %+v", cfg.Sort)
+	}
+	// Nothing of the parent rule is inherited, not even its key.
+	rule := cfg.Sort[1]
+	if rule.Key != nil || rule.Order != nil {
+		t.Errorf("Expected the settings of the parent rule to be dropped, got key=%v order=%v", rule.Key, rule.Order)
+	}
+	if diff := cmp.Diff([]string{"aws_vpc"}, rule.First); diff != "" {
+		t.Errorf("Unexpected first:\n%s", diff)
+	}
+}
+
+func TestLoaderForDir(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, `
+sort_blocks = ["variable", "output"]
+write       = true
+ignore      = ["examples/"]
+
+sort "output" {
+  order = "descending"
+}
+`)
+	legacy := filepath.Join(root, "modules", "legacy")
+	writeConfig(t, legacy, `
+sort_blocks = []
+ignore      = ["old.tf"]
+
+sort "locals" {
+  order = "descending"
+}
+`)
+	isolated := filepath.Join(root, "modules", "isolated")
+	writeConfig(t, isolated, `
+root = true
+diff = true
+`)
+	plain := filepath.Join(root, "modules", "plain")
+	if err := os.MkdirAll(plain, 0755); err != nil {
+		t.Fatalf("Failed to create directory %s: %v", plain, err)
+	}
+
+	loader := config.NewLoader()
+
+	t.Run("Nested configuration overrides parent settings", func(t *testing.T) {
+		cfg, err := loader.ForDir(legacy)
+		if err != nil {
+			t.Fatalf("ForDir failed unexpectedly: %v", err)
+		}
+		if cfg.SortBlocks == nil || len(cfg.SortBlocks) != 0 {
+			t.Errorf("Expected sort_blocks to be overridden with an empty list, got %v", cfg.SortBlocks)
+		}
+		if cfg.Write == nil || !*cfg.Write {
+			t.Errorf("Expected write to be inherited from the parent, got %v", cfg.Write)
+		}
+		if len(cfg.Sort) != 2 || cfg.Sort[0].BlockType != "output" || cfg.Sort[1].BlockType != "locals" {
+			t.Errorf("Expected sort rules to be combined from root to leaf, got %+v", cfg.Sort)
+		}
+		if len(cfg.Sources) != 2 || cfg.Sources[0].Dir != root || cfg.Sources[1].Dir != legacy {
+			t.Errorf("Expected sources to be ordered from root to leaf, got %+v", cfg.Sources)
+		}
+	})
+
+	t.Run("Directory without configuration inherits from its parents", func(t *testing.T) {
+		cfg, err := loader.ForDir(plain)
+		if err != nil {
+			t.Fatalf("ForDir failed unexpectedly: %v", err)
+		}
+		if diff := cmp.Diff([]string{"variable", "output"}, cfg.SortBlocks); diff != "" {
+			t.Errorf("Unexpected sort_blocks:\n%s", diff)
+		}
+		if cfg.Dir != root {
+			t.Errorf("Expected nearest configuration directory %s, got %s", root, cfg.Dir)
+		}
+	})
+
+	t.Run("Root configuration stops the upward search", func(t *testing.T) {
+		cfg, err := loader.ForDir(isolated)
+		if err != nil {
+			t.Fatalf("ForDir failed unexpectedly: %v", err)
+		}
+		if cfg.SortBlocks != nil || cfg.Write != nil {
+			t.Errorf("Expected parent settings to be ignored, got sort_blocks=%v write=%v", cfg.SortBlocks, cfg.Write)
+		}
+		if cfg.Diff == nil || !*cfg.Diff {
+			t.Errorf("Expected diff to be true, got %v", cfg.Diff)
+		}
+		if len(cfg.Sources) != 1 {
+			t.Errorf("Expected a single source, got %d", len(cfg.Sources))
+		}
+	})
+
+	t.Run("Results are cached", func(t *testing.T) {
+		first, err := loader.ForDir(legacy)
+		if err != nil {
+			t.Fatalf("ForDir failed unexpectedly: %v", err)
+		}
+		second, err := loader.ForDir(legacy)
+		if err != nil {
+			t.Fatalf("ForDir failed unexpectedly: %v", err)
+		}
+		if first != second {
+			t.Error("Expected the same configuration instance for repeated lookups")
+		}
+	})
+}
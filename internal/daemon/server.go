@@ -0,0 +1,366 @@
+// Package daemon implements a long-running server answering sort and check requests over a unix
+// socket with JSON-RPC 2.0, keeping configuration and sorted files in memory between requests.
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+)
+
+// Error codes of JSON-RPC.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// maxMessageSize is the size of the largest request read from a connection.
+const maxMessageSize = 64 << 20
+
+// Backend sorts files for a Server. Its methods are never called concurrently.
+type Backend interface {
+	// Sort sorts src, the content of the file at path.
+	Sort(ctx context.Context, path string, src []byte) (*hclsort.Result, error)
+	// Files returns the files to sort under path: path itself when it names a file, or the sortable
+	// files of the directory tree it names.
+	Files(ctx context.Context, path string) ([]string, error)
+	// Reload discards the configuration loaded by earlier calls.
+	Reload() error
+}
+
+// Server answers the requests of the clients connected to a unix socket. Sorted files are cached by
+// path, so files that did not change since they were last sorted are not parsed again.
+type Server struct {
+	backend Backend
+
+	// mu serializes the calls to backend and guards results.
+	mu      sync.Mutex
+	results map[string]*hclsort.Result
+
+	// stop stops Serve after a shutdown request.
+	stop context.CancelFunc
+}
+
+// NewServer returns a Server sorting files with backend.
+func NewServer(backend Backend) *Server {
+	return &Server{backend: backend, results: map[string]*hclsort.Result{}}
+}
+
+// message is a JSON-RPC request or response.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+// responseError is the error of a JSON-RPC response.
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements error.
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+// SortParams are the parameters of the sort method.
+type SortParams struct {
+	// Path is the path of the file to sort. It selects how the file is sorted and which configuration
+	// applies to it.
+	Path string `json:"path"`
+	// Source is the content to sort instead of the content of the file at Path.
+	Source *string `json:"source,omitempty"`
+	// Write writes the sorted content back to the file at Path when it changed.
+	Write bool `json:"write,omitempty"`
+}
+
+// SortResult is the result of the sort method.
+type SortResult struct {
+	Path    string `json:"path"`
+	Sorted  string `json:"sorted"`
+	Changed bool   `json:"changed"`
+	Skipped bool   `json:"skipped"`
+}
+
+// CheckParams are the parameters of the check method.
+type CheckParams struct {
+	// Paths lists the files and directories to check; directories are walked recursively.
+	Paths []string `json:"paths"`
+}
+
+// CheckResult is the result of the check method.
+type CheckResult struct {
+	// Unsorted lists the files that would be changed by sorting them.
+	Unsorted []string `json:"unsorted"`
+	// Errors lists the files that could not be checked.
+	Errors []FileError `json:"errors"`
+}
+
+// FileError is an error checking a file.
+type FileError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Listen listens on the unix socket at path, replacing a socket left behind by a daemon that is no
+// longer running. It fails when another daemon is listening on path. Only the current user can
+// connect to the socket, and the caller removes it once the listener is closed.
+func Listen(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on '%s'", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket '%s': %w", path, err)
+	}
+
+	// The socket is created in a directory only the current user can enter, and moved to path once its
+	// permissions are restricted, so no other user can connect in between.
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".tfsort-daemon-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	private := filepath.Join(dir, "socket")
+
+	listener, err := net.Listen("unix", private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on '%s': %w", path, err)
+	}
+	if unixListener, ok := listener.(*net.UnixListener); ok {
+		// The socket is removed from path by the caller.
+		unixListener.SetUnlinkOnClose(false)
+	}
+	if err = os.Chmod(private, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict access to '%s': %w", path, err)
+	}
+	if err = os.Rename(private, path); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to move socket to '%s': %w", path, err)
+	}
+	return listener, nil
+}
+
+// Serve answers the requests of the clients connecting to listener until ctx is done or a client
+// requests a shutdown, then closes listener.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	ctx, s.stop = context.WithCancel(ctx)
+	defer s.stop()
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var connections sync.WaitGroup
+	defer connections.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+		connections.Add(1)
+		go func() {
+			defer connections.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// serveConn answers the requests read from conn, one JSON message per line, until the client closes
+// it or ctx is done.
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		response, ok := s.respond(ctx, line)
+		if !ok {
+			continue
+		}
+		response.JSONRPC = "2.0"
+		if err := encoder.Encode(response); err != nil {
+			return
+		}
+	}
+}
+
+// respond returns the response to the request encoded in line, or false for notifications, which are
+// never answered.
+func (s *Server) respond(ctx context.Context, line []byte) (message, bool) {
+	var request message
+	if err := json.Unmarshal(line, &request); err != nil {
+		null := json.RawMessage("null")
+		return message{ID: &null, Error: &responseError{Code: codeParseError, Message: err.Error()}}, true
+	}
+
+	result, err := s.handle(ctx, request.Method, request.Params)
+	if request.ID == nil {
+		return message{}, false
+	}
+	if err != nil {
+		var rpcErr *responseError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &responseError{Code: codeInternalError, Message: err.Error()}
+		}
+		return message{ID: request.ID, Error: rpcErr}, true
+	}
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	return message{ID: request.ID, Result: result}, true
+}
+
+// handle returns the result of the request method with params.
+func (s *Server) handle(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "sort":
+		var p SortParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Path == "" {
+			return nil, &responseError{Code: codeInvalidParams, Message: "path is required"}
+		}
+		return s.sort(ctx, p)
+	case "check":
+		var p CheckParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.check(ctx, p.Paths)
+	case "reload":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		clear(s.results)
+		return nil, s.backend.Reload()
+	case "shutdown":
+		s.stop()
+		return nil, nil
+	default:
+		if method == "" {
+			return nil, &responseError{Code: codeInvalidRequest, Message: "method is required"}
+		}
+		unsupported := fmt.Sprintf("method '%s' is not supported", method)
+		return nil, &responseError{Code: codeMethodNotFound, Message: unsupported}
+	}
+}
+
+// decodeParams decodes the params of a request into target.
+func decodeParams(params json.RawMessage, target any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, target); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// sort answers a sort request.
+func (s *Server) sort(ctx context.Context, p SortParams) (*SortResult, error) {
+	var src []byte
+	if p.Source != nil {
+		src = []byte(*p.Source)
+	} else {
+		var err error
+		if src, err = hclsort.ReadFileBytes(p.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := s.sortSource(ctx, p.Path, src)
+	if err != nil {
+		return nil, err
+	}
+	if p.Write && result.Changed() {
+		if err = os.WriteFile(p.Path, result.Sorted, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write file '%s': %w", p.Path, err)
+		}
+	}
+	return &SortResult{
+		Path:    p.Path,
+		Sorted:  string(result.Sorted),
+		Changed: result.Changed(),
+		Skipped: result.Skipped,
+	}, nil
+}
+
+// check answers a check request.
+func (s *Server) check(ctx context.Context, paths []string) (*CheckResult, error) {
+	result := &CheckResult{Unsorted: []string{}, Errors: []FileError{}}
+	for _, path := range paths {
+		s.mu.Lock()
+		files, err := s.backend.Files(ctx, path)
+		s.mu.Unlock()
+		if err != nil {
+			result.Errors = append(result.Errors, FileError{Path: path, Message: err.Error()})
+			continue
+		}
+
+		for _, file := range files {
+			if err = ctx.Err(); err != nil {
+				return nil, err
+			}
+			src, readErr := hclsort.ReadFileBytes(file)
+			if readErr != nil {
+				result.Errors = append(result.Errors, FileError{Path: file, Message: readErr.Error()})
+				continue
+			}
+			sorted, sortErr := s.sortSource(ctx, file, src)
+			if sortErr != nil {
+				result.Errors = append(result.Errors, FileError{Path: file, Message: sortErr.Error()})
+				continue
+			}
+			if sorted.Changed() {
+				result.Unsorted = append(result.Unsorted, file)
+			}
+		}
+	}
+	return result, nil
+}
+
+// sortSource returns src, the content of the file at path, sorted by the backend, or the result of
+// the last time the same content was sorted.
+func (s *Server) sortSource(ctx context.Context, path string, src []byte) (*hclsort.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cached, ok := s.results[path]; ok && bytes.Equal(cached.Original, src) {
+		return cached, nil
+	}
+	result, err := s.backend.Sort(ctx, path, src)
+	if err != nil {
+		return nil, err
+	}
+	s.results[path] = result
+	return result, nil
+}
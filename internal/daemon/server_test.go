@@ -0,0 +1,153 @@
+package daemon_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexNabokikh/tfsort/internal/daemon"
+	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+)
+
+// upperBackend sorts files by upper-casing them, counting how often they are sorted.
+type upperBackend struct {
+	dir     string
+	sorts   int
+	reloads int
+}
+
+func (b *upperBackend) Sort(_ context.Context, path string, src []byte) (*hclsort.Result, error) {
+	if strings.Contains(string(src), "invalid") {
+		return nil, errors.New("invalid content")
+	}
+	b.sorts++
+	return &hclsort.Result{Path: path, Original: src, Sorted: []byte(strings.ToUpper(string(src)))}, nil
+}
+
+func (b *upperBackend) Files(_ context.Context, path string) ([]string, error) {
+	if path != b.dir {
+		return nil, errors.New("not found")
+	}
+	return []string{filepath.Join(b.dir, "a.tf"), filepath.Join(b.dir, "b.tf")}, nil
+}
+
+func (b *upperBackend) Reload() error {
+	b.reloads++
+	return nil
+}
+
+// call sends a request to the daemon listening on socket and returns its response.
+func call(t *testing.T, socket, method string, params any) map[string]json.RawMessage {
+	t.Helper()
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	request := map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params}
+	if err = json.NewEncoder(conn).Encode(request); err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	var response map[string]json.RawMessage
+	if err = json.Unmarshal(line, &response); err != nil {
+		t.Fatalf("Failed to decode response %s: %v", line, err)
+	}
+	return response
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.tf": "a\n", "b.tf": "B\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	socket, err := os.CreateTemp("", "tfsort-*.sock")
+	if err != nil {
+		t.Fatalf("Failed to create socket path: %v", err)
+	}
+	socket.Close()
+	t.Cleanup(func() { os.Remove(socket.Name()) })
+
+	listener, err := daemon.Listen(socket.Name())
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	info, err := os.Stat(socket.Name())
+	if err != nil {
+		t.Fatalf("Failed to stat socket: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Listen() created a socket with mode %v, want %v", perm, os.FileMode(0600))
+	}
+	if _, err = daemon.Listen(socket.Name()); err == nil {
+		t.Error("Listen() error = nil, want an error while a daemon is listening")
+	}
+
+	backend := &upperBackend{dir: dir}
+	done := make(chan error, 1)
+	go func() {
+		done <- daemon.NewServer(backend).Serve(context.Background(), listener)
+	}()
+
+	path := filepath.Join(dir, "a.tf")
+	response := call(t, socket.Name(), "check", map[string]any{"paths": []string{dir, "missing"}})
+	var check daemon.CheckResult
+	if err = json.Unmarshal(response["result"], &check); err != nil {
+		t.Fatalf("Failed to decode check result: %v", err)
+	}
+	if len(check.Unsorted) != 1 || check.Unsorted[0] != path {
+		t.Errorf("check unsorted = %v, want [%s]", check.Unsorted, path)
+	}
+	if len(check.Errors) != 1 || check.Errors[0].Path != "missing" {
+		t.Errorf("check errors = %v, want an error for missing", check.Errors)
+	}
+
+	response = call(t, socket.Name(), "sort", map[string]any{"path": path, "write": true})
+	var sorted daemon.SortResult
+	if err = json.Unmarshal(response["result"], &sorted); err != nil {
+		t.Fatalf("Failed to decode sort result: %v", err)
+	}
+	if !sorted.Changed || sorted.Sorted != "A\n" {
+		t.Errorf("sort result = %+v, want changed content A", sorted)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "A\n" {
+		t.Errorf("sort wrote %q, want %q", content, "A\n")
+	}
+	if backend.sorts != 2 {
+		t.Errorf("backend sorted %d files, want 2 as unchanged files are cached", backend.sorts)
+	}
+
+	response = call(t, socket.Name(), "sort", map[string]any{"path": path, "source": "invalid"})
+	if !strings.Contains(string(response["error"]), "invalid content") {
+		t.Errorf("sort error = %s, want the error of the backend", response["error"])
+	}
+	if response = call(t, socket.Name(), "reload", nil); backend.reloads != 1 {
+		t.Errorf("reload response = %v, backend reloaded %d times, want 1", response, backend.reloads)
+	}
+	if response = call(t, socket.Name(), "hover", nil); !strings.Contains(string(response["error"]), "-32601") {
+		t.Errorf("hover error = %s, want code -32601", response["error"])
+	}
+
+	call(t, socket.Name(), "shutdown", nil)
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve() did not return after a shutdown request")
+	}
+}
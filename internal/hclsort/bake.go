@@ -0,0 +1,30 @@
+package hclsort
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// isBakeFile reports whether path names a Docker Buildx Bake file, such as docker-bake.hcl or
+// docker-bake.override.hcl.
+func isBakeFile(path string) bool {
+	name := filepath.Base(path)
+	return strings.HasPrefix(name, "docker-bake.") && strings.HasSuffix(name, ".hcl")
+}
+
+// sortBakeBlock sorts the arguments of a target or group block of a Bake file by name using compare,
+// along with the keys of its args and labels maps and its list of tags.
+func sortBakeBlock(block *hclwrite.Block, compare Comparator) {
+	body := block.Body()
+	for _, name := range []string{"args", "labels"} {
+		if body.GetAttribute(name) != nil {
+			sortMapAttribute(body, name, compare)
+		}
+	}
+	sortListAttribute(body, "tags", compare, false)
+	if !attributesInOrder(body, compare) {
+		sortBodyAttributes(body, compare)
+	}
+}
@@ -0,0 +1,154 @@
+package hclsort
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// bodyItem is an attribute or nested block of a body, moved as a unit when the body is sorted.
+type bodyItem struct {
+	// name is the attribute name, empty for nested blocks.
+	name string
+	// block is the nested block, nil for attributes.
+	block *hclwrite.Block
+	// tokens holds the comments above the item followed by the item itself,
+	// without leading or trailing newlines.
+	tokens hclwrite.Tokens
+}
+
+// bodyItems splits a body into its attributes and nested blocks in source order.
+// Comments between two items, including comments separated from the next item by a blank
+// line, travel with the item below them. Comments after the last item are returned as trailing.
+func bodyItems(body *hclwrite.Body) ([]*bodyItem, hclwrite.Tokens) {
+	tokens := body.BuildTokens(nil)
+
+	starts := make(map[*hclwrite.Token]*bodyItem)
+	lengths := make(map[*bodyItem]int)
+	for name, attr := range body.Attributes() {
+		attrTokens := attr.BuildTokens(nil)
+		item := &bodyItem{name: name}
+		starts[attrTokens[0]] = item
+		lengths[item] = len(attrTokens)
+	}
+	for _, block := range body.Blocks() {
+		blockTokens := block.BuildTokens(nil)
+		item := &bodyItem{block: block}
+		starts[blockTokens[0]] = item
+		lengths[item] = len(blockTokens)
+	}
+
+	var items []*bodyItem
+	gapStart := 0
+	for position := 0; position < len(tokens); {
+		item, ok := starts[tokens[position]]
+		if !ok {
+			position++
+			continue
+		}
+		end := position + lengths[item]
+		item.tokens = trimNewlines(append(slices.Clone(tokens[gapStart:position]), tokens[position:end]...))
+		items = append(items, item)
+		position = end
+		gapStart = end
+	}
+
+	// Keep a blank line between the last item and trailing comments separated from it by one.
+	trailing := trimNewlines(tokens[gapStart:])
+	if len(trailing) > 0 && tokens[gapStart].Type == hclsyntax.TokenNewline {
+		trailing = append(hclwrite.Tokens{tokens[gapStart]}, trailing...)
+	}
+	return items, trailing
+}
+
+// sortBodyAttributes sorts the attributes of a body by name using compare.
+// Nested blocks keep their positions, and comments travel with the item below them.
+func sortBodyAttributes(body *hclwrite.Body, compare Comparator) {
+	items, trailing := bodyItems(body)
+
+	var attributes []*bodyItem
+	var slots []int
+	for i, item := range items {
+		if item.block == nil {
+			attributes = append(attributes, item)
+			slots = append(slots, i)
+		}
+	}
+	slices.SortStableFunc(attributes, func(a, b *bodyItem) int {
+		return compare(a.name, b.name)
+	})
+	for i, slot := range slots {
+		items[slot] = attributes[i]
+	}
+
+	rebuildBody(body, items, trailing)
+}
+
+// attributesInOrder reports whether the attributes of body are already sorted by name using compare.
+func attributesInOrder(body *hclwrite.Body, compare Comparator) bool {
+	items, _ := bodyItems(body)
+	var names []string
+	for _, item := range items {
+		if item.block == nil {
+			names = append(names, item.name)
+		}
+	}
+	return slices.IsSortedFunc(names, compare)
+}
+
+// rebuildBody replaces the contents of body with items, one per line, followed by trailing.
+// Nested blocks are separated from the items around them by a blank line. They stay blocks of body,
+// so their bodies can still be sorted afterwards, while attributes become plain tokens.
+// Their expressions can no longer be replaced, but the body can still be sorted again.
+func rebuildBody(body *hclwrite.Body, items []*bodyItem, trailing hclwrite.Tokens) {
+	for _, item := range items {
+		if item.block != nil {
+			body.RemoveBlock(item.block)
+		}
+	}
+	body.Clear()
+	body.AppendNewline()
+	for i, item := range items {
+		if i > 0 && (item.block != nil || items[i-1].block != nil) {
+			body.AppendNewline()
+		}
+		if item.block == nil {
+			appendLine(body, item.tokens)
+			continue
+		}
+		// The comments above the block that are not part of it precede its first token.
+		first := item.block.BuildTokens(nil)[0]
+		if lead := item.tokens[:slices.Index(item.tokens, first)]; len(lead) > 0 {
+			body.AppendUnstructuredTokens(lead)
+		}
+		body.AppendBlock(item.block)
+	}
+	if len(trailing) > 0 {
+		appendLine(body, trailing)
+	}
+}
+
+// appendLine appends tokens to body and ends the line unless the tokens already do.
+// Line comments include their trailing newline, so a line ending in one is already terminated.
+func appendLine(body *hclwrite.Body, tokens hclwrite.Tokens) {
+	body.AppendUnstructuredTokens(tokens)
+	last := tokens[len(tokens)-1]
+	if last.Type == hclsyntax.TokenComment && bytes.HasSuffix(last.Bytes, []byte("\n")) {
+		return
+	}
+	body.AppendNewline()
+}
+
+// trimNewlines returns tokens without leading and trailing newline tokens.
+func trimNewlines(tokens hclwrite.Tokens) hclwrite.Tokens {
+	start, end := 0, len(tokens)
+	for start < end && tokens[start].Type == hclsyntax.TokenNewline {
+		start++
+	}
+	for end > start && tokens[end-1].Type == hclsyntax.TokenNewline {
+		end--
+	}
+	return tokens[start:end]
+}
@@ -0,0 +1,183 @@
+package hclsort
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Sort strategies accepted by ComparatorFor.
+const (
+	// StrategyLexical compares names byte by byte.
+	StrategyLexical = "lexical"
+	// StrategyNatural compares runs of digits by their numeric value, so "subnet_2" sorts before "subnet_10".
+	StrategyNatural = "natural"
+)
+
+// Sort orders accepted by configuration files.
+const (
+	// OrderAscending sorts names from lowest to highest.
+	OrderAscending = "ascending"
+	// OrderDescending sorts names from highest to lowest.
+	OrderDescending = "descending"
+)
+
+// Comparator compares two names. It returns a negative number when a sorts before b,
+// a positive number when a sorts after b and zero when they are equal.
+type Comparator func(a, b string) int
+
+// ComparatorFor returns the Comparator implementing the named sort strategy.
+// An empty strategy selects StrategyLexical.
+func ComparatorFor(strategy string) (Comparator, error) {
+	switch strategy {
+	case "", StrategyLexical:
+		return strings.Compare, nil
+	case StrategyNatural:
+		return naturalCompare, nil
+	default:
+		return nil, fmt.Errorf(
+			"unknown sort strategy '%s', expected '%s' or '%s'",
+			strategy,
+			StrategyLexical,
+			StrategyNatural,
+		)
+	}
+}
+
+// CollatorFor returns a Comparator that orders names according to the collation rules of a
+// BCP 47 locale, such as "da" or "de-DE", instead of their byte order. With StrategyNatural,
+// runs of digits are additionally compared by their numeric value.
+// Names the collation considers equal are ordered by their bytes, so the order is deterministic.
+func CollatorFor(locale, strategy string) (Comparator, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return nil, fmt.Errorf("invalid collation locale '%s': %w", locale, err)
+	}
+	if _, err = ComparatorFor(strategy); err != nil {
+		return nil, err
+	}
+
+	var options []collate.Option
+	if strategy == StrategyNatural {
+		options = append(options, collate.Numeric)
+	}
+	collator := collate.New(tag, options...)
+
+	return func(a, b string) int {
+		if c := collator.CompareString(a, b); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	}, nil
+}
+
+// Reverse returns a Comparator that sorts names in the opposite order of compare.
+func Reverse(compare Comparator) Comparator {
+	return func(a, b string) int {
+		return compare(b, a)
+	}
+}
+
+// PinFirst returns a Comparator that sorts the given names before all others, in the order they
+// are listed. Names that are not listed are ordered by compare after them.
+func PinFirst(names []string, compare Comparator) Comparator {
+	ranks := make(map[string]int, len(names))
+	for i, name := range names {
+		if _, ok := ranks[name]; !ok {
+			ranks[name] = i
+		}
+	}
+
+	return func(a, b string) int {
+		rankA, pinnedA := ranks[a]
+		rankB, pinnedB := ranks[b]
+		switch {
+		case pinnedA && pinnedB:
+			return rankA - rankB
+		case pinnedA:
+			return -1
+		case pinnedB:
+			return 1
+		default:
+			return compare(a, b)
+		}
+	}
+}
+
+// GroupBy returns a Comparator that sorts names into buckets by the first pattern they match,
+// in the order the patterns are listed, and orders names within a bucket by compare.
+// Names matching no pattern sort after all buckets.
+func GroupBy(patterns []*regexp.Regexp, compare Comparator) Comparator {
+	bucket := func(name string) int {
+		for i, pattern := range patterns {
+			if pattern.MatchString(name) {
+				return i
+			}
+		}
+		return len(patterns)
+	}
+
+	return func(a, b string) int {
+		if c := bucket(a) - bucket(b); c != 0 {
+			return c
+		}
+		return compare(a, b)
+	}
+}
+
+// IgnoreCase returns a Comparator that applies compare to the lowercase forms of names.
+// Names differing only in case are ordered by their original bytes, so "Name" sorts before "name".
+func IgnoreCase(compare Comparator) Comparator {
+	return func(a, b string) int {
+		if c := compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	}
+}
+
+// naturalCompare compares a and b, treating runs of digits as numbers.
+// Numbers that are equal in value but differ in leading zeros, such as "01" and "1",
+// fall back to a lexical comparison so the order is deterministic.
+func naturalCompare(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				return int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+			continue
+		}
+
+		startA, startB := i, j
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		numberA := strings.TrimLeft(a[startA:i], "0")
+		numberB := strings.TrimLeft(b[startB:j], "0")
+		if len(numberA) != len(numberB) {
+			return len(numberA) - len(numberB)
+		}
+		if c := strings.Compare(numberA, numberB); c != 0 {
+			return c
+		}
+	}
+
+	if c := (len(a) - i) - (len(b) - j); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// isDigit reports whether c is an ASCII digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,97 @@
+package hclsort
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// Severities of diagnostics.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Position is a location in a source file. Line and Column count from 1, and Column counts
+// characters rather than bytes.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Diagnostic describes a problem found in a source file at a range of positions, for editors and CI
+// integrations to annotate.
+type Diagnostic struct {
+	Filename string
+	// Start is the first position of the range and End the position just past it.
+	Start Position
+	End   Position
+	// Severity is SeverityError or SeverityWarning.
+	Severity string
+	// Code identifies the kind of problem, derived from its summary, such as
+	// "unclosed-configuration-block".
+	Code    string
+	Summary string
+	Detail  string
+}
+
+// String formats the diagnostic like the HCL parser, such as
+// "main.tf:1,14-15: Unclosed configuration block; There is no closing brace for this block".
+func (d Diagnostic) String() string {
+	text := fmt.Sprintf("%s:%d,%d-%d: %s", d.Filename, d.Start.Line, d.Start.Column, d.End.Column, d.Summary)
+	if d.Start.Line != d.End.Line {
+		text = fmt.Sprintf(
+			"%s:%d,%d-%d,%d: %s", d.Filename, d.Start.Line, d.Start.Column, d.End.Line, d.End.Column, d.Summary,
+		)
+	}
+	if d.Detail != "" {
+		text += "; " + d.Detail
+	}
+	return text
+}
+
+// ParseError is returned when a file cannot be parsed. Its Diagnostics describe each problem found.
+type ParseError struct {
+	Filename    string
+	Diagnostics []Diagnostic
+	// diags holds the diagnostics of the HCL parser, formatted in the error message.
+	diags hcl.Diagnostics
+}
+
+// Error implements error.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("error parsing HCL content from '%s': %v", e.Filename, e.diags)
+}
+
+// newParseError returns the ParseError describing the diagnostics of parsing filename.
+func newParseError(filename string, diags hcl.Diagnostics) *ParseError {
+	parseErr := &ParseError{Filename: filename, diags: diags}
+	for _, diag := range diags {
+		diagnostic := Diagnostic{
+			Filename: filename,
+			Severity: SeverityError,
+			Code:     diagnosticCode(diag.Summary),
+			Summary:  diag.Summary,
+			Detail:   diag.Detail,
+		}
+		if diag.Severity == hcl.DiagWarning {
+			diagnostic.Severity = SeverityWarning
+		}
+		if diag.Subject != nil {
+			diagnostic.Start = Position{Line: diag.Subject.Start.Line, Column: diag.Subject.Start.Column}
+			diagnostic.End = Position{Line: diag.Subject.End.Line, Column: diag.Subject.End.Column}
+		}
+		parseErr.Diagnostics = append(parseErr.Diagnostics, diagnostic)
+	}
+	return parseErr
+}
+
+// diagnosticCode returns the code of a diagnostic with the given summary: its words in lower case,
+// joined by hyphens.
+func diagnosticCode(summary string) string {
+	words := strings.FieldsFunc(strings.ToLower(summary), func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+	return strings.Join(words, "-")
+}
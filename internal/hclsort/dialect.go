@@ -0,0 +1,78 @@
+package hclsort
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Dialects of the HCL files that are sorted.
+const (
+	// DialectTerraform sorts Terraform and OpenTofu configurations and their related files.
+	DialectTerraform = "terraform"
+	// DialectNomad sorts Nomad job specifications, which keep the order of their nested blocks.
+	DialectNomad = "nomad"
+	// DialectVault sorts Vault policies by the paths they grant capabilities on.
+	DialectVault = "vault"
+	// DialectVaultServer sorts Vault server configurations.
+	DialectVaultServer = "vault-server"
+	// DialectConsul sorts Consul agent configurations.
+	DialectConsul = "consul"
+	// DialectWaypoint sorts Waypoint project configurations, the dialect of waypoint.hcl files.
+	DialectWaypoint = "waypoint"
+	// DialectGeneric sorts the files of any HCL-based tool as declared by block schemas, without the
+	// rules of the other dialects.
+	DialectGeneric = "generic"
+)
+
+// dialectProfile declares what can be reordered in the files of a dialect without changing their
+// meaning, on top of the block types selected for sorting.
+type dialectProfile struct {
+	// sortAssignments sorts the top-level attributes by name.
+	sortAssignments bool
+	// blocks lists the labeled top-level block types sorted by their labels.
+	blocks []string
+}
+
+// dialectProfiles holds the profiles of the dialects whose top-level items can be reordered.
+//
+//nolint:gochecknoglobals // Read-only table
+var dialectProfiles = map[string]dialectProfile{
+	DialectVault: {blocks: []string{"path"}},
+	DialectVaultServer: {
+		sortAssignments: true,
+		blocks:          []string{"listener", "storage", "ha_storage", "service_registration"},
+	},
+	DialectConsul: {sortAssignments: true},
+	// The use and hook blocks nested in apps run in order and are left as written.
+	DialectWaypoint: {blocks: []string{"variable", "app"}},
+}
+
+// fileDialect returns the dialect of the file at path: that of its well-known name, such as
+// DialectWaypoint for waypoint.hcl, or dialect.
+func fileDialect(path, dialect string) string {
+	if filepath.Base(path) == "waypoint.hcl" {
+		return DialectWaypoint
+	}
+	return dialect
+}
+
+// ValidateDialect checks that dialect names a known dialect of HCL files, or is empty.
+func ValidateDialect(dialect string) error {
+	switch dialect {
+	case "", DialectTerraform, DialectNomad, DialectVault, DialectVaultServer,
+		DialectConsul, DialectWaypoint, DialectGeneric:
+		return nil
+	default:
+		return fmt.Errorf(
+			"unknown dialect '%s', expected '%s', '%s', '%s', '%s', '%s', '%s' or '%s'",
+			dialect,
+			DialectTerraform,
+			DialectNomad,
+			DialectVault,
+			DialectVaultServer,
+			DialectConsul,
+			DialectWaypoint,
+			DialectGeneric,
+		)
+	}
+}
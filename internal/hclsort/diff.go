@@ -0,0 +1,191 @@
+package hclsort
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change.
+const diffContextLines = 3
+
+// diffOp is the kind of a single line in an edit script.
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+// diffLine is a single line of an edit script with its position in both inputs.
+type diffLine struct {
+	op      diffOp
+	text    string
+	oldLine int
+	newLine int
+}
+
+// UnifiedDiff returns a unified diff between original and sorted, or an empty string when they are equal.
+func UnifiedDiff(path string, original, sorted []byte) string {
+	if bytes.Equal(original, sorted) {
+		return ""
+	}
+
+	script := diffLines(splitLines(original), splitLines(sorted))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s.orig\n+++ %s\n", path, path)
+
+	for start := 0; start < len(script); {
+		if script[start].op == diffEqual {
+			start++
+			continue
+		}
+
+		hunkStart := max(start-diffContextLines, 0)
+		hunkEnd := start
+		for i := start; i < len(script); i++ {
+			if script[i].op != diffEqual {
+				hunkEnd = i + 1
+				continue
+			}
+			if i-hunkEnd >= 2*diffContextLines {
+				break
+			}
+		}
+		hunkEnd = min(hunkEnd+diffContextLines, len(script))
+
+		writeHunk(&sb, script[hunkStart:hunkEnd])
+		start = hunkEnd
+	}
+
+	return sb.String()
+}
+
+// writeHunk writes a single hunk, including its range header, to sb.
+func writeHunk(sb *strings.Builder, hunk []diffLine) {
+	oldStart, newStart := hunk[0].oldLine, hunk[0].newLine
+	oldCount, newCount := 0, 0
+	for _, line := range hunk {
+		switch line.op {
+		case diffEqual:
+			oldCount++
+			newCount++
+		case diffDelete:
+			oldCount++
+		case diffInsert:
+			newCount++
+		}
+	}
+
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+	for _, line := range hunk {
+		switch line.op {
+		case diffEqual:
+			sb.WriteString(" ")
+		case diffDelete:
+			sb.WriteString("-")
+		case diffInsert:
+			sb.WriteString("+")
+		}
+		sb.WriteString(line.text)
+		sb.WriteString("\n")
+	}
+}
+
+// hunkRange formats a hunk range as used in unified diff headers.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// An empty range refers to the line before the change.
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines splits content into lines without their trailing newlines.
+func splitLines(content []byte) []string {
+	text := strings.TrimSuffix(string(content), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffLines computes a minimal line-based edit script using the Myers algorithm.
+func diffLines(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	maxD := n + m
+	offset := maxD + 1
+	v := make([]int, 2*maxD+2)
+	trace := make([][]int, 0, maxD+1)
+
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(a, b, trace, offset, d)
+			}
+		}
+	}
+
+	return nil
+}
+
+// backtrackDiff walks the Myers trace backwards to build the edit script.
+func backtrackDiff(a, b []string, trace [][]int, offset, depth int) []diffLine {
+	x, y := len(a), len(b)
+	script := make([]diffLine, 0, len(a)+len(b))
+
+	for d := depth; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			script = append(script, diffLine{op: diffEqual, text: a[x], oldLine: x + 1, newLine: y + 1})
+		}
+		if x == prevX {
+			y--
+			script = append(script, diffLine{op: diffInsert, text: b[y], oldLine: x + 1, newLine: y + 1})
+		} else {
+			x--
+			script = append(script, diffLine{op: diffDelete, text: a[x], oldLine: x + 1, newLine: y + 1})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		script = append(script, diffLine{op: diffEqual, text: a[x], oldLine: x + 1, newLine: y + 1})
+	}
+
+	for i, j := 0, len(script)-1; i < j; i, j = i+1, j-1 {
+		script[i], script[j] = script[j], script[i]
+	}
+
+	return script
+}
@@ -0,0 +1,168 @@
+package hclsort
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+const (
+	// directiveIgnore placed in the comment directly above a block keeps the block's
+	// position and contents untouched.
+	directiveIgnore = "tfsort:ignore"
+	// directiveOff starts a region that is emitted verbatim and excluded from reordering.
+	directiveOff = "tfsort:off"
+	// directiveOn ends a region started by directiveOff.
+	directiveOn = "tfsort:on"
+	// directiveSkipFile in the header comments of a file leaves the whole file untouched.
+	directiveSkipFile = "tfsort:skip-file"
+)
+
+// commentDirective returns the tfsort directive contained in a comment, if any.
+// Directives must be the first word of the comment, e.g. "# tfsort:ignore keep dependency order".
+func commentDirective(comment []byte) string {
+	text := bytes.TrimSpace(comment)
+	switch {
+	case bytes.HasPrefix(text, []byte("#")):
+		text = text[1:]
+	case bytes.HasPrefix(text, []byte("//")):
+		text = text[2:]
+	case bytes.HasPrefix(text, []byte("/*")):
+		text = bytes.TrimSuffix(text[2:], []byte("*/"))
+	default:
+		return ""
+	}
+
+	fields := bytes.Fields(text)
+	if len(fields) == 0 || !bytes.HasPrefix(fields[0], []byte("tfsort:")) {
+		return ""
+	}
+	return string(fields[0])
+}
+
+// leadComments returns the comment tokens attached directly above a block.
+func leadComments(block *hclwrite.Block) hclwrite.Tokens {
+	var comments hclwrite.Tokens
+	for _, token := range block.BuildTokens(nil) {
+		switch token.Type {
+		case hclsyntax.TokenComment:
+			comments = append(comments, token)
+		case hclsyntax.TokenNewline:
+			continue
+		default:
+			return comments
+		}
+	}
+	return comments
+}
+
+// hasBlockDirective reports whether the comments directly above block contain directive.
+func hasBlockDirective(block *hclwrite.Block, directive string) bool {
+	for _, comment := range leadComments(block) {
+		if commentDirective(comment.Bytes) == directive {
+			return true
+		}
+	}
+	return false
+}
+
+// verbatimLineRanges returns the line ranges fenced by tfsort:off and tfsort:on comment lines.
+// The ranges include the marker lines; an unterminated region extends to the last line.
+func verbatimLineRanges(lines [][]byte) []tokenRange {
+	var ranges []tokenRange
+	open := -1
+
+	for i, line := range lines {
+		switch commentDirective(line) {
+		case directiveOff:
+			if open < 0 {
+				open = i
+			}
+		case directiveOn:
+			if open >= 0 {
+				ranges = append(ranges, tokenRange{start: open, end: i + 1})
+				open = -1
+			}
+		}
+	}
+
+	if open >= 0 {
+		ranges = append(ranges, tokenRange{start: open, end: len(lines)})
+	}
+	return ranges
+}
+
+// restoreVerbatimRegions replaces the tfsort:off/tfsort:on regions of formatted with the
+// corresponding lines of raw, undoing any formatting applied to them.
+// If the regions of both inputs do not correspond, formatted is returned unchanged.
+func restoreVerbatimRegions(raw, formatted []byte) []byte {
+	rawLines := bytes.SplitAfter(raw, []byte("\n"))
+	formattedLines := bytes.SplitAfter(formatted, []byte("\n"))
+
+	rawRanges := verbatimLineRanges(rawLines)
+	formattedRanges := verbatimLineRanges(formattedLines)
+	if len(rawRanges) == 0 || len(rawRanges) != len(formattedRanges) {
+		return formatted
+	}
+
+	var result bytes.Buffer
+	position := 0
+	for i, formattedRange := range formattedRanges {
+		result.Write(bytes.Join(formattedLines[position:formattedRange.start], nil))
+		result.Write(bytes.Join(rawLines[rawRanges[i].start:rawRanges[i].end], nil))
+		position = formattedRange.end
+	}
+	result.Write(bytes.Join(formattedLines[position:], nil))
+
+	return result.Bytes()
+}
+
+// headerComments returns the trimmed comment lines before the first line of code in src.
+// Blank lines within the header are skipped.
+func headerComments(src []byte) [][]byte {
+	var comments [][]byte
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !isCommentLine(line) {
+			break
+		}
+		comments = append(comments, line)
+	}
+	return comments
+}
+
+// hasSkipFileDirective reports whether any header comment is a tfsort:skip-file directive.
+func hasSkipFileDirective(header [][]byte) bool {
+	for _, comment := range header {
+		if commentDirective(comment) == directiveSkipFile {
+			return true
+		}
+	}
+	return false
+}
+
+// isGenerated reports whether any header comment matches the generated file pattern.
+// A nil pattern disables the detection.
+func isGenerated(header [][]byte, pattern *regexp.Regexp) bool {
+	if pattern == nil {
+		return false
+	}
+	for _, comment := range header {
+		if pattern.Match(comment) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCommentLine reports whether a trimmed line starts with a comment.
+func isCommentLine(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("#")) ||
+		bytes.HasPrefix(line, []byte("//")) ||
+		bytes.HasPrefix(line, []byte("/*"))
+}
@@ -0,0 +1,36 @@
+package hclsort
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// duplicateBlockWarnings returns a warning for each set of labeled top-level blocks sharing their type,
+// labels and sort key, such as two variable "region" blocks, in order of their first occurrence.
+// Provider configurations with different aliases are not duplicates. Sorting keeps
+// the relative order of duplicates, so the warning points at all of them. Blocks without labels, such
+// as terraform and locals blocks, may legitimately repeat and are not reported.
+func duplicateBlockWarnings(items []*topLevelItem, lines blockLines) []string {
+	var identities []string
+	duplicates := map[string][]*hclwrite.Block{}
+	for _, item := range items {
+		if item.block == nil || len(item.block.Labels()) == 0 {
+			continue
+		}
+		identity := blockIdentity(item.block)
+		if duplicates[identity] == nil {
+			identities = append(identities, identity)
+		}
+		duplicates[identity] = append(duplicates[identity], item.block)
+	}
+
+	var warnings []string
+	for _, identity := range identities {
+		if blocks := duplicates[identity]; len(blocks) > 1 {
+			address := blockAddress(blocks[0])
+			warnings = append(warnings, fmt.Sprintf("duplicate %s blocks at %s", address, lines.describe(blocks)))
+		}
+	}
+	return warnings
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // ValidateFilePath checks if the path is valid for processing.
@@ -27,13 +28,30 @@ func ValidateFilePath(path string) error {
 	}
 }
 
+// IsSkippedFile reports whether a file is maintained by Terraform or OpenTofu, such as the dependency
+// lock file, which must never be rewritten although it shares the .hcl extension.
+func IsSkippedFile(name string) bool {
+	return name == ".terraform.lock.hcl"
+}
+
+// IsSkippedDir reports whether a directory holds version control data or
+// downloaded providers and modules, which must never be rewritten.
+func IsSkippedDir(name string) bool {
+	switch name {
+	case ".git",
+		".terraform",
+		".terraform.d",
+		".terragrunt-cache",
+		".external_modules":
+		return true
+	default:
+		return false
+	}
+}
+
 // CheckFileExtension verifies the file extension against a list of allowed types.
 func CheckFileExtension(path string, allowedTypes map[string]bool) error {
-	fileExtension := ""
-	ext := filepath.Ext(path)
-	if len(ext) > 0 {
-		fileExtension = ext[1:]
-	}
+	fileExtension := FileType(path)
 
 	if !allowedTypes[fileExtension] {
 		if fileExtension != "" {
@@ -46,6 +64,19 @@ func CheckFileExtension(path string, allowedTypes map[string]bool) error {
 	return nil
 }
 
+// FileType returns the extension of path without its dot, or the last two extensions of JSON files
+// such as "tf.json", so that JSON configuration files are told apart from other JSON files.
+// It returns an empty string for paths without an extension.
+func FileType(path string) string {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if ext == "json" {
+		if inner := filepath.Ext(strings.TrimSuffix(path, ".json")); inner != "" {
+			return inner[1:] + ".json"
+		}
+	}
+	return ext
+}
+
 // ReadFileBytes reads the content of the file at the given path.
 func ReadFileBytes(path string) ([]byte, error) {
 	src, err := os.ReadFile(path)
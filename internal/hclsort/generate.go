@@ -0,0 +1,87 @@
+package hclsort
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// sortGeneratedContents sorts the configuration in the heredoc assigned to the contents of a
+// Terragrunt generate block, such as the provider blocks commonly generated this way, with the rules
+// of opts. The lines of indented heredocs keep their common indentation. Contents that are not a
+// heredoc or cannot be parsed, such as those with interpolations outside of strings, are left untouched.
+func sortGeneratedContents(block *hclwrite.Block, opts sortOptions) {
+	attr := block.Body().GetAttribute("contents")
+	if attr == nil {
+		return
+	}
+	tokens := trimNewlines(attr.Expr().BuildTokens(nil))
+	if len(tokens) < 3 || tokens[0].Type != hclsyntax.TokenOHeredoc ||
+		tokens[len(tokens)-1].Type != hclsyntax.TokenCHeredoc {
+		return
+	}
+	opening, closing := tokens[0].Bytes, tokens[len(tokens)-1].Bytes
+	content := tokens[1 : len(tokens)-1].Bytes()
+
+	file, err := ParseHCLContent(content, "")
+	if err != nil {
+		return
+	}
+	// The generated file is a Terraform configuration regardless of the file it is generated from.
+	opts.sortAssignments, opts.testFile = false, false
+	sorted, _, err := processAndSortBlocks(file, opts)
+	if err != nil {
+		return
+	}
+	indent := ""
+	if bytes.HasPrefix(opening, []byte("<<-")) {
+		indent = commonIndent(content)
+	}
+	text := indentLines(append(bytes.TrimSpace(FormatHCLBytes(sorted)), '\n'), indent)
+	if bytes.Equal(text, content) {
+		return
+	}
+
+	heredoc, err := ParseHCLContent(slices.Concat([]byte("contents = "), opening, text, closing, []byte("\n")), "")
+	if err != nil {
+		return
+	}
+	block.Body().SetAttributeRaw("contents", heredoc.Body().GetAttribute("contents").Expr().BuildTokens(nil))
+}
+
+// commonIndent returns the whitespace that every non-blank line of text starts with.
+func commonIndent(text []byte) string {
+	indent := ""
+	first := true
+	for line := range strings.SplitSeq(string(text), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lineIndent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent, first = lineIndent, false
+			continue
+		}
+		for !strings.HasPrefix(lineIndent, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+	return indent
+}
+
+// indentLines prefixes the non-blank lines of text with indent.
+func indentLines(text []byte, indent string) []byte {
+	if indent == "" {
+		return text
+	}
+	lines := bytes.SplitAfter(text, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) > 0 {
+			lines[i] = append([]byte(indent), line...)
+		}
+	}
+	return bytes.Join(lines, nil)
+}
@@ -0,0 +1,74 @@
+package hclsort
+
+import (
+	"slices"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// BlockSchema declares how the blocks of a type are sorted in the generic dialect.
+type BlockSchema struct {
+	// KeyLabels lists the indexes of the labels the blocks are sorted by, e.g. [0, 1] for the type and
+	// name of a resource. Blocks that lack one of these labels, and all blocks when it is empty, keep
+	// their positions.
+	KeyLabels []int
+	// Pinned lists the attributes that keep their positions in the bodies of the blocks, while the
+	// other attributes are sorted by name around them.
+	Pinned []string
+}
+
+// genericBlockTypes returns the block types sorted by key in the generic dialect, those with a schema.
+func genericBlockTypes(schemas map[string]BlockSchema) map[string]bool {
+	blocks := make(map[string]bool, len(schemas))
+	for blockType := range schemas {
+		blocks[blockType] = true
+	}
+	return blocks
+}
+
+// genericSortKey returns the key a block is sorted by according to its schema, or nil if the block
+// cannot be sorted.
+func genericSortKey(block *hclwrite.Block, schema BlockSchema) []string {
+	labels := block.Labels()
+	key := make([]string, 0, len(schema.KeyLabels))
+	for _, index := range schema.KeyLabels {
+		if index >= len(labels) {
+			return nil
+		}
+		key = append(key, labels[index])
+	}
+	if len(key) == 0 {
+		return nil
+	}
+	return key
+}
+
+// sortGenericBlock sorts the attributes of a block by name using compare, leaving the pinned
+// attributes of its schema and nested blocks in their positions. Bodies already in order are left as
+// they are.
+func sortGenericBlock(block *hclwrite.Block, schema BlockSchema, compare Comparator) {
+	body := block.Body()
+	items, trailing := bodyItems(body)
+
+	var attributes []*bodyItem
+	var slots []int
+	for i, item := range items {
+		if item.block == nil && !slices.Contains(schema.Pinned, item.name) {
+			attributes = append(attributes, item)
+			slots = append(slots, i)
+		}
+	}
+	if slices.IsSortedFunc(attributes, func(a, b *bodyItem) int {
+		return compare(a.name, b.name)
+	}) {
+		return
+	}
+	slices.SortStableFunc(attributes, func(a, b *bodyItem) int {
+		return compare(a.name, b.name)
+	})
+	for i, slot := range slots {
+		items[slot] = attributes[i]
+	}
+
+	rebuildBody(body, items, trailing)
+}
@@ -0,0 +1,65 @@
+package hclsort
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Groupings of sorted resource blocks.
+const (
+	// ResourceGroupingProvider groups resources by the provider prefix of their type, such as aws for
+	// aws_s3_bucket.
+	ResourceGroupingProvider = "provider"
+	// ResourceGroupingType groups resources by their type, such as aws_route53_record.
+	ResourceGroupingType = "type"
+)
+
+// resourceGroup returns the group of block under grouping, or an empty string for blocks other than
+// resources and when resources are not grouped.
+func resourceGroup(block *hclwrite.Block, grouping string) string {
+	labels := block.Labels()
+	if block.Type() != "resource" || len(labels) == 0 {
+		return ""
+	}
+	switch grouping {
+	case ResourceGroupingProvider:
+		prefix, _, _ := strings.Cut(labels[0], "_")
+		return prefix
+	case ResourceGroupingType:
+		return labels[0]
+	default:
+		return ""
+	}
+}
+
+// joinResourceGroups keeps adjacent resources of the same group on adjacent lines, so that only
+// groups are separated by blank lines. Pinned items are never joined.
+func joinResourceGroups(items []*topLevelItem, grouping string) {
+	for i := 0; i < len(items)-1; i++ {
+		current, next := items[i], items[i+1]
+		if current.pinned || next.pinned || current.block == nil || next.block == nil {
+			continue
+		}
+		group := resourceGroup(current.block, grouping)
+		if group != "" && group == resourceGroup(next.block, grouping) {
+			current.joinNext = true
+		}
+	}
+}
+
+// ValidateResourceGrouping checks that grouping names a known grouping of resources, or is empty.
+func ValidateResourceGrouping(grouping string) error {
+	switch grouping {
+	case "", ResourceGroupingProvider, ResourceGroupingType:
+		return nil
+	default:
+		return fmt.Errorf(
+			"unknown resource grouping '%s', expected '%s' or '%s'",
+			grouping,
+			ResourceGroupingProvider,
+			ResourceGroupingType,
+		)
+	}
+}
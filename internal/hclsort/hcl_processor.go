@@ -1,15 +1,17 @@
 package hclsort
 
 import (
-	"fmt"
+	"regexp"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2"
-	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 )
 
 // ParseHCLContent parses the HCL source byte slice using hclwrite.
+// It fails with a *ParseError describing the position of each problem.
 func ParseHCLContent(
 	src []byte,
 	filename string,
@@ -20,142 +22,603 @@ func ParseHCLContent(
 		hcl.Pos{Line: 1, Column: 1},
 	)
 	if diags.HasErrors() {
-		return nil, fmt.Errorf(
-			"error parsing HCL content from '%s': %w",
-			filename,
-			diags,
-		)
+		return nil, newParseError(filename, diags)
 	}
 	return file, nil
 }
 
 // sortRequiredProvidersInBlock sorts the entries in any required_providers block.
-func sortRequiredProvidersInBlock(block *hclwrite.Block) {
+// Comments above an entry move with it.
+func sortRequiredProvidersInBlock(block *hclwrite.Block, compare Comparator) {
 	for _, b := range block.Body().Blocks() {
 		if b.Type() != "required_providers" {
 			continue
 		}
-		body := b.Body()
-		attrs := body.Attributes()
+		sortBodyAttributes(b.Body(), compare)
+	}
+}
 
-		providerNames := make([]string, 0, len(attrs))
-		for name := range attrs {
-			providerNames = append(providerNames, name)
+// sortBackendBlocks sorts the attributes of any backend block, such as bucket, key and region.
+// Nested blocks keep their positions and comments above an attribute move with it.
+func sortBackendBlocks(block *hclwrite.Block, compare Comparator) {
+	for _, b := range block.Body().Blocks() {
+		if b.Type() != "backend" {
+			continue
 		}
-		sort.Strings(providerNames)
-
-		body.Clear()
-		body.AppendNewline()
-
-		for i, name := range providerNames {
-			attr := attrs[name]
-			tokens := attr.BuildTokens(nil)
+		sortBodyAttributes(b.Body(), compare)
+	}
+}
 
-			start, end := 0, len(tokens)
-			for start < end && tokens[start].Type == hclsyntax.TokenNewline {
-				start++
-			}
-			for end > start && tokens[end-1].Type == hclsyntax.TokenNewline {
-				end--
+// sortCloudBlocks puts any cloud block in canonical order, see cloudLayout, and sorts the attributes
+// of its workspaces block. With sortTags, lists of literal workspace tags are sorted as well.
+func sortCloudBlocks(block *hclwrite.Block, compare Comparator, sortTags bool) {
+	for _, cloud := range block.Body().Blocks() {
+		if cloud.Type() != "cloud" {
+			continue
+		}
+		for _, workspaces := range cloud.Body().Blocks() {
+			if workspaces.Type() != "workspaces" {
+				continue
 			}
-			body.AppendUnstructuredTokens(tokens[start:end])
-			if i+1 < len(providerNames) {
-				body.AppendNewline()
+			if sortTags {
+				sortListAttribute(workspaces.Body(), "tags", compare, true)
 			}
+			sortBodyAttributes(workspaces.Body(), compare)
 		}
-		body.AppendNewline()
+		sortBodyLayout(cloud.Body(), cloudLayout, compare, nil)
 	}
 }
 
-// sortLocalsBlock sorts the top‐level assignments in a locals block.
-func sortLocalsBlock(block *hclwrite.Block) {
-	body := block.Body()
-	attrs := body.Attributes()
-
-	names := make([]string, 0, len(attrs))
-	for name := range attrs {
-		names = append(names, name)
+// sortProviderMetaBlocks sorts the attributes of any provider_meta block.
+func sortProviderMetaBlocks(block *hclwrite.Block, compare Comparator) {
+	for _, b := range block.Body().Blocks() {
+		if b.Type() == "provider_meta" {
+			sortBodyAttributes(b.Body(), compare)
+		}
 	}
-	sort.Strings(names)
-
-	body.Clear()
-	body.AppendNewline()
-	for i, name := range names {
-		attr := attrs[name]
-		tokens := attr.BuildTokens(nil)
+}
 
-		start, end := 0, len(tokens)
-		for start < end && tokens[start].Type == hclsyntax.TokenNewline {
-			start++
-		}
-		for end > start && tokens[end-1].Type == hclsyntax.TokenNewline {
-			end--
-		}
+// sortTerraformBlock puts the settings of a terraform block in canonical order, see terraformLayout,
+// and sorts the entries of its required_providers, backend, cloud and provider_meta blocks.
+func sortTerraformBlock(block *hclwrite.Block, compare Comparator, sortTags bool) {
+	// Nested bodies are sorted first, the outer body is rebuilt from their tokens.
+	sortRequiredProvidersInBlock(block, compare)
+	sortBackendBlocks(block, compare)
+	sortCloudBlocks(block, compare, sortTags)
+	sortProviderMetaBlocks(block, compare)
+	sortBodyLayout(block.Body(), terraformLayout, compare, nil)
+}
 
-		body.AppendUnstructuredTokens(tokens[start:end])
-		if i+1 < len(names) {
-			body.AppendNewline()
+// sortLocalsBlock sorts the top‐level assignments in a locals block.
+// Comments above an assignment move with it. With a positive depth, the keys of the object literals
+// assigned to locals are sorted as well, up to depth levels of nesting. With topological, locals are
+// defined before the locals referring to them; see sortLocalsTopologically.
+func sortLocalsBlock(block *hclwrite.Block, compare Comparator, depth int, topological bool) {
+	if depth > 0 {
+		// Values are sorted first, as sorting the assignments turns them into tokens.
+		for name := range block.Body().Attributes() {
+			sortObjectAttribute(block.Body(), name, compare, objectLiteralOpening, depth)
 		}
 	}
-	body.AppendNewline()
+	if topological {
+		sortLocalsTopologically(block.Body(), compare)
+		return
+	}
+	sortBodyAttributes(block.Body(), compare)
+}
+
+// sortModuleParams puts the arguments of a module block in canonical order: source and version
+// first, then count and for_each, the alphabetized inputs, and providers and depends_on last.
+// Nested blocks are sorted by type and labels with blockCompare unless it is nil.
+func sortModuleParams(block *hclwrite.Block, compare, blockCompare Comparator) {
+	// Nested bodies are sorted first, the outer body is rebuilt from their tokens.
+	sortDynamicBlocks(block.Body(), compare)
+	sortBodyLayout(block.Body(), moduleLayout, compare, blockCompare)
+}
+
+// sortResourceParams puts the body of a resource or data block in canonical order: count and
+// for_each first, then the alphabetized attributes, nested blocks, provisioners in their original
+// order, connection, lifecycle, and depends_on last. Other nested blocks are sorted by type and
+// labels with blockCompare unless it is nil. The contents of dynamic blocks are sorted as well.
+func sortResourceParams(block *hclwrite.Block, compare, blockCompare Comparator) {
+	// Nested bodies are sorted first, the outer body is rebuilt from their tokens.
+	sortDynamicBlocks(block.Body(), compare)
+	sortBodyLayout(block.Body(), resourceLayout, compare, blockCompare)
+}
+
+// sortProviderParams puts the body of a provider block in canonical order: alias first, then the
+// alphabetized configuration attributes, and nested blocks last in their original order.
+func sortProviderParams(block *hclwrite.Block, compare Comparator) {
+	sortBodyLayout(block.Body(), providerLayout, compare, nil)
 }
 
 // ProcessAndSortBlocks extracts sortable blocks (variables, outputs, locals, terraform) and sorts them.
+// Blocks whose type is in allowedBlocks are sorted by their key: the first label for most blocks,
+// the name and alias for providers, and the addresses of moved, import and removed blocks.
+// Each block type is sorted separately, except for variables and outputs which are sorted together.
+// Sorting is stable, so blocks with equal keys keep their original relative order.
+// The bodies of resource, data, module, provider, output and check blocks are put in canonical order;
+// see their layouts.
+// Blocks preceded by a "# tfsort:ignore" comment and regions fenced by "# tfsort:off" and
+// "# tfsort:on" comments keep their position and contents.
 func ProcessAndSortBlocks(
 	file *hclwrite.File,
 	allowedBlocks map[string]bool,
 ) *hclwrite.File {
-	for _, block := range file.Body().Blocks() {
-		switch block.Type() {
-		case "terraform":
-			sortRequiredProvidersInBlock(block)
-		case "locals":
-			sortLocalsBlock(block)
+	// Only sort key expressions can fail, and none are used here.
+	sorted, _, _ := processAndSortBlocks(file, sortOptions{allowedBlocks: allowedBlocks, compare: strings.Compare})
+	return sorted
+}
+
+// sortOptions controls how processAndSortBlocks sorts a file.
+type sortOptions struct {
+	// allowedBlocks lists the block types sorted by their keys.
+	allowedBlocks map[string]bool
+	// excludedBlocks lists block types that are never sorted, neither by key nor their contents.
+	excludedBlocks map[string]bool
+	// compare orders block labels and attribute names.
+	compare Comparator
+	// blockCompare overrides compare for individual block types.
+	blockCompare map[string]Comparator
+	// keyExprs holds expressions that compute the sort keys of individual block types.
+	keyExprs map[string]hcl.Expression
+	// sectionPattern matches the banner comments delimiting sections, which are sorted independently.
+	sectionPattern *regexp.Regexp
+	// groupByBlankLines sorts groups of items that are not separated by blank lines independently.
+	groupByBlankLines bool
+	// sectionHeaders holds the header comments inserted above the blocks of each listed type.
+	sectionHeaders map[string]string
+	// sortNestedBlocks sorts the nested blocks of resource, data and module bodies by type and labels.
+	sortNestedBlocks bool
+	// sortWorkspaceTags sorts the literal tags of the workspaces block of cloud blocks.
+	sortWorkspaceTags bool
+	// sortDependsOn sorts the references in depends_on lists.
+	sortDependsOn bool
+	// mapAttributes lists the attributes whose map literals are sorted by key.
+	mapAttributes map[string]bool
+	// sortObjectTypes sorts the attributes of object type constraints in the types of variables.
+	sortObjectTypes bool
+	// sortVariableDefaults sorts the keys of the maps and objects in the defaults of variables.
+	sortVariableDefaults bool
+	// localsDepth is the number of nesting levels of object literals in locals sorted by key.
+	localsDepth int
+	// mergeTerraformBlocks consolidates the terraform blocks of a file into the first one.
+	mergeTerraformBlocks bool
+	// localsOrder is the order of the assignments in locals blocks, LocalsOrderAlphabetical when empty.
+	localsOrder string
+	// resourceGrouping groups sorted resources, such as by ResourceGroupingProvider, when not empty.
+	resourceGrouping string
+	// minimalMoves keeps the original blank lines above blocks that are not moved.
+	minimalMoves bool
+	// blockOrder lists the block types in the order they are placed in files.
+	blockOrder []string
+	// normalizeVersions normalizes the literal version constraints of terraform and module blocks.
+	normalizeVersions bool
+	// providerSource is the form the source addresses of required providers are written in, if any.
+	providerSource string
+	// dialect is the dialect of the sorted files, DialectTerraform when empty.
+	dialect string
+	// blockSchemas declares how block types are sorted in the generic dialect.
+	blockSchemas map[string]BlockSchema
+	// blockSorters sorts the contents of blocks of individual types instead of the built-in sorting.
+	blockSorters map[string]BlockSortFunc
+	// pluginBlocks holds the block types whose contents were sorted by plugins.
+	pluginBlocks map[string]bool
+	// sortAssignments sorts top-level attributes by name, as in variable definitions files.
+	sortAssignments bool
+	// testFile keeps run blocks in place and sorts the assignments of variables blocks, as in test files.
+	testFile bool
+	// packerFile sorts source blocks and keeps build blocks in place and untouched, as in Packer templates.
+	packerFile bool
+	// keepBlockOrder keeps every top-level item in place, sorting only the contents of blocks, as in
+	// override files.
+	keepBlockOrder bool
+	// terramateFile sorts globals blocks like locals and the settings of stack blocks, as in Terramate files.
+	terramateFile bool
+	// bakeFile sorts target and group blocks and their arguments, as in Docker Buildx Bake files.
+	bakeFile bool
+	// sortAssignmentValues sorts the keys of the object literals assigned to sorted top-level attributes.
+	sortAssignmentValues bool
+	// blankLines is the number of blank lines between top-level blocks, one when zero.
+	blankLines int
+	// typeBlankLines is the number of blank lines between items of different types, blankLines when zero.
+	typeBlankLines int
+	// listPaths holds the dot-separated attribute paths whose string lists are sorted.
+	listPaths [][]string
+	// targets applies sort behaviors to the items at paths within top-level blocks.
+	targets []Target
+}
+
+// compareFor returns the Comparator used for blocks of the given type.
+func (o sortOptions) compareFor(blockType string) Comparator {
+	if compare, ok := o.blockCompare[blockType]; ok {
+		return compare
+	}
+	return o.compare
+}
+
+// nestedCompare returns the Comparator ordering the nested blocks of blocks of the given type, or
+// nil when nested blocks keep their original order.
+func (o sortOptions) nestedCompare(blockType string) Comparator {
+	if !o.sortNestedBlocks {
+		return nil
+	}
+	return o.compareFor(blockType)
+}
+
+// customized reports whether blocks of the given type have their own Comparator or key expression.
+func (o sortOptions) customized(blockType string) bool {
+	_, customCompare := o.blockCompare[blockType]
+	_, customKey := o.keyExprs[blockType]
+	return customCompare || customKey
+}
+
+// sortGroup returns the group a block type is sorted within.
+// Blocks of different groups are never interleaved. Variables and outputs share a group, so
+// they are ordered by name regardless of their type, unless either type is customized, section
+// headers are inserted or block types are placed in a configured order.
+func (o sortOptions) sortGroup(blockType string) string {
+	if blockType == "output" && !o.customized("variable") && !o.customized("output") && o.sectionHeaders == nil &&
+		o.blockOrder == nil {
+		return "variable"
+	}
+	return blockType
+}
+
+// blockSortKey returns the key a top-level block is sorted by, following its schema in the generic
+// dialect, or nil if the block cannot be sorted.
+func (o sortOptions) blockSortKey(block *hclwrite.Block) []string {
+	if o.dialect == DialectGeneric {
+		return genericSortKey(block, o.blockSchemas[block.Type()])
+	}
+	return blockSortKey(block)
+}
+
+// sortBlockContents sorts the body of a top-level block according to its type.
+func sortBlockContents(block *hclwrite.Block, opts sortOptions) {
+	blockType := block.Type()
+	compare := opts.compareFor(blockType)
+	exempt := opts.exemptions(block)
+
+	if opts.pluginBlocks[blockType] {
+		return
+	}
+	if sorter, ok := opts.blockSorters[blockType]; ok {
+		sorter(block, compare)
+		return
+	}
+	if opts.dialect == DialectGeneric {
+		if schema, ok := opts.blockSchemas[blockType]; ok {
+			sortGenericBlock(block, schema, compare)
 		}
+		return
+	}
+	if opts.normalizeVersions {
+		normalizeVersions(block)
+	}
+	if opts.providerSource != "" && blockType == "terraform" {
+		normalizeProviderSources(block, opts.providerSource)
+	}
+	if len(opts.mapAttributes) > 0 {
+		sortMapAttributes(block.Body(), opts.mapAttributes, compare, exempt)
+	}
+	if len(opts.listPaths) > 0 {
+		sortListPaths(block.Body(), []*hclwrite.Block{block}, opts.listPaths, compare, exempt)
+	}
+	if opts.sortDependsOn && slices.Contains([]string{"resource", "data", "module", "output"}, blockType) &&
+		!exempt.attributes[block.Body().GetAttribute("depends_on")] {
+		sortListAttribute(block.Body(), "depends_on", compare, false)
+	}
+	if len(opts.targets) > 0 {
+		applyTargets(block, opts.targets, compare, exempt)
 	}
 
+	switch blockType {
+	case "terraform":
+		sortTerraformBlock(block, compare, opts.sortWorkspaceTags)
+	case "locals":
+		sortLocalsBlock(block, compare, opts.localsDepth, opts.localsOrder == LocalsOrderTopological)
+	case "globals":
+		// Globals are evaluated lazily, wherever they are defined, so they are always alphabetized.
+		if opts.terramateFile {
+			sortLocalsBlock(block, compare, opts.localsDepth, false)
+		}
+	case "stack":
+		if opts.terramateFile {
+			sortBodyLayout(block.Body(), stackLayout, compare, nil)
+		}
+	case "module":
+		sortModuleParams(block, compare, opts.nestedCompare(blockType))
+	case "resource", "data":
+		sortResourceParams(block, compare, opts.nestedCompare(blockType))
+	case "provider":
+		sortProviderParams(block, compare)
+	case "check":
+		sortBodyLayout(block.Body(), checkLayout, compare, nil)
+	case "generate":
+		sortGeneratedContents(block, opts)
+	case "target", "group":
+		if opts.bakeFile {
+			sortBakeBlock(block, compare)
+		}
+	case "path":
+		if opts.dialect == DialectVault {
+			sortPolicyBlock(block, compare)
+		}
+	case "job":
+		if opts.dialect == DialectNomad {
+			sortJobBlock(block, compare)
+		}
+	case "run", "variables":
+		if opts.testFile {
+			sortTestBlock(block, compare)
+		}
+	case "output":
+		sortBodyLayout(block.Body(), outputLayout, compare, nil)
+	case "variable":
+		if opts.sortObjectTypes {
+			sortObjectAttribute(block.Body(), "type", compare, objectTypeOpening, anyDepth)
+		}
+		if opts.sortVariableDefaults {
+			sortObjectAttribute(block.Body(), "default", compare, objectLiteralOpening, anyDepth)
+		}
+	}
+}
+
+// processAndSortBlocks implements ProcessAndSortBlocks.
+// It returns warnings about duplicate blocks and blocks that could not be processed as requested, and
+// fails if a sort key expression cannot be evaluated.
+func processAndSortBlocks(file *hclwrite.File, opts sortOptions) (*hclwrite.File, []string, error) {
 	body := file.Body()
-	originalBlocks := body.Blocks()
+	if opts.sortAssignments && opts.sortAssignmentValues {
+		sortAssignmentValues(body, opts.compare)
+	}
+	lines := newBlockLines(body)
+	items := topLevelItems(body)
 
-	sortableItems := make([]*SortableBlock, 0)
-	otherBlocks := make([]*hclwrite.Block, 0)
+	for _, item := range items {
+		if item.block != nil && (hasBlockDirective(item.block, directiveIgnore) || opts.ignoredBlock(item.block)) {
+			item.pinned = true
+		}
+	}
+	if opts.packerFile {
+		// Build blocks and the provisioners within them keep their order.
+		pinBlocks(items, "build")
+	}
+	warnings := duplicateBlockWarnings(items, lines)
+	if opts.mergeTerraformBlocks && !opts.excludedBlocks["terraform"] {
+		var mergeWarnings []string
+		items, mergeWarnings = mergeTerraformItems(items, lines)
+		warnings = append(warnings, mergeWarnings...)
+	}
+	for _, item := range items {
+		if item.pinned || item.block == nil || opts.excludedBlocks[item.block.Type()] {
+			continue
+		}
+		sortBlockContents(item.block, opts)
+	}
+	if opts.testFile {
+		pinBlocks(items, "run")
+	}
+	if opts.keepBlockOrder {
+		for _, item := range items {
+			item.pinned = true
+		}
+	}
 
-	for _, block := range originalBlocks {
-		blockType := block.Type()
-		if allowedBlocks[blockType] && len(block.Labels()) > 0 {
-			sortableItems = append(sortableItems, &SortableBlock{
-				Name:  block.Labels()[0],
-				Block: block,
-			})
-		} else {
-			otherBlocks = append(otherBlocks, block)
+	if opts.sectionHeaders != nil {
+		// Banners delimit the sections of files sorted by sections, so they are only replaced otherwise.
+		banners := bannerPattern
+		if opts.sectionPattern != nil {
+			banners = nil
 		}
+		items = removeSectionHeaders(items, opts.sectionHeaders, banners)
+	}
+	sections := [][]*topLevelItem{items}
+	if opts.sectionPattern != nil {
+		sections = splitSections(items, opts.sectionPattern)
+	}
+	if opts.groupByBlankLines {
+		sections = splitGroupHeaders(splitBlankLineGroups(sections))
+	}
+	var orderedItems []*topLevelItem
+	for _, section := range sections {
+		sorted, err := sortItems(section, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		if opts.groupByBlankLines {
+			// Items of a group stay on adjacent lines, below the header of the group.
+			for i := 0; i < len(sorted)-1; i++ {
+				sorted[i].joinNext = sorted[i].joinNext || !sorted[i].groupHeader
+			}
+		}
+		if opts.resourceGrouping != "" && opts.allowedBlocks["resource"] && !opts.excludedBlocks["resource"] {
+			joinResourceGroups(sorted, opts.resourceGrouping)
+		}
+		if opts.sortAssignments {
+			joinAssignments(sorted)
+		}
+		orderedItems = append(orderedItems, sorted...)
+	}
+	if opts.sectionHeaders != nil {
+		orderedItems = insertSectionHeaders(orderedItems, opts.sectionHeaders)
 	}
 
-	sort.Slice(sortableItems, func(i, j int) bool {
-		return sortableItems[i].Name < sortableItems[j].Name
-	})
+	if opts.minimalMoves {
+		keepSeparators(items, orderedItems)
+	}
+	appendItems(body, orderedItems, opts)
 
+	return file, warnings, nil
+}
+
+// pinBlocks keeps the blocks of the given type among items in their original positions.
+func pinBlocks(items []*topLevelItem, blockType string) {
+	for _, item := range items {
+		if item.block != nil && item.block.Type() == blockType {
+			item.pinned = true
+		}
+	}
+}
+
+// appendItems replaces the contents of body with items, separated by blank lines unless joined or
+// keeping their original separator.
+func appendItems(body *hclwrite.Body, items []*topLevelItem, opts sortOptions) {
 	body.Clear()
+	for i, item := range items {
+		switch {
+		case i == 0:
+		case item.keepSeparator:
+			body.AppendUnstructuredTokens(item.separator)
+		case !items[i-1].joinNext:
+			for range opts.blankLinesBetween(items[i-1], item) {
+				body.AppendNewline()
+			}
+		}
+		item.appendTo(body)
+	}
+}
 
-	for i, block := range otherBlocks {
-		body.AppendBlock(block)
-		if i < len(otherBlocks)-1 || len(sortableItems) > 0 {
-			body.AppendNewline()
+// blankLinesBetween returns the number of blank lines separating item from the previous one.
+func (o sortOptions) blankLinesBetween(previous, item *topLevelItem) int {
+	if o.typeBlankLines > 0 && itemType(previous) != itemType(item) {
+		return o.typeBlankLines
+	}
+	return max(o.blankLines, 1)
+}
+
+// itemType returns the block type of item, or an empty string for items other than blocks.
+func itemType(item *topLevelItem) string {
+	if item.block == nil {
+		return ""
+	}
+	return item.block.Type()
+}
+
+// sortItems returns items with their sortable blocks ordered by key.
+// Pinned items keep their index, and other items keep their relative order before the sorted blocks.
+func sortItems(items []*topLevelItem, opts sortOptions) ([]*topLevelItem, error) {
+	sortableItems := make([]*SortableBlock, 0)
+	otherItems := make([]*topLevelItem, 0)
+	pinnedItems := make([]pinnedItem, 0)
+	itemsByBlock := make(map[*hclwrite.Block]*topLevelItem, len(items))
+
+	for index, item := range items {
+		if item.pinned {
+			pinnedItems = append(pinnedItems, pinnedItem{index: index, item: item})
+			continue
+		}
+		block := item.block
+		if block == nil {
+			// Top-level attributes keep their relative order, unless they are sorted below.
+			otherItems = append(otherItems, item)
+			continue
+		}
+		itemsByBlock[block] = item
+		key := opts.blockSortKey(block)
+		expr := opts.keyExprs[block.Type()]
+		if !opts.allowedBlocks[block.Type()] || opts.excludedBlocks[block.Type()] || (key == nil && expr == nil) {
+			otherItems = append(otherItems, item)
+			continue
+		}
+
+		sortable := &SortableBlock{Key: key, Block: block}
+		if key != nil {
+			sortable.Name = key[0]
+		}
+		if expr != nil {
+			var err error
+			if sortable.exprKey, err = evalSortKey(expr, block); err != nil {
+				return nil, err
+			}
 		}
+		sortableItems = append(sortableItems, sortable)
+	}
+
+	if opts.sortAssignments {
+		sortAssignments(otherItems, opts.compare)
 	}
 
-	for i, sb := range sortableItems {
-		body.AppendBlock(sb.Block)
-		if i < len(sortableItems)-1 {
-			body.AppendNewline()
+	// Groups keep the order in which they first appear; blocks are sorted by key within them.
+	groupRanks := make(map[string]int)
+	for _, sb := range sortableItems {
+		group := opts.sortGroup(sb.Block.Type())
+		if _, ok := groupRanks[group]; !ok {
+			groupRanks[group] = len(groupRanks)
 		}
 	}
+	// The sort is stable: blocks with equal keys, such as duplicates, keep their original relative order.
+	sort.SliceStable(sortableItems, func(i, j int) bool {
+		blockType := sortableItems[i].Block.Type()
+		rankI := groupRanks[opts.sortGroup(blockType)]
+		rankJ := groupRanks[opts.sortGroup(sortableItems[j].Block.Type())]
+		if rankI != rankJ {
+			return rankI < rankJ
+		}
+		// Blocks of a group share a type whenever that type is customized.
+		compare := opts.compareFor(blockType)
+		if opts.resourceGrouping != "" {
+			groupI := resourceGroup(sortableItems[i].Block, opts.resourceGrouping)
+			if c := compare(groupI, resourceGroup(sortableItems[j].Block, opts.resourceGrouping)); c != 0 {
+				return c < 0
+			}
+		}
+		if sortableItems[i].exprKey != nil {
+			return compareKeyValues(sortableItems[i].exprKey, sortableItems[j].exprKey, compare) < 0
+		}
+		return slices.CompareFunc(sortableItems[i].Key, sortableItems[j].Key, compare) < 0
+	})
+
+	orderedItems := otherItems
+	for _, sb := range sortableItems {
+		orderedItems = append(orderedItems, itemsByBlock[sb.Block])
+	}
+	if len(opts.blockOrder) > 0 {
+		orderByType(orderedItems, opts.blockOrder)
+	}
+	for _, pi := range pinnedItems {
+		orderedItems = slices.Insert(orderedItems, min(pi.index, len(orderedItems)), pi.item)
+	}
 
-	return file
+	return orderedItems, nil
+}
+
+// orderByType stably moves items next to the other items of their block type, placing types in the
+// given order. Block types missing from order follow in the order they first appear, and top-level
+// attributes come first. Within a type, blocks that are not sorted by key come before sorted ones.
+func orderByType(items []*topLevelItem, order []string) {
+	ranks := make(map[string]int, len(order))
+	for i, blockType := range order {
+		if _, ok := ranks[blockType]; !ok {
+			ranks[blockType] = i
+		}
+	}
+	next := len(order)
+	for _, item := range items {
+		if item.block == nil {
+			continue
+		}
+		if _, ok := ranks[item.block.Type()]; !ok {
+			ranks[item.block.Type()] = next
+			next++
+		}
+	}
+
+	rank := func(item *topLevelItem) int {
+		if item.block == nil {
+			return -1
+		}
+		return ranks[item.block.Type()]
+	}
+	slices.SortStableFunc(items, func(a, b *topLevelItem) int {
+		return rank(a) - rank(b)
+	})
 }
 
 // FormatHCLBytes formats the HCL file's content into a byte slice.
+// Regions fenced by "# tfsort:off" and "# tfsort:on" comments are left unformatted.
 func FormatHCLBytes(file *hclwrite.File) []byte {
-	return hclwrite.Format(file.Bytes())
+	raw := file.Bytes()
+	return restoreVerbatimRegions(raw, hclwrite.Format(raw))
 }
@@ -0,0 +1,166 @@
+package hclsort
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// DefaultSectionHeader is the template of the comments inserted above each block type's section.
+const DefaultSectionHeader = "# --- {{.Title}} ---"
+
+// sectionTitles holds the default titles of the sections of common block types.
+var sectionTitles = map[string]string{ //nolint:gochecknoglobals // Read-only lookup table
+	"check":     "Checks",
+	"data":      "Data Sources",
+	"import":    "Imports",
+	"locals":    "Locals",
+	"module":    "Modules",
+	"moved":     "Moved Blocks",
+	"output":    "Outputs",
+	"provider":  "Providers",
+	"removed":   "Removed Blocks",
+	"resource":  "Resources",
+	"terraform": "Terraform",
+	"variable":  "Variables",
+}
+
+// sectionHeaderData is the data a section header template is executed with.
+type sectionHeaderData struct {
+	// Type is the block type of the section, e.g. "variable".
+	Type string
+	// Title is the title of the section, e.g. "Variables".
+	Title string
+}
+
+// RenderSectionHeaders executes a text/template section header, such as DefaultSectionHeader,
+// for each block type and returns the resulting comments by block type. The template can refer
+// to {{.Type}} and {{.Title}}; titles overrides the default title of individual block types.
+// Every header must render to a single "#" or "//" line comment.
+func RenderSectionHeaders(header string, blockTypes []string, titles map[string]string) (map[string]string, error) {
+	tmpl, err := template.New("section_header").Option("missingkey=error").Parse(header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid section header template: %w", err)
+	}
+
+	headers := make(map[string]string, len(blockTypes))
+	for _, blockType := range blockTypes {
+		data := sectionHeaderData{Type: blockType, Title: sectionTitle(blockType, titles)}
+		var rendered strings.Builder
+		if err = tmpl.Execute(&rendered, data); err != nil {
+			return nil, fmt.Errorf("error rendering section header for '%s' blocks: %w", blockType, err)
+		}
+
+		text := strings.TrimSpace(rendered.String())
+		if strings.Contains(text, "\n") || !(strings.HasPrefix(text, "#") || strings.HasPrefix(text, "//")) {
+			return nil, fmt.Errorf(
+				"section header for '%s' blocks must be a single line comment, got %q",
+				blockType,
+				text,
+			)
+		}
+		headers[blockType] = text
+	}
+	return headers, nil
+}
+
+// sectionTitle returns the title of a block type's section.
+// Types without a default title use the capitalized type name.
+func sectionTitle(blockType string, titles map[string]string) string {
+	if title, ok := titles[blockType]; ok {
+		return title
+	}
+	if title, ok := sectionTitles[blockType]; ok {
+		return title
+	}
+	if blockType == "" {
+		return ""
+	}
+	return strings.ToUpper(blockType[:1]) + blockType[1:]
+}
+
+// bannerPattern matches the banner comments replaced by section headers, like DefaultSectionPattern.
+//
+//nolint:gochecknoglobals // Read-only pattern
+var bannerPattern = regexp.MustCompile(DefaultSectionPattern)
+
+// removeSectionHeaders drops previously inserted section headers from the comments between items,
+// so inserting them again after sorting does not duplicate them. Items consisting only of headers
+// are removed. Banner comments above items matching banners, such as "### Variables ###" or boxes
+// of banner lines around a title, are dropped too, as the inserted headers replace them rather than
+// being reordered with the blocks below them; the comments of the file header and trailer are kept.
+// A nil banners pattern keeps every banner.
+func removeSectionHeaders(items []*topLevelItem, headers map[string]string, banners *regexp.Regexp) []*topLevelItem {
+	known := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		known[header] = true
+	}
+	withoutHeaders := func(tokens hclwrite.Tokens) hclwrite.Tokens {
+		var kept hclwrite.Tokens
+		for _, token := range tokens {
+			if token.Type != hclsyntax.TokenComment || !known[string(bytes.TrimSpace(token.Bytes))] {
+				kept = append(kept, token)
+			}
+		}
+		return kept
+	}
+
+	result := make([]*topLevelItem, 0, len(items))
+	for _, item := range items {
+		if banners != nil {
+			if banner := splitBanner(item, banners); banner != nil {
+				// The comments above the banners of an item stay above it.
+				item.lead = append(withoutBanners(banner.tokens, banners), item.lead...)
+			}
+		}
+		item.lead = trimLeadingNewlines(withoutHeaders(item.lead))
+		if item.block == nil && item.verbatim == nil && item.pinned {
+			// The comments at the top and the bottom of the file.
+			if item.tokens = trimNewlines(withoutHeaders(item.tokens)); len(item.tokens) == 0 {
+				continue
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// withoutBanners returns tokens, the comments above an item ending with a banner matching banners,
+// without the comments from the first banner on.
+func withoutBanners(tokens hclwrite.Tokens, banners *regexp.Regexp) hclwrite.Tokens {
+	for i, token := range tokens {
+		if token.Type == hclsyntax.TokenComment && banners.Match(bytes.TrimSpace(token.Bytes)) {
+			return trimNewlines(tokens[:i])
+		}
+	}
+	return tokens
+}
+
+// insertSectionHeaders inserts the header of a block type above every run of its blocks.
+func insertSectionHeaders(items []*topLevelItem, headers map[string]string) []*topLevelItem {
+	result := make([]*topLevelItem, 0, len(items))
+	previousType := ""
+	for _, item := range items {
+		blockType := ""
+		if item.block != nil {
+			blockType = item.block.Type()
+		}
+		if header, ok := headers[blockType]; ok && blockType != previousType {
+			if len(result) > 0 {
+				// Headers are always separated from the item above them by a blank line.
+				result[len(result)-1].joinNext = false
+			}
+			result = append(result, &topLevelItem{
+				tokens: hclwrite.Tokens{{Type: hclsyntax.TokenComment, Bytes: []byte(header + "\n")}},
+			})
+		}
+		previousType = blockType
+		result = append(result, item)
+	}
+	return result
+}
@@ -1,23 +1,35 @@
 package hclsort
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"maps"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
 )
 
 // NewIngestor returns a new Ingestor instance with default allowed types and blocks.
 func NewIngestor() *Ingestor {
 	return &Ingestor{
 		AllowedTypes: map[string]bool{
-			"tf":   true,
-			"hcl":  true,
-			"tofu": true,
+			"tf":        true,
+			"hcl":       true,
+			"tofu":      true,
+			"tfvars":    true,
+			"tf.json":   true,
+			"tofu.json": true,
 		},
 		AllowedBlocks: map[string]bool{
 			"variable": true,
 			"output":   true,
 		},
+		GeneratedPattern: regexp.MustCompile(DefaultGeneratedPattern),
 	}
 }
 
@@ -28,33 +40,249 @@ func (i *Ingestor) Parse(
 	dryRun bool,
 	isStdin bool,
 ) error {
+	return i.ParseContext(context.Background(), inputPath, outputPath, dryRun, isStdin)
+}
+
+// ParseContext is like Parse, but stops before sorting or writing the file once ctx is done.
+func (i *Ingestor) ParseContext(
+	ctx context.Context,
+	inputPath string,
+	outputPath string,
+	dryRun bool,
+	isStdin bool,
+) error {
+	result, err := i.SortContext(ctx, inputPath, isStdin)
+	if err != nil {
+		return err
+	}
+
+	// Skipped files are left untouched rather than rewritten with identical content.
+	if result.Skipped && outputPath == "" && !dryRun && !isStdin {
+		return nil
+	}
+
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	return WriteSortedContent(inputPath, outputPath, dryRun, result.Sorted, isStdin)
+}
+
+// Sort reads, parses and sorts a Terraform/HCL file without writing the result anywhere.
+// Files whose header contains a "# tfsort:skip-file" comment or matches GeneratedPattern are
+// returned unchanged.
+func (i *Ingestor) Sort(inputPath string, isStdin bool) (*Result, error) {
+	return i.SortContext(context.Background(), inputPath, isStdin)
+}
+
+// SortContext is like Sort, but fails with the error of ctx once it is done.
+func (i *Ingestor) SortContext(ctx context.Context, inputPath string, isStdin bool) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	var src []byte
 	var err error
-	filenameForParser := inputPath
 
 	if isStdin {
 		src, err = io.ReadAll(os.Stdin)
 		if err != nil {
-			return fmt.Errorf("error reading from stdin: %w", err)
+			return nil, fmt.Errorf("error reading from stdin: %w", err)
 		}
 	} else {
 		if extErr := CheckFileExtension(inputPath, i.AllowedTypes); extErr != nil {
-			fmt.Fprintf(os.Stderr, "Warning: %v\n", extErr)
+			fmt.Fprintf(i.warnings(), "Warning: %v\n", extErr)
 		}
 		src, err = ReadFileBytes(inputPath)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	hclFile, err := ParseHCLContent(src, filenameForParser)
+	return i.sortSource(ctx, inputPath, src)
+}
+
+// sortSource sorts the content src of the file at inputPath like SortSource, printing the warnings
+// about it to the Warnings writer.
+func (i *Ingestor) sortSource(ctx context.Context, inputPath string, src []byte) (*Result, error) {
+	result, err := i.SortSourceContext(ctx, inputPath, src)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(i.warnings(), "Warning: %s: %s\n", inputPath, warning)
 	}
+	return result, nil
+}
 
-	processedFile := ProcessAndSortBlocks(hclFile, i.AllowedBlocks)
+// warnings returns the writer receiving the warnings printed by i, stderr unless Warnings is set.
+func (i *Ingestor) warnings() io.Writer {
+	if i.Warnings != nil {
+		return i.Warnings
+	}
+	return os.Stderr
+}
 
-	formattedBytes := FormatHCLBytes(processedFile)
+// SortSource parses and sorts src, the content of the file at inputPath, without reading or writing
+// any file. The name of the file selects how it is sorted, such as the assignments of .tfvars files.
+// Warnings are returned in the Result rather than printed.
+func (i *Ingestor) SortSource(inputPath string, src []byte) (*Result, error) {
+	return i.SortSourceContext(context.Background(), inputPath, src)
+}
 
-	return WriteSortedContent(inputPath, outputPath, dryRun, formattedBytes, isStdin)
+// SortSourceContext is like SortSource, but fails with the error of ctx when it is done before src is
+// parsed or sorted.
+func (i *Ingestor) SortSourceContext(ctx context.Context, inputPath string, src []byte) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if IsSkippedFile(filepath.Base(inputPath)) {
+		return &Result{Path: inputPath, Original: src, Sorted: src, Skipped: true}, nil
+	}
+	if header := headerComments(src); hasSkipFileDirective(header) || isGenerated(header, i.GeneratedPattern) {
+		return &Result{Path: inputPath, Original: src, Sorted: src, Skipped: true}, nil
+	}
+	if plugin := i.filePlugin(inputPath); plugin != nil {
+		return sortWithPlugin(ctx, plugin, inputPath, src)
+	}
+	override := i.OverrideFiles != OverrideFilesSort && isOverrideFile(inputPath)
+	if override && i.OverrideFiles == OverrideFilesSkip {
+		return &Result{Path: inputPath, Original: src, Sorted: src, Skipped: true}, nil
+	}
+	if isJSONConfig(inputPath) {
+		if override {
+			// Sorting JSON configuration files reorders blocks by their labels, which override files keep.
+			return &Result{Path: inputPath, Original: src, Sorted: src}, nil
+		}
+		return i.sortJSONSource(inputPath, src)
+	}
+
+	hclFile, err := ParseHCLContent(src, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	spacings := i.recordSpacing(hclFile)
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	opts := i.sortOptions()
+	opts.dialect = fileDialect(inputPath, opts.dialect)
+	profile := dialectProfiles[opts.dialect]
+	opts.sortAssignments = isVariablesFile(inputPath)
+	opts.sortAssignmentValues = opts.sortAssignments && i.SortTfvarsValues
+	opts.sortAssignments = opts.sortAssignments || profile.sortAssignments
+	opts.allowedBlocks = withBlocks(opts.allowedBlocks, profile.blocks...)
+	if opts.dialect == DialectGeneric {
+		// Only the declared block types are sorted, whatever the types selected for other dialects.
+		opts.allowedBlocks = genericBlockTypes(opts.blockSchemas)
+	}
+	opts.testFile = isTestFile(inputPath)
+	if opts.packerFile = isPackerFile(inputPath); opts.packerFile {
+		opts.allowedBlocks = withBlocks(opts.allowedBlocks, "source")
+	}
+	opts.terramateFile = isTerramateFile(inputPath)
+	opts.keepBlockOrder = override
+	if opts.bakeFile = isBakeFile(inputPath); opts.bakeFile {
+		opts.allowedBlocks = withBlocks(opts.allowedBlocks, "target", "group")
+	}
+	if plugins := i.blockPlugins(); plugins != nil {
+		if opts.pluginBlocks, err = sortPluginBlocks(ctx, inputPath, hclFile.Body(), plugins, opts); err != nil {
+			return nil, fmt.Errorf("error sorting '%s': %w", inputPath, err)
+		}
+	}
+	processedFile, warnings, err := processAndSortBlocks(hclFile, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error sorting '%s': %w", inputPath, err)
+	}
+	formattedBytes := i.render(processedFile, spacings)
+
+	return &Result{
+		Path:     inputPath,
+		Original: src,
+		Sorted:   append(bytes.TrimSpace(formattedBytes), '\n'),
+		Warnings: warnings,
+	}, nil
+}
+
+// withBlocks returns a copy of allowed, the block types sorted by key, that also includes blockTypes,
+// such as the source blocks of Packer templates.
+func withBlocks(allowed map[string]bool, blockTypes ...string) map[string]bool {
+	blocks := maps.Clone(allowed)
+	if blocks == nil {
+		blocks = map[string]bool{}
+	}
+	for _, blockType := range blockTypes {
+		blocks[blockType] = true
+	}
+	return blocks
+}
+
+// recordSpacing records the spacing of the tokens of file before it is sorted, when the spacing of
+// sorted files is kept as written; see render.
+func (i *Ingestor) recordSpacing(file *hclwrite.File) tokenSpacing {
+	if !i.PreserveFormatting && i.Alignment != AlignmentPreserve {
+		return nil
+	}
+	return newTokenSpacing(file)
+}
+
+// render returns the content of a sorted file, formatted unless PreserveFormatting is set, in which
+// case the tokens keep the spacing recorded in spacings before sorting. Otherwise equals signs are
+// aligned as set by Alignment.
+func (i *Ingestor) render(file *hclwrite.File, spacings tokenSpacing) []byte {
+	switch {
+	case i.PreserveFormatting:
+		return spacings.bytes(file, everyToken)
+	case i.Alignment == AlignmentPreserve:
+		return spacings.bytes(file, isEquals)
+	case i.Alignment == AlignmentNever:
+		return unalignedBytes(file)
+	default:
+		return FormatHCLBytes(file)
+	}
+}
+
+// sortOptions returns the options processAndSortBlocks sorts files with.
+func (i *Ingestor) sortOptions() sortOptions {
+	compare := i.Compare
+	if compare == nil {
+		compare = strings.Compare
+	}
+
+	listPaths := make([][]string, 0, len(i.ListPaths))
+	for _, path := range i.ListPaths {
+		listPaths = append(listPaths, strings.Split(path, "."))
+	}
+
+	return sortOptions{
+		allowedBlocks:        i.AllowedBlocks,
+		excludedBlocks:       i.ExcludedBlocks,
+		compare:              compare,
+		blockCompare:         i.BlockCompare,
+		keyExprs:             i.KeyExprs,
+		sectionPattern:       i.SectionPattern,
+		groupByBlankLines:    i.GroupByBlankLines,
+		sectionHeaders:       i.SectionHeaders,
+		sortNestedBlocks:     i.SortNestedBlocks,
+		sortWorkspaceTags:    i.SortWorkspaceTags,
+		sortDependsOn:        i.SortDependsOn,
+		mapAttributes:        i.MapAttributes,
+		sortObjectTypes:      i.SortObjectTypes,
+		sortVariableDefaults: i.SortVariableDefaults,
+		localsDepth:          i.LocalsDepth,
+		localsOrder:          i.LocalsOrder,
+		mergeTerraformBlocks: i.MergeTerraformBlocks,
+		resourceGrouping:     i.ResourceGrouping,
+		blockOrder:           i.BlockOrder,
+		normalizeVersions:    i.NormalizeVersions,
+		providerSource:       i.ProviderSource,
+		dialect:              i.Dialect,
+		blockSchemas:         i.BlockSchemas,
+		blockSorters:         i.BlockSorters,
+		blankLines:           i.BlankLines,
+		typeBlankLines:       i.TypeBlankLines,
+		minimalMoves:         i.MinimalMoves,
+		listPaths:            listPaths,
+		targets:              i.Targets,
+	}
 }
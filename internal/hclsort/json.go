@@ -0,0 +1,277 @@
+package hclsort
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// jsonCommentKey is the property name of comments in Terraform JSON configuration.
+const jsonCommentKey = "//"
+
+// jsonMember is a property of a JSON object.
+type jsonMember struct {
+	key   string
+	value any
+}
+
+// jsonObject is a JSON object whose properties keep their order.
+type jsonObject []jsonMember
+
+// isJSONConfig reports whether path names a JSON configuration file, such as main.tf.json or
+// main.tofu.json.
+func isJSONConfig(path string) bool {
+	fileType := FileType(path)
+	return fileType == "tf.json" || fileType == "tofu.json"
+}
+
+// sortJSONSource sorts the content src of the Terraform JSON configuration file at inputPath, such as
+// main.tf.json. The labels of the block types listed in AllowedBlocks and the entries of
+// required_providers are sorted by key; see sortJSONConfig. Files whose keys are already in order are
+// returned unchanged, and others are written with the indentation of their second line.
+func (i *Ingestor) sortJSONSource(inputPath string, src []byte) (*Result, error) {
+	root, err := parseJSON(src)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing JSON content from '%s': %w", inputPath, err)
+	}
+	config, ok := root.(jsonObject)
+	if !ok {
+		return nil, fmt.Errorf("error parsing JSON content from '%s': expected an object", inputPath)
+	}
+
+	result := &Result{Path: inputPath, Original: src, Sorted: src}
+	if sortJSONConfig(config, i.sortOptions()) {
+		var buf bytes.Buffer
+		writeJSON(&buf, config, jsonIndent(src), 0)
+		buf.WriteByte('\n')
+		result.Sorted = buf.Bytes()
+	}
+	return result, nil
+}
+
+// sortJSONConfig sorts the labels of the blocks of config whose type is allowed by opts, such as the
+// names of variables or the types and names of resources, and the entries of the required_providers
+// of terraform blocks. Comment properties stay first. It reports whether any keys were reordered.
+func sortJSONConfig(config jsonObject, opts sortOptions) bool {
+	changed := false
+	for _, member := range config {
+		blockType := member.key
+		if opts.excludedBlocks[blockType] {
+			continue
+		}
+		compare := opts.compareFor(blockType)
+		if blockType == "terraform" {
+			forEachJSONObject(member.value, func(terraform jsonObject) {
+				for _, nested := range terraform {
+					if nested.key == "required_providers" {
+						forEachJSONObject(nested.value, func(providers jsonObject) {
+							changed = sortJSONMembers(providers, compare) || changed
+						})
+					}
+				}
+			})
+			continue
+		}
+		if opts.allowedBlocks[blockType] {
+			changed = sortJSONLabels(member.value, jsonLabelCount(blockType), compare) || changed
+		}
+	}
+	return changed
+}
+
+// sortJSONLabels sorts the keys of value, and of the objects nested in it, up to the given number of
+// block labels. It reports whether any keys were reordered.
+func sortJSONLabels(value any, labels int, compare Comparator) bool {
+	if labels == 0 {
+		return false
+	}
+	changed := false
+	forEachJSONObject(value, func(object jsonObject) {
+		changed = sortJSONMembers(object, compare) || changed
+		for _, member := range object {
+			changed = sortJSONLabels(member.value, labels-1, compare) || changed
+		}
+	})
+	return changed
+}
+
+// jsonLabelCount returns the number of labels of blocks of the given type.
+func jsonLabelCount(blockType string) int {
+	switch blockType {
+	case "resource", "data", "ephemeral":
+		return 2
+	case "variable", "output", "module", "provider", "check":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// forEachJSONObject calls fn with value when it is an object, or with each object of value when it
+// is an array, as blocks of the same type may be given either way.
+func forEachJSONObject(value any, fn func(jsonObject)) {
+	switch value := value.(type) {
+	case jsonObject:
+		fn(value)
+	case []any:
+		for _, element := range value {
+			if object, ok := element.(jsonObject); ok {
+				fn(object)
+			}
+		}
+	}
+}
+
+// sortJSONMembers sorts the properties of object by key using compare, keeping comment properties
+// first. It reports whether they were reordered.
+func sortJSONMembers(object jsonObject, compare Comparator) bool {
+	order := func(a, b jsonMember) int {
+		if (a.key == jsonCommentKey) != (b.key == jsonCommentKey) {
+			if a.key == jsonCommentKey {
+				return -1
+			}
+			return 1
+		}
+		return compare(a.key, b.key)
+	}
+	if slices.IsSortedFunc(object, order) {
+		return false
+	}
+	slices.SortStableFunc(object, order)
+	return true
+}
+
+// parseJSON decodes a JSON document, keeping the order of the properties of its objects, which are
+// returned as jsonObject values. Numbers are returned as json.Number to keep their text.
+func parseJSON(src []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(src))
+	decoder.UseNumber()
+	value, err := decodeJSONValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected content after the top-level value")
+	}
+	return value, nil
+}
+
+// decodeJSONValue decodes the next value from decoder; see parseJSON.
+func decodeJSONValue(decoder *json.Decoder) (any, error) {
+	token, err := nextJSONToken(decoder)
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		object := jsonObject{}
+		for decoder.More() {
+			token, err = nextJSONToken(decoder)
+			if err != nil {
+				return nil, err
+			}
+			// Object keys are always decoded as strings.
+			key, _ := token.(string)
+			var value any
+			if value, err = decodeJSONValue(decoder); err != nil {
+				return nil, err
+			}
+			object = append(object, jsonMember{key: key, value: value})
+		}
+		_, err = nextJSONToken(decoder)
+		return object, err
+	case json.Delim('['):
+		array := []any{}
+		for decoder.More() {
+			var value any
+			if value, err = decodeJSONValue(decoder); err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = nextJSONToken(decoder)
+		return array, err
+	default:
+		return token, nil
+	}
+}
+
+// nextJSONToken returns the next token of decoder.
+func nextJSONToken(decoder *json.Decoder) (json.Token, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return token, nil
+}
+
+// writeJSON writes value to buf with each property and element on its own line, indented by indent
+// per level of nesting starting at depth.
+func writeJSON(buf *bytes.Buffer, value any, indent string, depth int) {
+	newline := func(depth int) {
+		buf.WriteByte('\n')
+		buf.WriteString(strings.Repeat(indent, depth))
+	}
+	switch value := value.(type) {
+	case jsonObject:
+		if len(value) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteByte('{')
+		for i, member := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			newline(depth + 1)
+			writeJSONScalar(buf, member.key)
+			buf.WriteString(": ")
+			writeJSON(buf, member.value, indent, depth+1)
+		}
+		newline(depth)
+		buf.WriteByte('}')
+	case []any:
+		if len(value) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteByte('[')
+		for i, element := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			newline(depth + 1)
+			writeJSON(buf, element, indent, depth+1)
+		}
+		newline(depth)
+		buf.WriteByte(']')
+	default:
+		writeJSONScalar(buf, value)
+	}
+}
+
+// writeJSONScalar writes a string, number, boolean or null to buf. Unlike json.Marshal, characters
+// such as < and > are not escaped, as they are common in Terraform expressions.
+func writeJSONScalar(buf *bytes.Buffer, value any) {
+	var scalar bytes.Buffer
+	encoder := json.NewEncoder(&scalar)
+	encoder.SetEscapeHTML(false)
+	// Decoded strings, numbers, booleans and nulls can always be encoded.
+	_ = encoder.Encode(value)
+	buf.Write(bytes.TrimSuffix(scalar.Bytes(), []byte("\n")))
+}
+
+// jsonIndent returns the indentation of the second line of src, or two spaces when it is not indented.
+func jsonIndent(src []byte) string {
+	lines := bytes.SplitN(src, []byte("\n"), 3)
+	if len(lines) > 1 {
+		if line := lines[1]; len(line) > len(bytes.TrimLeft(line, " \t")) {
+			return string(line[:len(line)-len(bytes.TrimLeft(line, " \t"))])
+		}
+	}
+	return "  "
+}
@@ -0,0 +1,146 @@
+package hclsort
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/tryfunc"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// keyExprContext returns the evaluation context of a sort key expression for block.
+// Expressions can refer to the block's type, its labels, and the names of the attributes
+// and nested blocks in its body, and call a small set of string and collection functions.
+func keyExprContext(block *hclwrite.Block) *hcl.EvalContext {
+	attributes := slices.Sorted(maps.Keys(block.Body().Attributes()))
+	nestedBlocks := make([]string, 0, len(block.Body().Blocks()))
+	for _, nested := range block.Body().Blocks() {
+		nestedBlocks = append(nestedBlocks, nested.Type())
+	}
+
+	return &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"type":       cty.StringVal(block.Type()),
+			"labels":     stringList(block.Labels()),
+			"attributes": stringList(attributes),
+			"blocks":     stringList(nestedBlocks),
+		},
+		Functions: map[string]function.Function{
+			"can":      tryfunc.CanFunc,
+			"contains": stdlib.ContainsFunc,
+			"join":     stdlib.JoinFunc,
+			"length":   stdlib.LengthFunc,
+			"lower":    stdlib.LowerFunc,
+			"regex":    stdlib.RegexFunc,
+			"substr":   stdlib.SubstrFunc,
+			"try":      tryfunc.TryFunc,
+			"upper":    stdlib.UpperFunc,
+		},
+	}
+}
+
+// stringList converts values to a cty list, which is empty rather than unknown when values is empty.
+func stringList(values []string) cty.Value {
+	if len(values) == 0 {
+		return cty.ListValEmpty(cty.String)
+	}
+	elements := make([]cty.Value, len(values))
+	for i, value := range values {
+		elements[i] = cty.StringVal(value)
+	}
+	return cty.ListVal(elements)
+}
+
+// evalSortKey evaluates a sort key expression for block.
+// The expression may produce a single string, number or bool, or a list or tuple of them.
+func evalSortKey(expr hcl.Expression, block *hclwrite.Block) ([]cty.Value, error) {
+	value, diags := expr.Value(keyExprContext(block))
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("error evaluating sort key of %s: %w", blockAddress(block), diags)
+	}
+
+	if !value.IsKnown() || value.IsNull() {
+		return nil, fmt.Errorf("sort key of %s must not be null", blockAddress(block))
+	}
+	if !value.Type().IsListType() && !value.Type().IsTupleType() {
+		return []cty.Value{value}, nil
+	}
+
+	key := make([]cty.Value, 0, value.LengthInt())
+	for it := value.ElementIterator(); it.Next(); {
+		_, element := it.Element()
+		key = append(key, element)
+	}
+	return key, nil
+}
+
+// compareKeyValues compares two keys produced by evalSortKey element by element.
+// Strings are ordered by compare, numbers by value and false sorts before true.
+// Elements of different types are ordered by type: null, numbers, strings, bools and others.
+func compareKeyValues(a, b []cty.Value, compare Comparator) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareKeyValue(a[i], b[i], compare); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// compareKeyValue compares two elements of keys produced by evalSortKey.
+func compareKeyValue(a, b cty.Value, compare Comparator) int {
+	if c := keyValueRank(a) - keyValueRank(b); c != 0 {
+		return c
+	}
+
+	switch {
+	case a.IsNull() || !a.IsKnown() || !b.IsKnown():
+		return 0
+	case a.Type() == cty.Number:
+		return a.AsBigFloat().Cmp(b.AsBigFloat())
+	case a.Type() == cty.String:
+		return compare(a.AsString(), b.AsString())
+	case a.Type() == cty.Bool:
+		return boolRank(a.True()) - boolRank(b.True())
+	default:
+		return strings.Compare(a.GoString(), b.GoString())
+	}
+}
+
+// keyValueRank orders key elements of different types.
+func keyValueRank(value cty.Value) int {
+	switch {
+	case value.IsNull():
+		return 0
+	case value.Type() == cty.Number:
+		return 1
+	case value.Type() == cty.String:
+		return 2
+	case value.Type() == cty.Bool:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// boolRank returns 1 for true and 0 for false.
+func boolRank(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// blockAddress returns a human readable reference to block, such as `resource "aws_vpc" "main"`.
+func blockAddress(block *hclwrite.Block) string {
+	parts := []string{block.Type()}
+	for _, label := range block.Labels() {
+		parts = append(parts, fmt.Sprintf("%q", label))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,236 @@
+package hclsort
+
+import (
+	"slices"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// anyName in a layoutSlot matches every attribute or nested block not matched by another slot.
+const anyName = "*"
+
+// layoutSlot is a position in the canonical order of a block body.
+// Exactly one of attribute and block is set.
+type layoutSlot struct {
+	// attribute matches the attribute with this name, or every other attribute when anyName.
+	attribute string
+	// block matches nested blocks of this type, or every other nested block when anyName.
+	block string
+	// byLabels sorts the nested blocks matching the slot by their labels instead of keeping their order.
+	byLabels bool
+}
+
+// bodyLayout is the canonical order of the items of a block body, one slot after another.
+// Attributes sharing a slot are sorted by name, nested blocks sharing a slot keep their order,
+// and items matching no slot are placed last.
+type bodyLayout []layoutSlot
+
+// outputLayout orders output blocks as description, value, sensitive, ephemeral, other
+// attributes and depends_on, followed by nested blocks with precondition blocks last.
+//
+//nolint:gochecknoglobals // Read-only layout
+var outputLayout = bodyLayout{
+	{attribute: "description"},
+	{attribute: "value"},
+	{attribute: "sensitive"},
+	{attribute: "ephemeral"},
+	{attribute: anyName},
+	{attribute: "depends_on"},
+	{block: anyName},
+	{block: "precondition"},
+}
+
+// moduleLayout orders module calls as source, version, count, for_each, the module's inputs,
+// providers and depends_on.
+//
+//nolint:gochecknoglobals // Read-only layout
+var moduleLayout = bodyLayout{
+	{attribute: "source"},
+	{attribute: "version"},
+	{attribute: "count"},
+	{attribute: "for_each"},
+	{attribute: anyName},
+	{attribute: "providers"},
+	{attribute: "depends_on"},
+	{block: anyName},
+}
+
+// providerLayout orders provider blocks as alias, the other attributes, and nested blocks such as
+// assume_role and default_tags in their original order.
+//
+//nolint:gochecknoglobals // Read-only layout
+var providerLayout = bodyLayout{
+	{attribute: "alias"},
+	{attribute: anyName},
+	{block: anyName},
+}
+
+// resourceLayout orders resource and data blocks as count, for_each, the other attributes, nested
+// blocks in their original order, provisioner and connection blocks, lifecycle and depends_on,
+// as recommended by the style guide.
+//
+//nolint:gochecknoglobals // Read-only layout
+var resourceLayout = bodyLayout{
+	{attribute: "count"},
+	{attribute: "for_each"},
+	{attribute: anyName},
+	{block: anyName},
+	{block: "provisioner"},
+	{block: "connection"},
+	{block: "lifecycle"},
+	{attribute: "depends_on"},
+}
+
+// terraformLayout orders terraform blocks as required_version, required_providers, backend, cloud,
+// experiments and provider_meta blocks sorted by provider name, followed by any other settings.
+//
+//nolint:gochecknoglobals // Read-only layout
+var terraformLayout = bodyLayout{
+	{attribute: "required_version"},
+	{block: "required_providers"},
+	{block: "backend"},
+	{block: "cloud"},
+	{attribute: "experiments"},
+	{block: "provider_meta", byLabels: true},
+	{attribute: anyName},
+	{block: anyName},
+}
+
+// cloudLayout orders cloud blocks as organization, hostname, other attributes and the workspaces block.
+//
+//nolint:gochecknoglobals // Read-only layout
+var cloudLayout = bodyLayout{
+	{attribute: "organization"},
+	{attribute: "hostname"},
+	{attribute: anyName},
+	{block: "workspaces"},
+	{block: anyName},
+}
+
+// checkLayout orders check blocks as the scoped data block followed by assert blocks in their
+// original order.
+//
+//nolint:gochecknoglobals // Read-only layout
+var checkLayout = bodyLayout{
+	{block: "data"},
+	{block: "assert"},
+	{attribute: anyName},
+	{block: anyName},
+}
+
+// dynamicLayout orders dynamic blocks as for_each, iterator, labels, and the content block.
+//
+//nolint:gochecknoglobals // Read-only layout
+var dynamicLayout = bodyLayout{
+	{attribute: "for_each"},
+	{attribute: "iterator"},
+	{attribute: "labels"},
+	{attribute: anyName},
+	{block: "content"},
+	{block: anyName},
+}
+
+// stackLayout orders the stack blocks of Terramate files as id, name, description, tags, the after
+// and before ordering constraints, wants, wanted_by, watch and any other settings.
+//
+//nolint:gochecknoglobals // Read-only layout
+var stackLayout = bodyLayout{
+	{attribute: "id"},
+	{attribute: "name"},
+	{attribute: "description"},
+	{attribute: "tags"},
+	{attribute: "after"},
+	{attribute: "before"},
+	{attribute: "wants"},
+	{attribute: "wanted_by"},
+	{attribute: "watch"},
+	{attribute: anyName},
+	{block: anyName},
+}
+
+// contentLayout orders the content blocks of dynamic blocks as alphabetized attributes followed by
+// nested blocks in their original order.
+//
+//nolint:gochecknoglobals // Read-only layout
+var contentLayout = bodyLayout{
+	{attribute: anyName},
+	{block: anyName},
+}
+
+// rank returns the index of the slot matching item.
+func (l bodyLayout) rank(item *bodyItem) int {
+	wildcard := len(l)
+	for i, slot := range l {
+		name, pattern := item.name, slot.attribute
+		if item.block != nil {
+			name, pattern = item.block.Type(), slot.block
+		}
+		switch pattern {
+		case name:
+			return i
+		case anyName:
+			wildcard = min(wildcard, i)
+		}
+	}
+	return wildcard
+}
+
+// sortBodyLayout orders the attributes and nested blocks of body according to layout, using
+// compare for attributes sharing a slot and for the labels of byLabels slots. Nested blocks sharing
+// the wildcard slot are sorted by
+// type and labels using blockCompare, or keep their order when blockCompare is nil.
+// Comments travel with the item below them. Bodies that are already in order are left untouched,
+// including their blank lines.
+func sortBodyLayout(body *hclwrite.Body, layout bodyLayout, compare, blockCompare Comparator) {
+	items, trailing := bodyItems(body)
+	order := func(a, b *bodyItem) int {
+		rank := layout.rank(a)
+		if c := rank - layout.rank(b); c != 0 {
+			return c
+		}
+		if a.block == nil || b.block == nil {
+			if a.block == nil && b.block == nil {
+				return compare(a.name, b.name)
+			}
+			return 0
+		}
+		switch {
+		case rank == len(layout):
+			return 0
+		case layout[rank].byLabels:
+			return slices.CompareFunc(a.block.Labels(), b.block.Labels(), compare)
+		case layout[rank].block == anyName && blockCompare != nil:
+			return slices.CompareFunc(blockTypeAndLabels(a.block), blockTypeAndLabels(b.block), blockCompare)
+		default:
+			return 0
+		}
+	}
+	if slices.IsSortedFunc(items, order) {
+		return
+	}
+	slices.SortStableFunc(items, order)
+	rebuildBody(body, items, trailing)
+}
+
+// blockTypeAndLabels returns the type of block followed by its labels.
+func blockTypeAndLabels(block *hclwrite.Block) []string {
+	return append([]string{block.Type()}, block.Labels()...)
+}
+
+// sortDynamicBlocks orders the dynamic blocks nested anywhere in body, keeping for_each and iterator
+// at the top and sorting the attributes of their content blocks using compare.
+func sortDynamicBlocks(body *hclwrite.Body, compare Comparator) {
+	for _, block := range body.Blocks() {
+		// Nested bodies are sorted first, as sorting a body turns its nested blocks into plain tokens.
+		sortDynamicBlocks(block.Body(), compare)
+		if block.Type() != "dynamic" {
+			continue
+		}
+		for _, content := range block.Body().Blocks() {
+			if content.Type() == "content" {
+				sortBodyLayout(content.Body(), contentLayout, compare, nil)
+			}
+		}
+		sortBodyLayout(block.Body(), dynamicLayout, compare, nil)
+	}
+}
@@ -0,0 +1,54 @@
+package hclsort
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// blockLines holds the lines on which the top-level blocks of a file start in its source, used to
+// point at blocks in warnings.
+type blockLines map[*hclwrite.Block]int
+
+// newBlockLines records the lines of the top-level blocks of body. It must be called before body is
+// modified.
+func newBlockLines(body *hclwrite.Body) blockLines {
+	starts := map[*hclwrite.Token]*hclwrite.Block{}
+	for _, block := range body.Blocks() {
+		tokens := block.BuildTokens(nil)
+		if count := leadTokenCount(tokens); count < len(tokens) {
+			starts[tokens[count]] = block
+		}
+	}
+
+	lines := make(blockLines, len(starts))
+	line := 1
+	for _, token := range body.BuildTokens(nil) {
+		if block, ok := starts[token]; ok {
+			lines[block] = line
+		}
+		line += bytes.Count(token.Bytes, []byte("\n"))
+	}
+	return lines
+}
+
+// describe returns the lines of blocks in ascending order, such as "lines 3, 7 and 12".
+func (l blockLines) describe(blocks []*hclwrite.Block) string {
+	numbers := make([]int, 0, len(blocks))
+	for _, block := range blocks {
+		numbers = append(numbers, l[block])
+	}
+	slices.Sort(numbers)
+
+	texts := make([]string, len(numbers))
+	for i, number := range numbers {
+		texts[i] = fmt.Sprint(number)
+	}
+	if len(texts) == 1 {
+		return "line " + texts[0]
+	}
+	return fmt.Sprintf("lines %s and %s", strings.Join(texts[:len(texts)-1], ", "), texts[len(texts)-1])
+}
@@ -0,0 +1,177 @@
+package hclsort
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// listElement is an element of a list literal.
+type listElement struct {
+	// tokens holds the element's tokens without surrounding newlines.
+	tokens hclwrite.Tokens
+	// key is the text the element is sorted by.
+	key string
+}
+
+// sortListAttribute sorts the elements of the list literal assigned to the named attribute of body
+// using compare. With stringsOnly, only lists whose elements are all literal strings, without
+// interpolations, are sorted. Lists containing comments are left untouched, as are expressions
+// other than list literals. Lists spanning multiple lines keep one element per line.
+func sortListAttribute(body *hclwrite.Body, name string, compare Comparator, stringsOnly bool) {
+	attr := body.GetAttribute(name)
+	if attr == nil {
+		return
+	}
+
+	elements, multiline, trailingComma, ok := listElements(attr.Expr().BuildTokens(nil))
+	if !ok || len(elements) < 2 {
+		return
+	}
+	if stringsOnly && slices.ContainsFunc(elements, func(element listElement) bool {
+		return !isStringLiteral(element.tokens)
+	}) {
+		return
+	}
+	sorted := slices.Clone(elements)
+	slices.SortStableFunc(sorted, func(a, b listElement) int {
+		return compare(a.key, b.key)
+	})
+	if slices.EqualFunc(elements, sorted, func(a, b listElement) bool { return a.key == b.key }) {
+		return
+	}
+
+	tokens := hclwrite.Tokens{{Type: hclsyntax.TokenOBrack, Bytes: []byte("[")}}
+	for i, element := range sorted {
+		if multiline {
+			tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")})
+		}
+		tokens = append(tokens, element.tokens...)
+		if i < len(sorted)-1 || trailingComma {
+			tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenComma, Bytes: []byte(",")})
+		}
+	}
+	if multiline {
+		tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")})
+	}
+	tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenCBrack, Bytes: []byte("]")})
+	body.SetAttributeRaw(name, tokens)
+}
+
+// listElements splits the tokens of a list literal into its elements. It reports whether the list
+// spans multiple lines and ends with a comma, and fails for other expressions and for lists
+// containing comments.
+func listElements(tokens hclwrite.Tokens) ([]listElement, bool, bool, bool) {
+	tokens = trimNewlines(tokens)
+	if len(tokens) < 2 || tokens[0].Type != hclsyntax.TokenOBrack || tokens[len(tokens)-1].Type != hclsyntax.TokenCBrack {
+		return nil, false, false, false
+	}
+
+	var elements []listElement
+	multiline, trailingComma := false, false
+	depth, start := 0, 1
+	appendElement := func(end int) {
+		if element := trimNewlines(tokens[start:end]); len(element) > 0 {
+			elements = append(elements, listElement{tokens: element, key: elementKey(element)})
+		}
+	}
+	for i, token := range tokens[1 : len(tokens)-1] {
+		index := i + 1
+		switch token.Type {
+		case hclsyntax.TokenComment:
+			return nil, false, false, false
+		case hclsyntax.TokenOBrack, hclsyntax.TokenOBrace, hclsyntax.TokenOParen, hclsyntax.TokenOQuote,
+			hclsyntax.TokenOHeredoc, hclsyntax.TokenTemplateInterp, hclsyntax.TokenTemplateControl:
+			depth++
+		case hclsyntax.TokenCBrack, hclsyntax.TokenCBrace, hclsyntax.TokenCParen, hclsyntax.TokenCQuote,
+			hclsyntax.TokenCHeredoc, hclsyntax.TokenTemplateSeqEnd:
+			depth--
+		case hclsyntax.TokenNewline:
+			multiline = multiline || depth == 0
+		case hclsyntax.TokenComma:
+			if depth == 0 {
+				appendElement(index)
+				start = index + 1
+				trailingComma = true
+				continue
+			}
+		}
+		if token.Type != hclsyntax.TokenNewline {
+			trailingComma = false
+		}
+	}
+	appendElement(len(tokens) - 1)
+
+	return elements, multiline, trailingComma, true
+}
+
+// elementKey returns the text a list element is sorted by: the contents of string literals, and
+// the source text of other expressions.
+func elementKey(tokens hclwrite.Tokens) string {
+	if isStringLiteral(tokens) {
+		if len(tokens) == 2 {
+			return ""
+		}
+		return string(tokens[1].Bytes)
+	}
+	return strings.TrimSpace(string(tokens.Bytes()))
+}
+
+// isStringLiteral reports whether tokens form a quoted string without interpolations.
+func isStringLiteral(tokens hclwrite.Tokens) bool {
+	switch len(tokens) {
+	case 2:
+		return tokens[0].Type == hclsyntax.TokenOQuote && tokens[1].Type == hclsyntax.TokenCQuote
+	case 3:
+		return tokens[0].Type == hclsyntax.TokenOQuote && tokens[1].Type == hclsyntax.TokenQuotedLit &&
+			tokens[2].Type == hclsyntax.TokenCQuote
+	default:
+		return false
+	}
+}
+
+// sortListPaths sorts the string list literals assigned to attributes of body matching any of paths.
+// A path is an attribute name, optionally preceded by the names of the blocks enclosing it, such as
+// aws_iam_role.managed_policy_arns; the first label or the type of a block is its name. ancestors
+// holds the blocks enclosing body, outermost first. Exempt attributes and blocks are left untouched.
+func sortListPaths(
+	body *hclwrite.Body,
+	ancestors []*hclwrite.Block,
+	paths [][]string,
+	compare Comparator,
+	exempt exemptions,
+) {
+	for _, block := range body.Blocks() {
+		if !exempt.blocks[block] {
+			sortListPaths(block.Body(), append(slices.Clip(ancestors), block), paths, compare, exempt)
+		}
+	}
+	for name, attr := range body.Attributes() {
+		if exempt.attributes[attr] {
+			continue
+		}
+		for _, path := range paths {
+			if path[len(path)-1] == name && matchesAncestors(path[:len(path)-1], ancestors) {
+				sortListAttribute(body, name, compare, true)
+				break
+			}
+		}
+	}
+}
+
+// matchesAncestors reports whether the innermost ancestors are named by segments, in order.
+func matchesAncestors(segments []string, ancestors []*hclwrite.Block) bool {
+	if len(segments) > len(ancestors) {
+		return false
+	}
+	ancestors = ancestors[len(ancestors)-len(segments):]
+	for i, segment := range segments {
+		labels := ancestors[i].Labels()
+		if segment != ancestors[i].Type() && (len(labels) == 0 || segment != labels[0]) {
+			return false
+		}
+	}
+	return true
+}
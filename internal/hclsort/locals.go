@@ -0,0 +1,106 @@
+package hclsort
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Orders of the assignments in locals blocks.
+const (
+	// LocalsOrderAlphabetical sorts locals by name.
+	LocalsOrderAlphabetical = "alphabetical"
+	// LocalsOrderTopological defines locals before the locals of the same block referring to them,
+	// sorting by name otherwise.
+	LocalsOrderTopological = "topological"
+)
+
+// sortLocalsTopologically orders the assignments of a locals body so that every local is defined
+// before the locals referring to it, choosing the first by compare among those whose dependencies
+// are all defined. When only locals in or depending on a reference cycle remain, the first of them
+// by compare is placed next.
+// Comments above an assignment move with it.
+func sortLocalsTopologically(body *hclwrite.Body, compare Comparator) {
+	items, trailing := bodyItems(body)
+
+	var attributes []*bodyItem
+	var slots []int
+	for i, item := range items {
+		if item.block == nil {
+			attributes = append(attributes, item)
+			slots = append(slots, i)
+		}
+	}
+
+	dependencies := localDependencies(body)
+	defined := make(map[string]bool, len(attributes))
+	remaining := slices.Clone(attributes)
+	slices.SortStableFunc(remaining, func(a, b *bodyItem) int {
+		return compare(a.name, b.name)
+	})
+	ordered := make([]*bodyItem, 0, len(attributes))
+	for len(remaining) > 0 {
+		next := slices.IndexFunc(remaining, func(item *bodyItem) bool {
+			return !slices.ContainsFunc(dependencies[item.name], func(name string) bool {
+				return !defined[name]
+			})
+		})
+		if next < 0 {
+			// Every remaining local is part of or depends on a cycle.
+			next = 0
+		}
+		defined[remaining[next].name] = true
+		ordered = append(ordered, remaining[next])
+		remaining = slices.Delete(remaining, next, next+1)
+	}
+	if slices.Equal(ordered, attributes) {
+		return
+	}
+	for i, slot := range slots {
+		items[slot] = ordered[i]
+	}
+
+	rebuildBody(body, items, trailing)
+}
+
+// localDependencies returns the names of the other locals of body that each local refers to.
+// Expressions that cannot be parsed are assumed to refer to no locals.
+func localDependencies(body *hclwrite.Body) map[string][]string {
+	attributes := body.Attributes()
+	dependencies := make(map[string][]string, len(attributes))
+	for name, attr := range attributes {
+		expr, diags := hclsyntax.ParseExpression(attr.Expr().BuildTokens(nil).Bytes(), "", hcl.InitialPos)
+		if diags.HasErrors() {
+			continue
+		}
+		for _, traversal := range expr.Variables() {
+			if traversal.RootName() != "local" || len(traversal) < 2 {
+				continue
+			}
+			step, ok := traversal[1].(hcl.TraverseAttr)
+			if !ok || step.Name == name || attributes[step.Name] == nil {
+				continue
+			}
+			dependencies[name] = append(dependencies[name], step.Name)
+		}
+	}
+	return dependencies
+}
+
+// ValidateLocalsOrder checks that order names a known order of locals assignments.
+func ValidateLocalsOrder(order string) error {
+	switch order {
+	case LocalsOrderAlphabetical, LocalsOrderTopological:
+		return nil
+	default:
+		return fmt.Errorf(
+			"unknown locals order '%s', expected '%s' or '%s'",
+			order,
+			LocalsOrderAlphabetical,
+			LocalsOrderTopological,
+		)
+	}
+}
@@ -0,0 +1,146 @@
+package hclsort
+
+import (
+	"slices"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// DefaultMapAttributes lists the attributes whose map literals are sorted by key by default.
+//
+//nolint:gochecknoglobals // Read-only default
+var DefaultMapAttributes = []string{"tags", "labels", "default_tags"}
+
+// sortMapAttributes sorts the keys of the map literals assigned to the named attributes anywhere in
+// body, including nested blocks, using compare. Exempt attributes and blocks are left untouched.
+func sortMapAttributes(body *hclwrite.Body, names map[string]bool, compare Comparator, exempt exemptions) {
+	for _, block := range body.Blocks() {
+		if !exempt.blocks[block] {
+			sortMapAttributes(block.Body(), names, compare, exempt)
+		}
+	}
+	for name, attr := range body.Attributes() {
+		if names[name] && !exempt.attributes[attr] {
+			sortMapAttribute(body, name, compare)
+		}
+	}
+}
+
+// sortMapAttribute sorts the keys of the map literal assigned to the named attribute of body.
+// Maps whose keys are not all literal names or strings, maps containing comments and lines holding
+// more than one element are left untouched.
+func sortMapAttribute(body *hclwrite.Body, name string, compare Comparator) {
+	tokens := trimNewlines(body.GetAttribute(name).Expr().BuildTokens(nil))
+	if len(tokens) < 2 || tokens[0].Type != hclsyntax.TokenOBrace || tokens[len(tokens)-1].Type != hclsyntax.TokenCBrace {
+		return
+	}
+	inner := tokens[1 : len(tokens)-1]
+	if slices.ContainsFunc(inner, func(token *hclwrite.Token) bool {
+		return token.Type == hclsyntax.TokenComment
+	}) {
+		return
+	}
+
+	multiline := slices.ContainsFunc(inner, func(token *hclwrite.Token) bool {
+		return token.Type == hclsyntax.TokenNewline
+	})
+	separator := hclsyntax.TokenComma
+	if multiline {
+		separator = hclsyntax.TokenNewline
+	}
+	elements, ok := mapElements(inner, separator)
+	if !ok || len(elements) < 2 {
+		return
+	}
+
+	sorted := slices.Clone(elements)
+	slices.SortStableFunc(sorted, func(a, b listElement) int {
+		return compare(a.key, b.key)
+	})
+	if slices.EqualFunc(elements, sorted, func(a, b listElement) bool { return a.key == b.key }) {
+		return
+	}
+
+	result := hclwrite.Tokens{tokens[0]}
+	for i, element := range sorted {
+		switch {
+		case multiline:
+			result = append(result, &hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")})
+		case i > 0:
+			result = append(result, &hclwrite.Token{Type: hclsyntax.TokenComma, Bytes: []byte(",")})
+		}
+		result = append(result, element.tokens...)
+	}
+	if multiline {
+		result = append(result, &hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")})
+	}
+	result = append(result, tokens[len(tokens)-1])
+	body.SetAttributeRaw(name, result)
+}
+
+// mapElements splits the tokens between the braces of a map literal at separator tokens outside
+// nested expressions. Each element is keyed by its literal key; it fails when a key is not a name
+// or a string literal, or when a multi-line map holds more than one element on a line.
+func mapElements(tokens hclwrite.Tokens, separator hclsyntax.TokenType) ([]listElement, bool) {
+	var elements []listElement
+	depth, start := 0, 0
+	appendElement := func(end int) bool {
+		element := trimNewlines(tokens[start:end])
+		if len(element) == 0 {
+			return true
+		}
+		key, ok := mapKey(element)
+		if !ok {
+			return false
+		}
+		elements = append(elements, listElement{tokens: element, key: key})
+		return true
+	}
+
+	for i, token := range tokens {
+		switch token.Type {
+		case hclsyntax.TokenOBrack, hclsyntax.TokenOBrace, hclsyntax.TokenOParen, hclsyntax.TokenOQuote,
+			hclsyntax.TokenOHeredoc, hclsyntax.TokenTemplateInterp, hclsyntax.TokenTemplateControl:
+			depth++
+		case hclsyntax.TokenCBrack, hclsyntax.TokenCBrace, hclsyntax.TokenCParen, hclsyntax.TokenCQuote,
+			hclsyntax.TokenCHeredoc, hclsyntax.TokenTemplateSeqEnd:
+			depth--
+		case hclsyntax.TokenComma:
+			// A comma ending a line of a multi-line map stays part of the line.
+			if depth == 0 && separator == hclsyntax.TokenNewline && i+1 < len(tokens) &&
+				tokens[i+1].Type != hclsyntax.TokenNewline {
+				return nil, false
+			}
+		}
+		if depth == 0 && token.Type == separator {
+			if !appendElement(i) {
+				return nil, false
+			}
+			start = i + 1
+		}
+	}
+	if !appendElement(len(tokens)) {
+		return nil, false
+	}
+	return elements, true
+}
+
+// mapKey returns the literal key of a map element, such as Name in `Name = "web"` or `"Name" = "web"`.
+func mapKey(element hclwrite.Tokens) (string, bool) {
+	for i, token := range element {
+		if token.Type != hclsyntax.TokenEqual && token.Type != hclsyntax.TokenColon {
+			continue
+		}
+		key := element[:i]
+		switch {
+		case len(key) == 1 && key[0].Type == hclsyntax.TokenIdent:
+			return string(key[0].Bytes), true
+		case isStringLiteral(key):
+			return elementKey(key), true
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,177 @@
+package hclsort
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// mergeTerraformItems consolidates the terraform blocks among items into the first of them.
+// Pinned and excluded blocks are left alone. Blocks setting an attribute or required provider to
+// different values, or configuring more than one backend or cloud block, are not merged; a warning
+// describing the conflict is returned instead.
+func mergeTerraformItems(items []*topLevelItem, lines blockLines) ([]*topLevelItem, []string) {
+	var merged []*topLevelItem
+	for _, item := range items {
+		if item.block != nil && item.block.Type() == "terraform" && !item.pinned && item.skipTokens == 0 {
+			merged = append(merged, item)
+		}
+	}
+	if len(merged) < 2 {
+		return items, nil
+	}
+
+	blocks := make([]*hclwrite.Block, len(merged))
+	for i, item := range merged {
+		blocks[i] = item.block
+	}
+	conflict := terraformConflict(blocks)
+	if conflict == "" {
+		block, err := mergeTerraformBlocks(merged)
+		if err == nil {
+			merged[0].block = block
+			return slices.DeleteFunc(items, func(item *topLevelItem) bool {
+				return slices.Contains(merged[1:], item)
+			}), nil
+		}
+		conflict = err.Error()
+	}
+	return items, []string{fmt.Sprintf("terraform blocks at %s were not merged: %s", lines.describe(blocks), conflict)}
+}
+
+// terraformConflict describes why the terraform blocks cannot be merged, or returns an empty string
+// when they can. Attributes and required providers set to the same value in several blocks are not
+// conflicts.
+func terraformConflict(blocks []*hclwrite.Block) string {
+	attributes := map[string]string{}
+	providers := map[string]string{}
+	backends := 0
+	for _, block := range blocks {
+		for name, attr := range block.Body().Attributes() {
+			if conflict := setOnce(attributes, name, attr); conflict {
+				return fmt.Sprintf("'%s' is set to different values", name)
+			}
+		}
+		for _, nested := range block.Body().Blocks() {
+			switch nested.Type() {
+			case "backend", "cloud":
+				backends++
+				if backends > 1 {
+					return "more than one backend or cloud block is configured"
+				}
+			case "required_providers":
+				for name, attr := range nested.Body().Attributes() {
+					if conflict := setOnce(providers, name, attr); conflict {
+						return fmt.Sprintf("provider '%s' is required with different settings", name)
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// setOnce records the value of the attribute in values and reports whether a different value was
+// recorded for the same name before.
+func setOnce(values map[string]string, name string, attr *hclwrite.Attribute) bool {
+	value := strings.Join(strings.Fields(string(attr.Expr().BuildTokens(nil).Bytes())), " ")
+	previous, ok := values[name]
+	values[name] = value
+	return ok && previous != value
+}
+
+// mergeTerraformBlocks returns a terraform block holding the contents of the blocks of items in
+// order, with their required_providers blocks combined into the first one. Attributes and required
+// providers repeated with the same value are kept once. Comments above the blocks after the first one
+// are moved into the merged block.
+func mergeTerraformBlocks(items []*topLevelItem) (*hclwrite.Block, error) {
+	// The merged block is assembled as source text, so comments are kept and the result is parsed
+	// into attributes and blocks that can be sorted.
+	var contents, providers bytes.Buffer
+	seen := map[string]bool{}
+	providersAt := -1
+	for i, item := range items {
+		if i > 0 {
+			contents.Write(item.lead.Bytes())
+			contents.Write(leadComments(item.block).Bytes())
+		}
+		entries, trailing := bodyItems(item.block.Body())
+		for _, entry := range entries {
+			switch {
+			case entry.block != nil && entry.block.Type() == "required_providers":
+				// Comments above the first required_providers block stay above the combined block.
+				comments := entry.tokens[:leadTokenCount(entry.tokens)]
+				if providersAt < 0 {
+					contents.Write(comments.Bytes())
+					providersAt = contents.Len()
+				} else {
+					providers.Write(comments.Bytes())
+				}
+				writeProviders(&providers, entry, seen)
+			case entry.block == nil && seen["attribute:"+entry.name]:
+				// The attribute is repeated with the same value.
+			default:
+				if entry.block == nil {
+					seen["attribute:"+entry.name] = true
+				}
+				writeLine(&contents, entry.tokens)
+			}
+		}
+		if len(trailing) > 0 {
+			writeLine(&contents, trailing)
+		}
+	}
+
+	src := contents.Bytes()
+	if providersAt >= 0 {
+		src = slices.Concat(src[:providersAt], []byte("required_providers {\n"), providers.Bytes(),
+			[]byte("}\n"), src[providersAt:])
+	}
+	file, diags := hclwrite.ParseConfig(slices.Concat(
+		items[0].block.BuildTokens(nil)[:headerLength(items[0].block)].Bytes(),
+		[]byte("{\n"), src, []byte("}\n"),
+	), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("error parsing merged terraform block: %w", diags)
+	}
+	return file.Body().Blocks()[0], nil
+}
+
+// writeProviders writes the entries of a required_providers block to buf, skipping providers already
+// written.
+func writeProviders(buf *bytes.Buffer, block *bodyItem, seen map[string]bool) {
+	entries, trailing := bodyItems(block.block.Body())
+	for _, entry := range entries {
+		if entry.block == nil {
+			if seen["provider:"+entry.name] {
+				continue
+			}
+			seen["provider:"+entry.name] = true
+		}
+		writeLine(buf, entry.tokens)
+	}
+	if len(trailing) > 0 {
+		writeLine(buf, trailing)
+	}
+}
+
+// writeLine writes tokens to buf and ends the line unless they already do.
+func writeLine(buf *bytes.Buffer, tokens hclwrite.Tokens) {
+	buf.Write(tokens.Bytes())
+	if !endsLine(tokens[len(tokens)-1]) {
+		buf.WriteByte('\n')
+	}
+}
+
+// headerLength returns the number of tokens of block before the opening brace of its body,
+// including the comments directly above it.
+func headerLength(block *hclwrite.Block) int {
+	return slices.IndexFunc(block.BuildTokens(nil), func(token *hclwrite.Token) bool {
+		return token.Type == hclsyntax.TokenOBrace
+	})
+}
@@ -0,0 +1,59 @@
+package hclsort
+
+import "sort"
+
+// keepSeparators marks the blocks of ordered that keep the blank lines above them because they are
+// not moved: the blocks of the longest subsequence of ordered that is in original order, unless they
+// were the first item or now follow a comment or attribute. Other items are separated as usual.
+func keepSeparators(original, ordered []*topLevelItem) {
+	index := make(map[*topLevelItem]int, len(original))
+	for i, item := range original {
+		index[item] = i
+	}
+	var positions, sequence []int
+	for position, item := range ordered {
+		if i, ok := index[item]; ok {
+			positions = append(positions, position)
+			sequence = append(sequence, i)
+		}
+	}
+
+	kept := make([]bool, len(ordered))
+	for _, i := range longestIncreasing(sequence) {
+		kept[positions[i]] = true
+	}
+	for i := 1; i < len(ordered); i++ {
+		if kept[i] && ordered[i].separator != nil && ordered[i].block != nil && ordered[i-1].block != nil {
+			ordered[i].keepSeparator = true
+		}
+	}
+}
+
+// longestIncreasing returns the indices of a longest strictly increasing subsequence of values, in
+// ascending order.
+func longestIncreasing(values []int) []int {
+	// tails[k] is the index of the smallest value ending an increasing subsequence of length k+1.
+	var tails []int
+	previous := make([]int, len(values))
+	for i, value := range values {
+		k := sort.Search(len(tails), func(k int) bool { return values[tails[k]] >= value })
+		previous[i] = -1
+		if k > 0 {
+			previous[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+
+	result := make([]int, len(tails))
+	if len(tails) == 0 {
+		return result
+	}
+	for k, i := len(tails)-1, tails[len(tails)-1]; k >= 0; k, i = k-1, previous[i] {
+		result[k] = i
+	}
+	return result
+}
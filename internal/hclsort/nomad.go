@@ -0,0 +1,17 @@
+package hclsort
+
+import "github.com/hashicorp/hcl/v2/hclwrite"
+
+// sortJobBlock sorts the arguments of a Nomad job block and of every block nested in it, such as
+// group, task, config and env blocks, using compare. Nested blocks keep their positions, as the order
+// of groups, tasks and other repeated blocks can be significant. Bodies already in order are left as
+// they are.
+func sortJobBlock(block *hclwrite.Block, compare Comparator) {
+	body := block.Body()
+	if !attributesInOrder(body, compare) {
+		sortBodyAttributes(body, compare)
+	}
+	for _, nested := range body.Blocks() {
+		sortJobBlock(nested, compare)
+	}
+}
@@ -0,0 +1,223 @@
+package hclsort
+
+import (
+	"slices"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// anyDepth sorts objects at every nesting level; see sortObjects.
+const anyDepth = -1
+
+// objectElement is an attribute of an object type constraint or an element of an object literal.
+type objectElement struct {
+	// lead holds the comment lines above the element.
+	lead hclwrite.Tokens
+	// tokens holds the element's line, including a trailing comma and comment.
+	tokens hclwrite.Tokens
+	// key is the element's literal key.
+	key string
+}
+
+// sortObjectAttribute sorts the objects in the expression assigned to the named attribute of body,
+// up to depth levels of nesting, using compare. opening tells where the objects start; see sortObjects.
+func sortObjectAttribute(
+	body *hclwrite.Body,
+	name string,
+	compare Comparator,
+	opening func(hclwrite.Tokens) int,
+	depth int,
+) {
+	attr := body.GetAttribute(name)
+	if attr == nil {
+		return
+	}
+	tokens := attr.Expr().BuildTokens(nil)
+	if sorted, changed := sortObjects(tokens, compare, opening, depth); changed {
+		body.SetAttributeRaw(name, sorted)
+	}
+}
+
+// sortObjects sorts the elements of the objects in tokens by key using compare. Objects nested in
+// other objects are sorted up to depth levels, where one level sorts only the outermost objects and
+// anyDepth sorts every level. opening returns the number of tokens up to and including the opening
+// brace of an object starting at the first of the given tokens, or zero when none starts there. Objects whose elements cannot be
+// told apart, such as several elements on a line of a multi-line object, are left untouched.
+// It reports whether any object was reordered.
+func sortObjects(
+	tokens hclwrite.Tokens,
+	compare Comparator,
+	opening func(hclwrite.Tokens) int,
+	depth int,
+) (hclwrite.Tokens, bool) {
+	if depth == 0 {
+		return tokens, false
+	}
+	nestedDepth := depth - 1
+	if depth == anyDepth {
+		nestedDepth = anyDepth
+	}
+
+	result := make(hclwrite.Tokens, 0, len(tokens))
+	changed := false
+	for i := 0; i < len(tokens); i++ {
+		length := opening(tokens[i:])
+		if length == 0 {
+			result = append(result, tokens[i])
+			continue
+		}
+		brace := i + length - 1
+		end := closingToken(tokens, brace)
+		if end < 0 {
+			return tokens, false
+		}
+		// Nested objects are sorted first, so they move along with their element.
+		inner, innerChanged := sortObjects(tokens[brace+1:end], compare, opening, nestedDepth)
+		sorted, sortedChanged := sortObjectElements(inner, compare)
+		result = append(append(append(result, tokens[i:brace+1]...), sorted...), tokens[end])
+		changed = changed || innerChanged || sortedChanged
+		i = end
+	}
+	return result, changed
+}
+
+// objectTypeOpening matches the "object({" of object type constraints, such as
+// object({ name = string, port = optional(number, 80) }).
+func objectTypeOpening(tokens hclwrite.Tokens) int {
+	if len(tokens) > 2 && tokens[0].Type == hclsyntax.TokenIdent && string(tokens[0].Bytes) == "object" &&
+		tokens[1].Type == hclsyntax.TokenOParen && tokens[2].Type == hclsyntax.TokenOBrace {
+		return 3
+	}
+	return 0
+}
+
+// objectLiteralOpening matches the opening brace of object and map literals. The braces of for
+// expressions match as well, but their elements have no literal keys, so they are left untouched.
+func objectLiteralOpening(tokens hclwrite.Tokens) int {
+	if len(tokens) > 0 && tokens[0].Type == hclsyntax.TokenOBrace {
+		return 1
+	}
+	return 0
+}
+
+// closingToken returns the index of the token closing the bracket, brace or parenthesis at open, or
+// -1 when it is not closed.
+func closingToken(tokens hclwrite.Tokens, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		depth += nesting(tokens[i])
+		if depth == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// nesting returns 1 for tokens opening a nested expression, -1 for tokens closing one, and 0 otherwise.
+func nesting(token *hclwrite.Token) int {
+	switch token.Type {
+	case hclsyntax.TokenOBrack, hclsyntax.TokenOBrace, hclsyntax.TokenOParen, hclsyntax.TokenOQuote,
+		hclsyntax.TokenOHeredoc, hclsyntax.TokenTemplateInterp, hclsyntax.TokenTemplateControl:
+		return 1
+	case hclsyntax.TokenCBrack, hclsyntax.TokenCBrace, hclsyntax.TokenCParen, hclsyntax.TokenCQuote,
+		hclsyntax.TokenCHeredoc, hclsyntax.TokenTemplateSeqEnd:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// sortObjectElements sorts the elements between the braces of an object by key using compare.
+// Comment lines above an element and comments at the end of its line move with it, and blank lines
+// between elements are dropped. It reports whether the elements were reordered.
+func sortObjectElements(tokens hclwrite.Tokens, compare Comparator) (hclwrite.Tokens, bool) {
+	elements, trailing, multiline, ok := objectElements(tokens)
+	if !ok || len(elements) < 2 {
+		return tokens, false
+	}
+	order := func(a, b objectElement) int {
+		return compare(a.key, b.key)
+	}
+	if slices.IsSortedFunc(elements, order) {
+		return tokens, false
+	}
+	slices.SortStableFunc(elements, order)
+
+	var result hclwrite.Tokens
+	if multiline {
+		result = append(result, &hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")})
+	}
+	for i, element := range elements {
+		if !multiline && i > 0 {
+			result = append(result, &hclwrite.Token{Type: hclsyntax.TokenComma, Bytes: []byte(",")})
+		}
+		result = append(append(result, element.lead...), element.tokens...)
+		// The newline of a trailing line comment already ends the line.
+		if multiline && !endsLine(element.tokens[len(element.tokens)-1]) {
+			result = append(result, &hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")})
+		}
+	}
+	return append(result, trailing...), true
+}
+
+// objectElements splits the tokens between the braces of an object into its elements and the comment
+// lines after the last one. Elements of a multi-line object are separated by line ends, and those of
+// a single-line object by commas. It fails for elements without a literal key, for several elements
+// on a line of a multi-line object, and for comments in a single-line object.
+func objectElements(tokens hclwrite.Tokens) ([]objectElement, hclwrite.Tokens, bool, bool) {
+	multiline := false
+	depth := 0
+	for _, token := range tokens {
+		depth += nesting(token)
+		if depth == 0 && endsLine(token) {
+			multiline = true
+		}
+	}
+
+	var elements []objectElement
+	var lead, line hclwrite.Tokens
+	appendElement := func() bool {
+		line = trimNewlines(line)
+		if len(line) == 0 {
+			return true
+		}
+		key, ok := mapKey(line)
+		if !ok {
+			return false
+		}
+		elements = append(elements, objectElement{lead: lead, tokens: line, key: key})
+		lead, line = nil, nil
+		return true
+	}
+
+	depth = 0
+	for i, token := range tokens {
+		depth += nesting(token)
+		switch {
+		case depth != 0:
+			line = append(line, token)
+		case token.Type == hclsyntax.TokenComment && !multiline:
+			return nil, nil, false, false
+		case token.Type == hclsyntax.TokenComment && len(trimNewlines(line)) == 0:
+			lead = append(lead, token)
+		case token.Type == hclsyntax.TokenComma && !multiline:
+			if !appendElement() {
+				return nil, nil, false, false
+			}
+		case token.Type == hclsyntax.TokenComma && i+1 < len(tokens) && !endsLine(tokens[i+1]) &&
+			tokens[i+1].Type != hclsyntax.TokenComment:
+			// Several elements on a line.
+			return nil, nil, false, false
+		default:
+			line = append(line, token)
+			if endsLine(token) && !appendElement() {
+				return nil, nil, false, false
+			}
+		}
+	}
+	if !appendElement() {
+		return nil, nil, false, false
+	}
+	return elements, lead, multiline, true
+}
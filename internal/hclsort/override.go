@@ -0,0 +1,44 @@
+package hclsort
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Handling of override files, whose blocks are merged into the blocks of the same name elsewhere in
+// the module.
+const (
+	// OverrideFilesContents sorts the contents of the blocks of override files, keeping the blocks
+	// themselves in place.
+	OverrideFilesContents = "contents"
+	// OverrideFilesSkip leaves override files untouched.
+	OverrideFilesSkip = "skip"
+	// OverrideFilesSort sorts override files like any other file.
+	OverrideFilesSort = "sort"
+)
+
+// isOverrideFile reports whether name is a Terraform override file, such as override.tf or
+// main_override.tf.json, whose blocks are merged into the blocks of the same name elsewhere in the
+// module and must not be moved.
+func isOverrideFile(name string) bool {
+	name = filepath.Base(strings.TrimSuffix(name, ".json"))
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	return stem == "override" || strings.HasSuffix(stem, "_override")
+}
+
+// ValidateOverrideFiles checks that handling names a known handling of override files, or is empty.
+func ValidateOverrideFiles(handling string) error {
+	switch handling {
+	case "", OverrideFilesContents, OverrideFilesSkip, OverrideFilesSort:
+		return nil
+	default:
+		return fmt.Errorf(
+			"unknown handling of override files '%s', expected '%s', '%s' or '%s'",
+			handling,
+			OverrideFilesContents,
+			OverrideFilesSkip,
+			OverrideFilesSort,
+		)
+	}
+}
@@ -0,0 +1,8 @@
+package hclsort
+
+import "strings"
+
+// isPackerFile reports whether path names a Packer template, such as build.pkr.hcl.
+func isPackerFile(path string) bool {
+	return strings.HasSuffix(path, ".pkr.hcl")
+}
@@ -0,0 +1,116 @@
+package hclsort
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// Move describes how sorting moves a top-level block of a file.
+type Move struct {
+	// Address identifies the block by its type and labels, such as `variable "region"`.
+	Address string
+	Type    string
+	Labels  []string
+	// OldIndex and NewIndex are the positions of the block among the top-level blocks of the file
+	// before and after sorting, counted from 0. NewIndex is -1 for blocks merged into another block.
+	OldIndex int
+	NewIndex int
+	// OldLine and NewLine are the lines the block starts on before and after sorting, without the
+	// comments above it. NewLine is 0 for blocks merged into another block.
+	OldLine int
+	NewLine int
+}
+
+// SortPlan describes the changes sorting a file makes without its sorted content.
+type SortPlan struct {
+	Path string
+	// Moves lists the top-level blocks whose position among the blocks of the file changes, in their
+	// original order.
+	Moves []Move
+	// Changed reports whether sorting changes the file at all, including the contents and spacing of
+	// blocks that keep their positions.
+	Changed bool
+	// Skipped is set when the file opted out of sorting, is generated or is a dependency lock file.
+	Skipped bool
+}
+
+// PlanSource returns the plan for sorting src, the content of the file at inputPath, like SortSource,
+// without returning the sorted content. Blocks are matched by their type, labels and sort key, such as
+// the alias of provider configurations or the addresses of moved blocks; blocks sharing all of them are
+// matched in their order. JSON configuration files cannot be planned.
+func (i *Ingestor) PlanSource(inputPath string, src []byte) (*SortPlan, error) {
+	return i.PlanSourceContext(context.Background(), inputPath, src)
+}
+
+// PlanSourceContext is like PlanSource, but fails with the error of ctx when it is done before src is
+// sorted.
+func (i *Ingestor) PlanSourceContext(ctx context.Context, inputPath string, src []byte) (*SortPlan, error) {
+	if isJSONConfig(inputPath) {
+		return nil, fmt.Errorf("cannot plan sorting JSON configuration file '%s'", inputPath)
+	}
+	result, err := i.SortSourceContext(ctx, inputPath, src)
+	if err != nil {
+		return nil, err
+	}
+	plan := &SortPlan{Path: inputPath, Changed: result.Changed(), Skipped: result.Skipped}
+	if !plan.Changed {
+		return plan, nil
+	}
+
+	original, err := ParseHCLContent(src, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	sorted, err := ParseHCLContent(result.Sorted, inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing sorted content of '%s': %w", inputPath, err)
+	}
+
+	sortedLines := newBlockLines(sorted.Body())
+	positions := map[string][]int{}
+	sortedBlocks := sorted.Body().Blocks()
+	for index, block := range sortedBlocks {
+		identity := blockIdentity(block)
+		positions[identity] = append(positions[identity], index)
+	}
+
+	originalLines := newBlockLines(original.Body())
+	for index, block := range original.Body().Blocks() {
+		identity := blockIdentity(block)
+		move := Move{
+			Address:  blockAddress(block),
+			Type:     block.Type(),
+			Labels:   block.Labels(),
+			OldIndex: index,
+			NewIndex: -1,
+			OldLine:  originalLines[block],
+		}
+		if remaining := positions[identity]; len(remaining) > 0 {
+			move.NewIndex = remaining[0]
+			move.NewLine = sortedLines[sortedBlocks[move.NewIndex]]
+			positions[identity] = remaining[1:]
+		}
+		if move.NewIndex != move.OldIndex {
+			plan.Moves = append(plan.Moves, move)
+		}
+	}
+	return plan, nil
+}
+
+// String describes the move, such as `variable "a" moves from line 5 to line 1`.
+func (m Move) String() string {
+	if m.NewIndex < 0 {
+		return fmt.Sprintf("%s on line %d is merged into another block", m.Address, m.OldLine)
+	}
+	return fmt.Sprintf("%s moves from line %d to line %d", m.Address, m.OldLine, m.NewLine)
+}
+
+// String describes the moves of the plan, one per line.
+func (p *SortPlan) String() string {
+	var buf bytes.Buffer
+	for _, move := range p.Moves {
+		fmt.Fprintf(&buf, "%s: %s\n", p.Path, move)
+	}
+	return buf.String()
+}
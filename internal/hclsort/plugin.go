@@ -0,0 +1,257 @@
+package hclsort
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// PluginProtocolVersion is the version of the protocol spoken with plugins, sent in every request.
+const PluginProtocolVersion = 1
+
+// DefaultPluginTimeout bounds how long a plugin may run on a file when its Timeout is zero, so plugins
+// that hang cannot hang tfsort.
+const DefaultPluginTimeout = 30 * time.Second
+
+// Plugin is an external program sorting the contents of top-level blocks of some types, or whole files
+// of some types, such as those of a proprietary dialect.
+//
+// The program is run once per file it handles. It reads a JSON request from stdin and writes a JSON
+// response to stdout. Requests hold the protocol_version and path of the file, and either its whole
+// source or the blocks it handles, each with its type, labels and source:
+//
+//	{"protocol_version": 1, "path": "main.tf", "blocks": [{"type": "widget", "labels": ["a"], "source": "..."}]}
+//
+// Responses hold the sorted source, or the sorted blocks in the order of the request, each of which
+// must keep the type and labels of the original block. A response with an error, or a program exiting
+// with a non-zero status, fails sorting the file:
+//
+//	{"blocks": [{"source": "..."}]}
+//	{"error": "..."}
+type Plugin struct {
+	Name string
+	// Command holds the program run and its arguments.
+	Command []string
+	// BlockTypes lists the types of the top-level blocks whose contents the plugin sorts. Whether the
+	// blocks themselves are ordered by their labels is still selected by AllowedBlocks.
+	BlockTypes []string
+	// FileTypes lists the types of the files, as returned by FileType, that the plugin sorts entirely.
+	FileTypes []string
+	// Timeout bounds how long the program may run on a file before it is killed, DefaultPluginTimeout
+	// when zero.
+	Timeout time.Duration
+}
+
+// pluginRequest is the request sent to a plugin.
+type pluginRequest struct {
+	ProtocolVersion int           `json:"protocol_version"`
+	Path            string        `json:"path"`
+	Source          string        `json:"source,omitempty"`
+	Blocks          []pluginBlock `json:"blocks,omitempty"`
+}
+
+// pluginBlock is a top-level block sent to or returned by a plugin.
+type pluginBlock struct {
+	Type   string   `json:"type,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+	Source string   `json:"source"`
+}
+
+// pluginResponse is the response of a plugin.
+type pluginResponse struct {
+	Source string        `json:"source"`
+	Blocks []pluginBlock `json:"blocks"`
+	Error  string        `json:"error"`
+}
+
+// run sends request to the plugin and returns its response.
+func (p Plugin) run(ctx context.Context, request pluginRequest) (*pluginResponse, error) {
+	if len(p.Command) == 0 {
+		return nil, fmt.Errorf("plugin '%s' has no command", p.Name)
+	}
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request to plugin '%s': %w", p.Name, err)
+	}
+
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultPluginTimeout
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	//nolint:gosec // Plugins are commands configured to be run.
+	command := exec.CommandContext(runCtx, p.Command[0], p.Command[1:]...)
+	command.Stdin = bytes.NewReader(input)
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	if err = command.Run(); err != nil {
+		if ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("plugin '%s' did not finish within %v", p.Name, timeout)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("plugin '%s' failed: %w: %s", p.Name, err, message)
+		}
+		return nil, fmt.Errorf("plugin '%s' failed: %w", p.Name, err)
+	}
+
+	response := &pluginResponse{}
+	if err = json.Unmarshal(stdout.Bytes(), response); err != nil {
+		return nil, fmt.Errorf("plugin '%s' returned an invalid response: %w", p.Name, err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("plugin '%s' failed: %s", p.Name, response.Error)
+	}
+	return response, nil
+}
+
+// filePlugin returns the first plugin sorting files of the type of path, or nil when there is none.
+func (i *Ingestor) filePlugin(path string) *Plugin {
+	fileType := FileType(path)
+	for index := range i.Plugins {
+		if slices.Contains(i.Plugins[index].FileTypes, fileType) {
+			return &i.Plugins[index]
+		}
+	}
+	return nil
+}
+
+// blockPlugins returns the plugins sorting the contents of block types by type, the first one
+// declared for each type, or nil when there are none.
+func (i *Ingestor) blockPlugins() map[string]*Plugin {
+	var plugins map[string]*Plugin
+	for index := range i.Plugins {
+		for _, blockType := range i.Plugins[index].BlockTypes {
+			if plugins == nil {
+				plugins = map[string]*Plugin{}
+			}
+			if plugins[blockType] == nil {
+				plugins[blockType] = &i.Plugins[index]
+			}
+		}
+	}
+	return plugins
+}
+
+// sortWithPlugin sorts src, the content of the file at inputPath, with plugin.
+func sortWithPlugin(ctx context.Context, plugin *Plugin, inputPath string, src []byte) (*Result, error) {
+	response, err := plugin.run(ctx, pluginRequest{
+		ProtocolVersion: PluginProtocolVersion,
+		Path:            inputPath,
+		Source:          string(src),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error sorting '%s': %w", inputPath, err)
+	}
+	// Responses replace the file, so content that was lost or mangled by the plugin is rejected.
+	sorted := []byte(response.Source)
+	if err = validPluginSource(inputPath, src, sorted); err != nil {
+		return nil, fmt.Errorf("error sorting '%s': plugin '%s' returned %w", inputPath, plugin.Name, err)
+	}
+	return &Result{Path: inputPath, Original: src, Sorted: sorted}, nil
+}
+
+// validPluginSource checks that sorted, the content of the file at inputPath returned by a plugin for
+// src, can replace the file: it must not be empty unless src is, and must parse like the file.
+func validPluginSource(inputPath string, src, sorted []byte) error {
+	if len(bytes.TrimSpace(sorted)) == 0 && len(bytes.TrimSpace(src)) > 0 {
+		return errors.New("no source")
+	}
+	if isJSONConfig(inputPath) {
+		if !json.Valid(sorted) {
+			return errors.New("invalid JSON")
+		}
+		return nil
+	}
+	if _, err := ParseHCLContent(sorted, inputPath); err != nil {
+		return fmt.Errorf("invalid source: %w", err)
+	}
+	return nil
+}
+
+// sortPluginBlocks replaces the contents of the top-level blocks of body handled by plugins with the
+// contents sorted by the plugins, sending each plugin the blocks it handles at once. Blocks that are
+// ignored or excluded by opts are left out. It returns the block types handled by plugins.
+func sortPluginBlocks(
+	ctx context.Context,
+	inputPath string,
+	body *hclwrite.Body,
+	plugins map[string]*Plugin,
+	opts sortOptions,
+) (map[string]bool, error) {
+	var order []*Plugin
+	claimed := map[*Plugin][]*hclwrite.Block{}
+	for _, item := range topLevelItems(body) {
+		block := item.block
+		if block == nil || item.pinned || item.skipTokens > 0 || opts.excludedBlocks[block.Type()] ||
+			hasBlockDirective(block, directiveIgnore) || opts.ignoredBlock(block) {
+			continue
+		}
+		plugin := plugins[block.Type()]
+		if plugin == nil {
+			continue
+		}
+		if claimed[plugin] == nil {
+			order = append(order, plugin)
+		}
+		claimed[plugin] = append(claimed[plugin], block)
+	}
+
+	for _, plugin := range order {
+		blocks := claimed[plugin]
+		request := pluginRequest{ProtocolVersion: PluginProtocolVersion, Path: inputPath}
+		for _, block := range blocks {
+			request.Blocks = append(request.Blocks, pluginBlock{
+				Type:   block.Type(),
+				Labels: block.Labels(),
+				Source: string(block.BuildTokens(nil).Bytes()),
+			})
+		}
+		response, err := plugin.run(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		if len(response.Blocks) != len(blocks) {
+			return nil, fmt.Errorf(
+				"plugin '%s' returned %d blocks, expected %d", plugin.Name, len(response.Blocks), len(blocks),
+			)
+		}
+		for index, block := range blocks {
+			if err = replaceBlockBody(block, response.Blocks[index].Source, inputPath); err != nil {
+				return nil, fmt.Errorf("plugin '%s' returned an invalid %s: %w", plugin.Name, blockAddress(block), err)
+			}
+		}
+	}
+
+	types := make(map[string]bool, len(plugins))
+	for blockType := range plugins {
+		types[blockType] = true
+	}
+	return types, nil
+}
+
+// replaceBlockBody replaces the body of block with the body of the single block in src, which must
+// have the type and labels of block.
+func replaceBlockBody(block *hclwrite.Block, src, filename string) error {
+	file, err := ParseHCLContent([]byte(src), filename)
+	if err != nil {
+		return err
+	}
+	blocks := file.Body().Blocks()
+	if len(blocks) != 1 || blocks[0].Type() != block.Type() || !slices.Equal(blocks[0].Labels(), block.Labels()) {
+		return errors.New("the source must hold the block alone")
+	}
+	block.Body().Clear()
+	block.Body().AppendUnstructuredTokens(blocks[0].Body().BuildTokens(nil))
+	return nil
+}
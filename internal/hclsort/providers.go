@@ -0,0 +1,104 @@
+package hclsort
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Forms of the source addresses of required providers.
+const (
+	// ProviderSourceShort omits the hostname of the public registry, as in "hashicorp/aws".
+	ProviderSourceShort = "short"
+	// ProviderSourceQualified includes the hostname, as in "registry.terraform.io/hashicorp/aws".
+	ProviderSourceQualified = "qualified"
+)
+
+const (
+	// defaultRegistry is the hostname of provider source addresses that omit it.
+	defaultRegistry = "registry.terraform.io"
+	// defaultNamespace is the namespace of provider source addresses that consist of a type only.
+	defaultNamespace = "hashicorp"
+)
+
+// normalizeProviderSources rewrites the literal source addresses of the required_providers of a
+// terraform block in the given form; see normalizeSource.
+func normalizeProviderSources(block *hclwrite.Block, form string) {
+	for _, nested := range block.Body().Blocks() {
+		if nested.Type() != "required_providers" {
+			continue
+		}
+		for _, attr := range nested.Body().Attributes() {
+			tokens := requirementArgument(attr.Expr().BuildTokens(nil), "source")
+			if len(tokens) == 3 && isStringLiteral(tokens) {
+				tokens[1].Bytes = []byte(normalizeSource(string(tokens[1].Bytes), form))
+			}
+		}
+	}
+}
+
+// normalizeSource returns the provider source address in lower case and in the given form.
+// Addresses consisting of a type only are in the hashicorp namespace, and addresses of other
+// registries keep their hostname. Addresses that are not valid are returned unchanged.
+func normalizeSource(source, form string) string {
+	parts := strings.Split(strings.ToLower(source), "/")
+	if len(parts) == 1 {
+		parts = []string{defaultNamespace, parts[0]}
+	}
+	if len(parts) == 2 {
+		parts = append([]string{defaultRegistry}, parts...)
+	}
+	if len(parts) != 3 || strings.ContainsFunc(source, func(r rune) bool { return r == ' ' || r == '$' }) {
+		return source
+	}
+	if form == ProviderSourceShort && parts[0] == defaultRegistry {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, "/")
+}
+
+// requirementArgument returns the tokens of the value of the named argument of a provider
+// requirement, such as { source = "hashicorp/aws", version = "~> 5.0" }, given the tokens of the
+// requirement. It returns nil for other expressions and when the argument is not set.
+func requirementArgument(tokens hclwrite.Tokens, name string) hclwrite.Tokens {
+	tokens = trimNewlines(tokens)
+	if len(tokens) == 0 || tokens[0].Type != hclsyntax.TokenOBrace {
+		return nil
+	}
+	depth := 0
+	for i, token := range tokens {
+		depth += nesting(token)
+		if depth != 1 || token.Type != hclsyntax.TokenIdent || string(token.Bytes) != name ||
+			i+1 == len(tokens) || tokens[i+1].Type != hclsyntax.TokenEqual {
+			continue
+		}
+		// The value ends at a comma, a comment or the end of the line or object.
+		end := i + 2
+		for valueDepth := 0; end < len(tokens); end++ {
+			valueDepth += nesting(tokens[end])
+			if valueDepth < 0 || valueDepth == 0 && (tokens[end].Type == hclsyntax.TokenComma ||
+				tokens[end].Type == hclsyntax.TokenComment || endsLine(tokens[end])) {
+				break
+			}
+		}
+		return tokens[i+2 : end]
+	}
+	return nil
+}
+
+// ValidateProviderSource checks that form names a known form of provider source addresses, or is empty.
+func ValidateProviderSource(form string) error {
+	switch form {
+	case "", ProviderSourceShort, ProviderSourceQualified:
+		return nil
+	default:
+		return fmt.Errorf(
+			"unknown provider source form '%s', expected '%s' or '%s'",
+			form,
+			ProviderSourceShort,
+			ProviderSourceQualified,
+		)
+	}
+}
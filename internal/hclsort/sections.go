@@ -0,0 +1,121 @@
+package hclsort
+
+import (
+	"bytes"
+	"regexp"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// splitSections partitions items at banner comments matching pattern into sections that are
+// sorted independently. The comments above an item up to and including its last banner become
+// a pinned item at the start of the item's section, so the banner keeps its position.
+func splitSections(items []*topLevelItem, pattern *regexp.Regexp) [][]*topLevelItem {
+	var sections [][]*topLevelItem
+	var section []*topLevelItem
+	for _, item := range items {
+		if banner := splitBanner(item, pattern); banner != nil {
+			if len(section) > 0 {
+				sections = append(sections, section)
+			}
+			section = []*topLevelItem{banner}
+		}
+		section = append(section, item)
+	}
+	if len(section) > 0 {
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// splitBanner detaches the comments above item up to and including the last banner comment
+// matching pattern and returns them as a pinned item. It returns nil when no banner precedes
+// the item. Comments of the file header, the trailer and verbatim regions are never split.
+func splitBanner(item *topLevelItem, pattern *regexp.Regexp) *topLevelItem {
+	if item.verbatim != nil || (item.block == nil && item.pinned) {
+		return nil
+	}
+
+	// Comments directly above an item are part of its own tokens and come after its lead.
+	own := item.tokens
+	if item.block != nil {
+		own = item.block.BuildTokens(nil)[item.skipTokens:]
+	}
+	if end := bannerEnd(own[:leadTokenCount(own)], pattern); end > 0 {
+		banner := &topLevelItem{tokens: append(slices.Clone(item.lead), own[:end]...), pinned: true, joinNext: true}
+		item.lead = nil
+		if item.block != nil {
+			item.skipTokens += end
+		} else {
+			item.tokens = own[end:]
+		}
+		return banner
+	}
+
+	end := bannerEnd(item.lead, pattern)
+	if end == 0 {
+		return nil
+	}
+	banner, rest := item.lead[:end], item.lead[end:]
+	item.lead = trimLeadingNewlines(rest)
+	return &topLevelItem{
+		tokens:   banner,
+		pinned:   true,
+		joinNext: len(rest) == 0 || rest[0].Type != hclsyntax.TokenNewline,
+	}
+}
+
+// bannerEnd returns the index after the last comment in tokens matching pattern, including the
+// newline ending a block comment, or zero when no comment matches.
+func bannerEnd(tokens hclwrite.Tokens, pattern *regexp.Regexp) int {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if tokens[i].Type != hclsyntax.TokenComment || !pattern.Match(bytes.TrimSpace(tokens[i].Bytes)) {
+			continue
+		}
+		if !endsLine(tokens[i]) && i+1 < len(tokens) && tokens[i+1].Type == hclsyntax.TokenNewline {
+			return i + 2
+		}
+		return i + 1
+	}
+	return 0
+}
+
+// splitBlankLineGroups partitions every section at blank lines into groups of adjacent items
+// that are sorted independently.
+func splitBlankLineGroups(sections [][]*topLevelItem) [][]*topLevelItem {
+	var groups [][]*topLevelItem
+	for _, section := range sections {
+		start := 0
+		for i, item := range section {
+			if i > start && item.blankBefore {
+				groups = append(groups, section[start:i])
+				start = i
+			}
+		}
+		groups = append(groups, section[start:])
+	}
+	return groups
+}
+
+// anyComment matches every comment.
+//
+//nolint:gochecknoglobals // Read-only pattern
+var anyComment = regexp.MustCompile(``)
+
+// splitGroupHeaders detaches the comments above the first item of every group of several items into
+// a pinned item at the start of the group. Such comments head the group, such as a banner above a
+// group of blocks, so they stay at its top rather than moving with the block below them.
+func splitGroupHeaders(groups [][]*topLevelItem) [][]*topLevelItem {
+	for i, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		if header := splitBanner(group[0], anyComment); header != nil {
+			header.groupHeader = true
+			groups[i] = append([]*topLevelItem{header}, group...)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,89 @@
+package hclsort
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// sortKeyFunc returns the key a top-level block is sorted by, or nil if the block cannot be sorted.
+type sortKeyFunc func(block *hclwrite.Block) []string
+
+// blockSortKey returns the sort key of a top-level block.
+// Blocks without a dedicated key function are sorted by their first label.
+func blockSortKey(block *hclwrite.Block) []string {
+	switch block.Type() {
+	case "resource", "data", "source":
+		return typeAndNameSortKey(block)
+	case "provider":
+		return providerSortKey(block)
+	case "moved", "removed":
+		return attributeSortKey("from", "to")(block)
+	case "import":
+		return attributeSortKey("to", "id")(block)
+	default:
+		return firstLabelSortKey(block)
+	}
+}
+
+// blockIdentity returns a key identifying a top-level block among the blocks of a file: its address
+// followed by its sort key, which tells apart provider configurations by their alias and unlabeled
+// blocks, such as moved and import blocks, by their attributes. Blocks sharing it are only told apart
+// by their order.
+func blockIdentity(block *hclwrite.Block) string {
+	return strings.Join(append([]string{blockAddress(block)}, blockSortKey(block)...), "\x00")
+}
+
+// firstLabelSortKey sorts blocks such as variables, outputs, modules and checks by their name.
+func firstLabelSortKey(block *hclwrite.Block) []string {
+	labels := block.Labels()
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels[:1:1]
+}
+
+// typeAndNameSortKey sorts blocks labeled with a type and a name, resources, data sources and Packer sources,
+// by type and then by name.
+func typeAndNameSortKey(block *hclwrite.Block) []string {
+	labels := block.Labels()
+	if len(labels) < 2 {
+		return nil
+	}
+	return labels[:2:2]
+}
+
+// providerSortKey sorts provider configurations by name, then by alias.
+// The default configuration of a provider has no alias and sorts before its aliases.
+func providerSortKey(block *hclwrite.Block) []string {
+	key := firstLabelSortKey(block)
+	if key == nil {
+		return nil
+	}
+	return append(key, attributeText(block.Body(), "alias"))
+}
+
+// attributeSortKey returns a sortKeyFunc for unlabeled blocks, such as moved and import blocks,
+// that sorts them by the source text of the named attributes. The first attribute is required.
+func attributeSortKey(names ...string) sortKeyFunc {
+	return func(block *hclwrite.Block) []string {
+		if block.Body().GetAttribute(names[0]) == nil {
+			return nil
+		}
+		key := make([]string, len(names))
+		for i, name := range names {
+			key[i] = attributeText(block.Body(), name)
+		}
+		return key
+	}
+}
+
+// attributeText returns the source text of the named attribute's expression,
+// or an empty string if the body has no such attribute.
+func attributeText(body *hclwrite.Body, name string) string {
+	attr := body.GetAttribute(name)
+	if attr == nil {
+		return ""
+	}
+	return strings.TrimSpace(string(attr.Expr().BuildTokens(nil).Bytes()))
+}
@@ -0,0 +1,34 @@
+package hclsort
+
+import (
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// BlockSortFunc sorts the contents of a top-level block in place, comparing names with compare. It
+// replaces the sorting tfsort applies to the contents of blocks of its type; see Ingestor.BlockSorters.
+type BlockSortFunc func(block *hclwrite.Block, compare Comparator)
+
+// SortAttributes sorts the attributes of body by name using compare. Nested blocks keep their
+// positions, and comments travel with the attribute below them. Bodies already in order are left
+// untouched; otherwise the values of attributes can no longer be sorted afterwards.
+func SortAttributes(body *hclwrite.Body, compare Comparator) {
+	if !attributesInOrder(body, compare) {
+		sortBodyAttributes(body, compare)
+	}
+}
+
+// SortListAttribute sorts the elements of the list literal assigned to the named attribute of body
+// using compare. Missing attributes, other expressions and lists containing comments are left
+// untouched.
+func SortListAttribute(body *hclwrite.Body, name string, compare Comparator) {
+	sortListAttribute(body, name, compare, false)
+}
+
+// SortMapAttribute sorts the keys of the map literal assigned to the named attribute of body using
+// compare. Missing attributes, maps whose keys are not all literal names or strings and maps containing
+// comments are left untouched.
+func SortMapAttribute(body *hclwrite.Body, name string, compare Comparator) {
+	if body.GetAttribute(name) != nil {
+		sortMapAttribute(body, name, compare)
+	}
+}
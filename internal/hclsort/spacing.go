@@ -0,0 +1,100 @@
+package hclsort
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Alignments of the equals signs of attributes and object elements.
+const (
+	// AlignmentAlign aligns the equals signs of adjacent lines, as hclwrite formats them.
+	AlignmentAlign = "align"
+	// AlignmentNever puts a single space before every equals sign.
+	AlignmentNever = "never"
+	// AlignmentPreserve keeps the spaces before equals signs as they were written.
+	AlignmentPreserve = "preserve"
+)
+
+// tokenSpacing records the spaces before the tokens of a file as parsed, keyed by token, along with the
+// token preceding each of them.
+type tokenSpacing map[*hclwrite.Token]spacing
+
+// spacing is the original position of a token relative to the token before it.
+type spacing struct {
+	previous *hclwrite.Token
+	spaces   int
+}
+
+// newTokenSpacing records the spacing of the tokens of file. It must be called before file is sorted.
+func newTokenSpacing(file *hclwrite.File) tokenSpacing {
+	tokens := file.BuildTokens(nil)
+	spacings := make(tokenSpacing, len(tokens))
+	var previous *hclwrite.Token
+	for _, token := range tokens {
+		spacings[token] = spacing{previous: previous, spaces: token.SpacesBefore}
+		previous = token
+	}
+	return spacings
+}
+
+// bytes returns the formatted content of file, with the original spacing of the tokens selected by
+// restore that still follow the token they followed when the spacing was recorded. Tokens created
+// while sorting or placed next to other tokens, such as reordered list elements, stay formatted.
+func (s tokenSpacing) bytes(file *hclwrite.File, restore func(*hclwrite.Token) bool) []byte {
+	tokens := formattedTokens(file)
+	var previous *hclwrite.Token
+	for _, token := range tokens {
+		if original, ok := s[token]; ok && original.previous == previous && restore(token) {
+			token.SpacesBefore = original.spaces
+		}
+		previous = token
+	}
+	return tokens.Bytes()
+}
+
+// unalignedBytes returns the formatted content of file with a single space before every equals sign.
+func unalignedBytes(file *hclwrite.File) []byte {
+	tokens := formattedTokens(file)
+	for _, token := range tokens {
+		if isEquals(token) {
+			token.SpacesBefore = 1
+		}
+	}
+	return tokens.Bytes()
+}
+
+// formattedTokens formats the spacing of the tokens of file and returns them. Unlike FormatHCLBytes,
+// the tokens are formatted in place, so their spacing can be adjusted before they are written.
+func formattedTokens(file *hclwrite.File) hclwrite.Tokens {
+	// Writing a file has hclwrite format the spacing of its tokens in place.
+	_ = file.Bytes()
+	return file.BuildTokens(nil)
+}
+
+// everyToken selects all tokens.
+func everyToken(*hclwrite.Token) bool {
+	return true
+}
+
+// isEquals reports whether the token is the equals sign of an attribute or object element.
+func isEquals(token *hclwrite.Token) bool {
+	return token.Type == hclsyntax.TokenEqual
+}
+
+// ValidateAlignment checks that alignment names a known alignment of equals signs, or is empty.
+func ValidateAlignment(alignment string) error {
+	switch alignment {
+	case "", AlignmentAlign, AlignmentNever, AlignmentPreserve:
+		return nil
+	default:
+		return fmt.Errorf(
+			"unknown alignment '%s', expected '%s', '%s' or '%s'",
+			alignment,
+			AlignmentAlign,
+			AlignmentNever,
+			AlignmentPreserve,
+		)
+	}
+}
@@ -0,0 +1,239 @@
+package hclsort
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// DefaultSplitFiles maps the block types moved by Split to the files of the standard module structure
+// they are moved to.
+//
+//nolint:gochecknoglobals // Read-only defaults
+var DefaultSplitFiles = map[string]string{
+	"variable":  "variables.tf",
+	"output":    "outputs.tf",
+	"terraform": "versions.tf",
+	"provider":  "providers.tf",
+}
+
+// moduleFile is a Terraform file of a module whose top-level items are being moved between files.
+type moduleFile struct {
+	path     string
+	original []byte
+	file     *hclwrite.File
+	items    []*topLevelItem
+	// spacings holds the original spacing of the file's tokens when it is kept as written.
+	spacings tokenSpacing
+	// changed is set when items were moved into or out of the file.
+	changed bool
+}
+
+// Split moves the top-level blocks of the .tf and .tofu files in dir whose type is listed in destinations
+// into the file named for their type, such as variables into variables.tf, or into its .tofu
+// counterpart when the module has one, and sorts the files blocks were moved into. Comments above a
+// block move with it. Blocks preceded by a "# tfsort:ignore" comment or in tfsort:off regions stay in
+// place, as do the blocks of override files and of files that opt out of sorting or are generated.
+// Moving blocks into such a file fails.
+// Nothing is written: the results describe the files that change, with a nil Original for files that
+// are created and a nil Sorted for files that are left without content and can be removed.
+func (i *Ingestor) Split(dir string, destinations map[string]string) ([]*Result, error) {
+	files, skipped, err := i.readModule(dir)
+	if err != nil {
+		return nil, err
+	}
+	return i.moveBlocks(dir, files, skipped, destinations)
+}
+
+// Consolidate gathers the top-level blocks of the .tf and .tofu files in dir whose type is listed in
+// destinations into the file named for their type, like Split. It fails without moving any block when
+// a block of a listed type is defined more than once in the module, such as two variable "region"
+// blocks, naming the files and lines of each definition. Override files may repeat blocks.
+func (i *Ingestor) Consolidate(dir string, destinations map[string]string) ([]*Result, error) {
+	files, skipped, err := i.readModule(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = duplicateDefinitions(files, destinations); err != nil {
+		return nil, err
+	}
+	return i.moveBlocks(dir, files, skipped, destinations)
+}
+
+// moveBlocks moves the blocks of files into their destinations and returns the changed files; see Split.
+func (i *Ingestor) moveBlocks(
+	dir string,
+	files map[string]*moduleFile,
+	skipped map[string]bool,
+	destinations map[string]string,
+) ([]*Result, error) {
+	var err error
+	moved := map[string][]*topLevelItem{}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		file := files[name]
+		kept := make([]*topLevelItem, 0, len(file.items))
+		for _, item := range file.items {
+			destination := moduleDestination(movedTo(item, destinations), files, skipped)
+			if destination == "" || destination == name {
+				kept = append(kept, item)
+				continue
+			}
+			if skipped[destination] {
+				return nil, fmt.Errorf(
+					"cannot move %s from '%s' into '%s', which is skipped",
+					blockAddress(item.block),
+					file.path,
+					filepath.Join(dir, destination),
+				)
+			}
+			moved[destination] = append(moved[destination], item)
+			file.changed = true
+		}
+		file.items = kept
+	}
+
+	for destination, items := range moved {
+		file, ok := files[destination]
+		if !ok {
+			file = &moduleFile{path: filepath.Join(dir, destination), file: hclwrite.NewEmptyFile()}
+			files[destination] = file
+		}
+		file.items = append(file.items, items...)
+		file.changed = true
+	}
+
+	var results []*Result
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		file := files[name]
+		if !file.changed {
+			continue
+		}
+		appendItems(file.file.Body(), file.items, i.sortOptions())
+		content := bytes.TrimSpace(i.render(file.file, file.spacings))
+		result := &Result{Path: file.path}
+		if len(content) > 0 {
+			result.Sorted = append(content, '\n')
+		}
+		if moved[name] != nil {
+			if result, err = i.sortSource(context.Background(), file.path, result.Sorted); err != nil {
+				return nil, err
+			}
+		}
+		result.Original = file.original
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// readModule parses the Terraform and OpenTofu files directly in dir, keyed by file name. Override
+// files are left out, and the names of files that opt out of sorting or are generated, or that OpenTofu
+// reads instead of a .tofu file of the same name, are returned separately.
+func (i *Ingestor) readModule(dir string) (map[string]*moduleFile, map[string]bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading module directory '%s': %w", dir, err)
+	}
+
+	files := map[string]*moduleFile{}
+	skipped := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if entry.IsDir() || (ext != ".tf" && ext != ".tofu") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if isOverrideFile(name) || (ext == ".tf" && hasTofuCounterpart(entries, name)) {
+			skipped[name] = true
+			continue
+		}
+		var src []byte
+		if src, err = ReadFileBytes(path); err != nil {
+			return nil, nil, err
+		}
+		if header := headerComments(src); hasSkipFileDirective(header) || isGenerated(header, i.GeneratedPattern) {
+			skipped[name] = true
+			continue
+		}
+		var file *hclwrite.File
+		if file, err = ParseHCLContent(src, path); err != nil {
+			return nil, nil, err
+		}
+		files[name] = &moduleFile{
+			path:     path,
+			original: src,
+			file:     file,
+			items:    topLevelItems(file.Body()),
+			spacings: i.recordSpacing(file),
+		}
+	}
+	return files, skipped, nil
+}
+
+// hasTofuCounterpart reports whether entries hold a .tofu file named like the .tf file name, which
+// OpenTofu reads instead of it.
+func hasTofuCounterpart(entries []os.DirEntry, name string) bool {
+	tofu := strings.TrimSuffix(name, ".tf") + ".tofu"
+	return slices.ContainsFunc(entries, func(entry os.DirEntry) bool {
+		return !entry.IsDir() && entry.Name() == tofu
+	})
+}
+
+// moduleDestination returns the name of the file blocks are moved into for destination: its .tofu
+// counterpart when the module has one, since OpenTofu ignores the .tf file then.
+func moduleDestination(destination string, files map[string]*moduleFile, skipped map[string]bool) string {
+	if !strings.HasSuffix(destination, ".tf") {
+		return destination
+	}
+	if tofu := strings.TrimSuffix(destination, ".tf") + ".tofu"; files[tofu] != nil || skipped[tofu] {
+		return tofu
+	}
+	return destination
+}
+
+// duplicateDefinitions returns an error describing the labeled top-level blocks of the listed types
+// that are defined more than once across files, or nil when there are none.
+func duplicateDefinitions(files map[string]*moduleFile, destinations map[string]string) error {
+	var addresses []string
+	definitions := map[string][]string{}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		lines := newBlockLines(files[name].file.Body())
+		for _, item := range files[name].items {
+			if item.block == nil || len(item.block.Labels()) == 0 || destinations[item.block.Type()] == "" {
+				continue
+			}
+			address := blockAddress(item.block)
+			if definitions[address] == nil {
+				addresses = append(addresses, address)
+			}
+			definitions[address] = append(definitions[address], fmt.Sprintf("%s:%d", name, lines[item.block]))
+		}
+	}
+
+	var duplicates []string
+	for _, address := range addresses {
+		if len(definitions[address]) > 1 {
+			locations := strings.Join(definitions[address], ", ")
+			duplicates = append(duplicates, fmt.Sprintf("%s is defined in %s", address, locations))
+		}
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("duplicate definitions found, no blocks were moved:\n%s", strings.Join(duplicates, "\n"))
+	}
+	return nil
+}
+
+// movedTo returns the name of the file the item is moved to, or an empty string when it stays in place.
+func movedTo(item *topLevelItem, destinations map[string]string) string {
+	if item.block == nil || item.pinned || item.skipTokens > 0 || hasBlockDirective(item.block, directiveIgnore) {
+		return ""
+	}
+	return destinations[item.block.Type()]
+}
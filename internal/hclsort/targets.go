@@ -0,0 +1,172 @@
+package hclsort
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Target applies sort behaviors to the attributes or nested blocks at a path within top-level
+// blocks, such as sorting the ingress blocks of aws_security_group resources by description.
+type Target struct {
+	// BlockType is the type of the targeted top-level blocks, such as "resource".
+	BlockType string
+	// Label matches the first label of the targeted top-level blocks; nil matches every block.
+	Label *regexp.Regexp
+	// Path holds the types of the nested blocks leading to the targeted item, followed by the name of
+	// the targeted attributes or nested blocks, e.g. ["ingress", "cidr_blocks"]. An empty path
+	// targets the top-level blocks themselves.
+	Path []string
+	// SortKeys sorts the keys of the map literals assigned to the targeted attributes.
+	SortKeys bool
+	// SortElements sorts the targeted attributes' lists of literal strings.
+	SortElements bool
+	// SortBy sorts the targeted nested blocks by the value of this attribute of theirs. Blocks without
+	// it keep their order after the others.
+	SortBy string
+	// Ignore exempts the targeted attributes, and the attributes of the targeted nested blocks, from
+	// sorting of map keys and list elements. Top-level blocks targeted with an empty path keep their
+	// position and contents, as if preceded by a "# tfsort:ignore" comment.
+	Ignore bool
+}
+
+// exemptions holds the attributes and nested blocks whose contents are left untouched.
+type exemptions struct {
+	attributes map[*hclwrite.Attribute]bool
+	blocks     map[*hclwrite.Block]bool
+}
+
+// matches reports whether the target applies to the top-level block.
+func (t Target) matches(block *hclwrite.Block) bool {
+	if block.Type() != t.BlockType {
+		return false
+	}
+	if t.Label == nil {
+		return true
+	}
+	labels := block.Labels()
+	return len(labels) > 0 && t.Label.MatchString(labels[0])
+}
+
+// ignoredBlock reports whether a target ignores the top-level block as a whole.
+func (o sortOptions) ignoredBlock(block *hclwrite.Block) bool {
+	return slices.ContainsFunc(o.targets, func(target Target) bool {
+		return target.Ignore && len(target.Path) == 0 && target.matches(block)
+	})
+}
+
+// exemptions returns the items of the top-level block that ignore targets exempt from sorting.
+func (o sortOptions) exemptions(block *hclwrite.Block) exemptions {
+	exempt := exemptions{attributes: map[*hclwrite.Attribute]bool{}, blocks: map[*hclwrite.Block]bool{}}
+	for _, target := range o.targets {
+		if !target.Ignore || len(target.Path) == 0 || !target.matches(block) {
+			continue
+		}
+		name := target.Path[len(target.Path)-1]
+		for _, body := range targetBodies(block.Body(), target.Path) {
+			if attr := body.GetAttribute(name); attr != nil {
+				exempt.attributes[attr] = true
+			}
+			for _, nested := range body.Blocks() {
+				if nested.Type() == name {
+					exempt.blocks[nested] = true
+				}
+			}
+		}
+	}
+	return exempt
+}
+
+// applyTargets applies the sort behaviors of the targets matching the top-level block. Attributes are
+// sorted before nested blocks are reordered, as reordering a body turns its attributes into tokens.
+func applyTargets(block *hclwrite.Block, targets []Target, compare Comparator, exempt exemptions) {
+	for _, target := range targets {
+		if target.Ignore || len(target.Path) == 0 || !target.matches(block) {
+			continue
+		}
+		name := target.Path[len(target.Path)-1]
+		for _, body := range targetBodies(block.Body(), target.Path) {
+			attr := body.GetAttribute(name)
+			if attr == nil || exempt.attributes[attr] {
+				continue
+			}
+			if target.SortKeys {
+				sortMapAttribute(body, name, compare)
+			}
+			if target.SortElements {
+				sortListAttribute(body, name, compare, true)
+			}
+		}
+	}
+	for _, target := range targets {
+		if target.SortBy == "" || len(target.Path) == 0 || !target.matches(block) {
+			continue
+		}
+		for _, body := range targetBodies(block.Body(), target.Path) {
+			sortBlocksBy(body, target.Path[len(target.Path)-1], target.SortBy, compare)
+		}
+	}
+}
+
+// targetBodies returns the bodies within body holding the items at path: the bodies of the nested
+// blocks named by all but the last segment of path.
+func targetBodies(body *hclwrite.Body, path []string) []*hclwrite.Body {
+	bodies := []*hclwrite.Body{body}
+	for _, blockType := range path[:len(path)-1] {
+		var nested []*hclwrite.Body
+		for _, parent := range bodies {
+			for _, block := range parent.Blocks() {
+				if block.Type() == blockType {
+					nested = append(nested, block.Body())
+				}
+			}
+		}
+		bodies = nested
+	}
+	return bodies
+}
+
+// sortBlocksBy sorts the nested blocks of the given type in body by the value of the named attribute,
+// the contents of string literals or the source text of other expressions, using compare.
+// Blocks without the attribute are placed after the others, and other items keep their positions.
+func sortBlocksBy(body *hclwrite.Body, blockType, attribute string, compare Comparator) {
+	items, trailing := bodyItems(body)
+
+	type keyedItem struct {
+		item *bodyItem
+		key  *string
+	}
+	var blocks []keyedItem
+	var slots []int
+	for i, item := range items {
+		if item.block == nil || item.block.Type() != blockType {
+			continue
+		}
+		keyed := keyedItem{item: item}
+		if attr := item.block.Body().GetAttribute(attribute); attr != nil {
+			key := elementKey(trimNewlines(attr.Expr().BuildTokens(nil)))
+			keyed.key = &key
+		}
+		blocks = append(blocks, keyed)
+		slots = append(slots, i)
+	}
+	order := func(a, b keyedItem) int {
+		switch {
+		case a.key == nil || b.key == nil:
+			// Blocks without the attribute sort last.
+			return boolRank(a.key == nil) - boolRank(b.key == nil)
+		default:
+			return compare(*a.key, *b.key)
+		}
+	}
+	if slices.IsSortedFunc(blocks, order) {
+		return
+	}
+	slices.SortStableFunc(blocks, order)
+	for i, slot := range slots {
+		items[slot] = blocks[i].item
+	}
+
+	rebuildBody(body, items, trailing)
+}
@@ -0,0 +1,9 @@
+package hclsort
+
+import "strings"
+
+// isTerramateFile reports whether path names a Terramate configuration file, such as
+// terramate.tm.hcl or stack.tm.hcl.
+func isTerramateFile(path string) bool {
+	return strings.HasSuffix(path, ".tm.hcl")
+}
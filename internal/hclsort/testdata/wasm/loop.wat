@@ -0,0 +1,16 @@
+;; A sorting rule whose comparisons never return, used by TestWasmComparator.
+;; loop.wasm is its binary encoding, e.g. built with: wat2wasm loop.wat
+(module
+  (memory (export "memory") 1)
+
+  (func (export "tfsort_abi_version") (result i32)
+    (i32.const 1))
+
+  (func (export "tfsort_alloc") (param $size i32) (result i32)
+    (i32.const 1024))
+
+  (func (export "tfsort_compare")
+    (param $a i32) (param $a_len i32) (param $b i32) (param $b_len i32) (result i32)
+    (loop $forever
+      (br $forever))
+    (unreachable)))
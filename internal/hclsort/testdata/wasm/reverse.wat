@@ -0,0 +1,26 @@
+;; A sorting rule ordering names in reverse byte order, used by TestWasmComparator.
+;; reverse.wasm is its binary encoding, e.g. built with: wat2wasm reverse.wat
+(module
+  (memory (export "memory") 1)
+
+  (func (export "tfsort_abi_version") (result i32)
+    (i32.const 1))
+
+  ;; Names are always copied into the static buffer at offset 1024.
+  (func (export "tfsort_alloc") (param $size i32) (result i32)
+    (i32.const 1024))
+
+  (func (export "tfsort_compare")
+    (param $a i32) (param $a_len i32) (param $b i32) (param $b_len i32) (result i32)
+    (local $i i32) (local $ca i32) (local $cb i32)
+    (block $done
+      (loop $next
+        (br_if $done (i32.eq (local.get $i) (local.get $a_len)))
+        (br_if $done (i32.eq (local.get $i) (local.get $b_len)))
+        (local.set $ca (i32.load8_u (i32.add (local.get $a) (local.get $i))))
+        (local.set $cb (i32.load8_u (i32.add (local.get $b) (local.get $i))))
+        (if (i32.ne (local.get $ca) (local.get $cb))
+          (then (return (i32.sub (local.get $cb) (local.get $ca)))))
+        (local.set $i (i32.add (local.get $i) (i32.const 1)))
+        (br $next)))
+    (i32.sub (local.get $b_len) (local.get $a_len))))
@@ -0,0 +1,27 @@
+package hclsort
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// isTestFile reports whether path names a test file, such as main.tftest.hcl, whose run blocks are
+// executed in order and must not be reordered.
+func isTestFile(path string) bool {
+	return strings.HasSuffix(path, ".tftest.hcl") || strings.HasSuffix(path, ".tofutest.hcl")
+}
+
+// sortTestBlock sorts the assignments of a top-level variables block of a test file, or of the
+// variables blocks nested in a run block, using compare.
+func sortTestBlock(block *hclwrite.Block, compare Comparator) {
+	if block.Type() == "variables" {
+		sortBodyAttributes(block.Body(), compare)
+		return
+	}
+	for _, nested := range block.Body().Blocks() {
+		if nested.Type() == "variables" {
+			sortBodyAttributes(nested.Body(), compare)
+		}
+	}
+}
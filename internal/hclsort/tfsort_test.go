@@ -1,15 +1,23 @@
 package hclsort_test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/AlexNabokikh/tfsort/internal/hclsort"
 	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 )
 
 const (
@@ -115,6 +123,18 @@ func TestCheckFileExtension(t *testing.T) {
 		}
 	})
 
+	t.Run("Valid JSON Configuration File Path", func(t *testing.T) {
+		if err := hclsort.CheckFileExtension("main.tf.json", allowedTypes); err != nil {
+			t.Errorf("Unexpected error for valid .tf.json file path: %v", err)
+		}
+		if err := hclsort.CheckFileExtension("main.tofu.json", allowedTypes); err != nil {
+			t.Errorf("Unexpected error for valid .tofu.json file path: %v", err)
+		}
+		if err := hclsort.CheckFileExtension("package.json", allowedTypes); err == nil {
+			t.Error("Expected error for a JSON file that is not a Terraform configuration but got nil")
+		}
+	})
+
 	t.Run("Path with valid extension (file existence not checked)", func(t *testing.T) {
 		err := hclsort.CheckFileExtension("nonExistentFile.tf", allowedTypes)
 		if err != nil {
@@ -576,6 +596,111 @@ locals {
 	}
 }
 
+func TestLocalsDepth(t *testing.T) {
+	input := `locals {
+  settings = {
+    web = { port = 80, host = "web" }
+    api = { port = 8080, host = "api" }
+  }
+  name = "app"
+}
+`
+	tests := map[string]struct {
+		depth int
+		want  string
+	}{
+		"Assignments only": {
+			depth: 0,
+			want: `locals {
+  name = "app"
+  settings = {
+    web = { port = 80, host = "web" }
+    api = { port = 8080, host = "api" }
+  }
+}
+`,
+		},
+		"Outermost objects": {
+			depth: 1,
+			want: `locals {
+  name = "app"
+  settings = {
+    api = { port = 8080, host = "api" }
+    web = { port = 80, host = "web" }
+  }
+}
+`,
+		},
+		"Nested objects": {
+			depth: 2,
+			want: `locals {
+  name = "app"
+  settings = {
+    api = { host = "api", port = 8080 }
+    web = { host = "web", port = 80 }
+  }
+}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "locals.tf")
+			if err := os.WriteFile(path, []byte(input), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.LocalsDepth = tc.depth
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLocalsOrderTopological(t *testing.T) {
+	input := `locals {
+  # The full name.
+  name   = "${local.prefix}-${local.env}"
+  tags   = { Name = local.name }
+  prefix = "app"
+  env    = var.env
+  a      = local.b
+  b      = local.a
+}
+`
+	want := `locals {
+  env    = var.env
+  prefix = "app"
+  # The full name.
+  name = "${local.prefix}-${local.env}"
+  tags = { Name = local.name }
+  a    = local.b
+  b    = local.a
+}
+`
+	path := filepath.Join(t.TempDir(), "locals.tf")
+	if err := os.WriteFile(path, []byte(input), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.LocalsOrder = hclsort.LocalsOrderTopological
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
 func testsFromFixtures(t *testing.T, testNames []string) map[string]struct {
 	hclInput string
 	want     string
@@ -634,3 +759,4709 @@ func TestProcessFixtures(t *testing.T) {
 		})
 	}
 }
+
+func TestSort(t *testing.T) {
+	ingestor := hclsort.NewIngestor()
+
+	t.Run("Unsorted file is reported as changed", func(t *testing.T) {
+		result, err := ingestor.Sort(validFilePath, false)
+		if err != nil {
+			t.Fatalf("Sort failed unexpectedly: %v", err)
+		}
+		if !result.Changed() {
+			t.Errorf("Expected %s to be reported as changed", validFilePath)
+		}
+	})
+
+	t.Run("Sorted file is reported as unchanged", func(t *testing.T) {
+		result, err := ingestor.Sort(expectedTfPath, false)
+		if err != nil {
+			t.Fatalf("Sort failed unexpectedly: %v", err)
+		}
+		if result.Changed() {
+			t.Errorf("Expected %s to be reported as unchanged, got:\n%s", expectedTfPath, result.Sorted)
+		}
+	})
+
+	t.Run("Sort does not modify the input file", func(t *testing.T) {
+		before, errRead := os.ReadFile(validFilePath)
+		if errRead != nil {
+			t.Fatalf("Failed to read %s: %v", validFilePath, errRead)
+		}
+		if _, err := ingestor.Sort(validFilePath, false); err != nil {
+			t.Fatalf("Sort failed unexpectedly: %v", err)
+		}
+		after, errRead := os.ReadFile(validFilePath)
+		if errRead != nil {
+			t.Fatalf("Failed to read %s: %v", validFilePath, errRead)
+		}
+		if diff := cmp.Diff(string(before), string(after)); diff != "" {
+			t.Errorf("Sort modified the input file:\n%s", diff)
+		}
+	})
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Run("Equal content produces no diff", func(t *testing.T) {
+		content := []byte("variable \"a\" {}\n")
+		if got := hclsort.UnifiedDiff("main.tf", content, content); got != "" {
+			t.Errorf("Expected empty diff, got:\n%s", got)
+		}
+	})
+
+	t.Run("Swapped blocks produce a single hunk", func(t *testing.T) {
+		original := []byte("variable \"b\" {}\n\nvariable \"a\" {}\n")
+		sorted := []byte("variable \"a\" {}\n\nvariable \"b\" {}\n")
+
+		want := `--- main.tf.orig
++++ main.tf
+@@ -1,3 +1,3 @@
+-variable "b" {}
+-
+ variable "a" {}
++
++variable "b" {}
+`
+		if diff := cmp.Diff(want, hclsort.UnifiedDiff("main.tf", original, sorted)); diff != "" {
+			t.Errorf("Unexpected diff output:\n%s", diff)
+		}
+	})
+
+	t.Run("Distant changes produce separate hunks", func(t *testing.T) {
+		originalLines := []string{"a", "1", "2", "3", "4", "5", "6", "7", "8", "b"}
+		sortedLines := []string{"A", "1", "2", "3", "4", "5", "6", "7", "8", "B"}
+		original := []byte(strings.Join(originalLines, "\n") + "\n")
+		sorted := []byte(strings.Join(sortedLines, "\n") + "\n")
+
+		got := hclsort.UnifiedDiff("main.tf", original, sorted)
+		if n := strings.Count(got, "@@ -"); n != 2 {
+			t.Errorf("Expected 2 hunks, got %d:\n%s", n, got)
+		}
+		if !strings.Contains(got, "@@ -1,4 +1,4 @@") || !strings.Contains(got, "@@ -7,4 +7,4 @@") {
+			t.Errorf("Unexpected hunk headers:\n%s", got)
+		}
+	})
+}
+
+func TestIgnoreDirective(t *testing.T) {
+	const hclInput = `variable "c" {}
+
+# tfsort:ignore keep dependency order
+locals {
+  zone   = "a"
+  region = "b"
+}
+
+variable "b" {}
+
+// tfsort:ignore
+variable "z" {}
+
+variable "a" {}
+`
+	const want = `variable "a" {}
+
+# tfsort:ignore keep dependency order
+locals {
+  zone   = "a"
+  region = "b"
+}
+
+variable "b" {}
+
+// tfsort:ignore
+variable "z" {}
+
+variable "c" {}
+`
+
+	file, err := hclsort.ParseHCLContent([]byte(hclInput), "test.tf")
+	if err != nil {
+		t.Fatalf("ParseHCLContent failed: %v", err)
+	}
+
+	sortedFile := hclsort.ProcessAndSortBlocks(file, hclsort.NewIngestor().AllowedBlocks)
+	got := string(hclsort.FormatHCLBytes(sortedFile))
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected output for ignored blocks:\n%s", diff)
+	}
+}
+
+func TestVerbatimRegionDirectives(t *testing.T) {
+	const hclInput = `variable "d" {}
+
+# tfsort:off
+variable "c" {
+  default     = 1
+  description =    "kept verbatim"
+}
+locals {
+  z = 1
+  a = 2
+}
+# tfsort:on
+
+variable "b" {}
+
+/* tfsort:off */
+variable "a2" {}
+# tfsort:on
+variable "a1" {}
+
+variable "a" {}
+`
+	const want = `variable "a" {}
+
+# tfsort:off
+variable "c" {
+  default     = 1
+  description =    "kept verbatim"
+}
+locals {
+  z = 1
+  a = 2
+}
+# tfsort:on
+
+variable "a1" {}
+
+/* tfsort:off */
+variable "a2" {}
+# tfsort:on
+
+variable "b" {}
+
+variable "d" {}
+`
+
+	file, err := hclsort.ParseHCLContent([]byte(hclInput), "test.tf")
+	if err != nil {
+		t.Fatalf("ParseHCLContent failed: %v", err)
+	}
+
+	sortedFile := hclsort.ProcessAndSortBlocks(file, hclsort.NewIngestor().AllowedBlocks)
+	got := string(hclsort.FormatHCLBytes(sortedFile))
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected output for tfsort:off/tfsort:on regions:\n%s", diff)
+	}
+
+	t.Run("Unterminated region extends to the end of the file", func(t *testing.T) {
+		const unterminated = `variable "b" {}
+
+# tfsort:off
+variable "z" {}
+variable    "y" {}
+`
+		unterminatedFile, parseErr := hclsort.ParseHCLContent([]byte(unterminated), "test.tf")
+		if parseErr != nil {
+			t.Fatalf("ParseHCLContent failed: %v", parseErr)
+		}
+
+		sortedRegion := hclsort.ProcessAndSortBlocks(unterminatedFile, hclsort.NewIngestor().AllowedBlocks)
+		sorted := string(hclsort.FormatHCLBytes(sortedRegion))
+
+		if diff := cmp.Diff(unterminated, sorted); diff != "" {
+			t.Errorf("unexpected output for unterminated region:\n%s", diff)
+		}
+	})
+}
+
+func TestSkipFileDirective(t *testing.T) {
+	ingestor := hclsort.NewIngestor()
+	unsorted := "variable \"b\" {}\nvariable \"a\" {}\n"
+
+	tests := map[string]struct {
+		content     string
+		wantSkipped bool
+	}{
+		"Directive in the header":          {"# Managed by hand\n\n# tfsort:skip-file\n" + unsorted, true},
+		"Directive with explanation":       {"// tfsort:skip-file order matters here\n" + unsorted, true},
+		"Directive after the first block":  {unsorted + "# tfsort:skip-file\n", false},
+		"File without directive is sorted": {"# header\n" + unsorted, false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(tc.content), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if result.Skipped != tc.wantSkipped {
+				t.Errorf("Expected Skipped to be %v, got %v", tc.wantSkipped, result.Skipped)
+			}
+			if result.Skipped && result.Changed() {
+				t.Errorf("Expected skipped file to be unchanged, got:\n%s", result.Sorted)
+			}
+
+			if err = ingestor.Parse(path, "", false, false); err != nil {
+				t.Fatalf("Parse failed unexpectedly: %v", err)
+			}
+			written, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", path, err)
+			}
+			if tc.wantSkipped && string(written) != tc.content {
+				t.Errorf("Expected skipped file to be left untouched, got:\n%s", written)
+			}
+		})
+	}
+}
+
+func TestGeneratedFiles(t *testing.T) {
+	unsorted := "variable \"b\" {}\nvariable \"a\" {}\n"
+
+	tests := map[string]struct {
+		content     string
+		pattern     *regexp.Regexp
+		wantSkipped bool
+	}{
+		"Default header": {
+			"# Code generated by terraform-provider-foo. DO NOT EDIT.\n" + unsorted,
+			regexp.MustCompile(hclsort.DefaultGeneratedPattern),
+			true,
+		},
+		"Default header with slashes": {
+			"// Code generated by cdktf; DO NOT EDIT.\n" + unsorted,
+			regexp.MustCompile(hclsort.DefaultGeneratedPattern),
+			true,
+		},
+		"Header after code is ignored": {
+			unsorted + "# Code generated by hand. DO NOT EDIT.\n",
+			regexp.MustCompile(hclsort.DefaultGeneratedPattern),
+			false,
+		},
+		"Custom pattern": {
+			"# Generated by terraform-docs\n" + unsorted,
+			regexp.MustCompile(`^# Generated by`),
+			true,
+		},
+		"Detection disabled": {
+			"# Code generated by terraform-provider-foo. DO NOT EDIT.\n" + unsorted,
+			nil,
+			false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(tc.content), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.GeneratedPattern = tc.pattern
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if result.Skipped != tc.wantSkipped {
+				t.Errorf("Expected Skipped to be %v, got %v", tc.wantSkipped, result.Skipped)
+			}
+			if result.Changed() == tc.wantSkipped {
+				t.Errorf("Expected Changed to be %v, got:\n%s", !tc.wantSkipped, result.Sorted)
+			}
+		})
+	}
+}
+
+func TestLockFiles(t *testing.T) {
+	const lockFile = `# This file is maintained automatically by "terraform init".
+# Manual edits may be lost in future updates.
+
+provider "registry.terraform.io/hashicorp/random" {
+  version     = "3.6.3"
+  constraints = "~> 3.6"
+  hashes = [
+    "h1:zG9uFP8l9u+yGZZvi5Te7PV62j50azpgwPunq2vTm1E=",
+    "zh:04ceb65210251339f07cd4611885d242cd4d0c7306e86dda9785396807c00451",
+  ]
+}
+
+provider "registry.terraform.io/hashicorp/aws" {
+  version     = "5.82.2"
+  constraints = ">= 5.0.0"
+  hashes = [
+    "h1:ce6Dw2y4PpuqAPtnQ0dO270dRTmwEARqnf7zD3T+IkY=",
+    "zh:0262fc96012fb7e173e1b7beadd46dfc25b1dc7eaef95b90e936fc454724f1c8",
+  ]
+}
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".terraform.lock.hcl")
+	if err := os.WriteFile(path, []byte(lockFile), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.AllowedBlocks["provider"] = true
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if !result.Skipped || result.Changed() {
+		t.Errorf("Expected the lock file to be skipped unchanged, got:\n%s", result.Sorted)
+	}
+
+	if err = ingestor.Parse(path, "", false, false); err != nil {
+		t.Fatalf("Parse failed unexpectedly: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if diff := cmp.Diff(lockFile, string(got)); diff != "" {
+		t.Errorf("Lock file was rewritten (-want +got):\n%s", diff)
+	}
+}
+
+func TestExcludedBlocks(t *testing.T) {
+	content := `locals {
+  b = 2
+  a = 1
+}
+
+variable "b" {}
+
+variable "a" {}
+`
+	path := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.ExcludedBlocks = map[string]bool{"locals": true}
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+
+	want := `locals {
+  b = 2
+  a = 1
+}
+
+variable "a" {}
+
+variable "b" {}
+`
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected sorted content (-want +got):\n%s", diff)
+	}
+
+	ingestor.ExcludedBlocks = map[string]bool{"variable": true}
+	result, err = ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if !strings.Contains(string(result.Sorted), "variable \"b\" {}\n\nvariable \"a\" {}") {
+		t.Errorf("Expected excluded variables to keep their order, got:\n%s", result.Sorted)
+	}
+}
+
+func TestSortBlockKeys(t *testing.T) {
+	const hclInput = `module "vpc" {}
+module "dns" {}
+provider "google" {}
+provider "aws" {
+  alias = "west"
+}
+provider "aws" {}
+moved {
+  from = aws_instance.b
+  to   = aws_instance.c
+}
+moved {
+  to   = aws_instance.d
+  from = aws_instance.a
+}
+import {
+  to = aws_s3_bucket.logs
+  id = "logs"
+}
+import {
+  to = aws_s3_bucket.assets
+  id = "assets"
+}
+removed {
+  from = aws_instance.z
+}
+removed {
+  from = aws_instance.y
+}
+check "health" {}
+check "certificate" {}
+`
+	const want = `module "dns" {}
+
+module "vpc" {}
+
+provider "aws" {}
+
+provider "aws" {
+  alias = "west"
+}
+
+provider "google" {}
+
+moved {
+  to   = aws_instance.d
+  from = aws_instance.a
+}
+
+moved {
+  from = aws_instance.b
+  to   = aws_instance.c
+}
+
+import {
+  to = aws_s3_bucket.assets
+  id = "assets"
+}
+
+import {
+  to = aws_s3_bucket.logs
+  id = "logs"
+}
+
+removed {
+  from = aws_instance.y
+}
+
+removed {
+  from = aws_instance.z
+}
+
+check "certificate" {}
+
+check "health" {}
+`
+
+	file, err := hclsort.ParseHCLContent([]byte(hclInput), "test.tf")
+	if err != nil {
+		t.Fatalf("ParseHCLContent failed: %v", err)
+	}
+
+	allowedBlocks := map[string]bool{
+		"module": true, "provider": true, "moved": true, "import": true, "removed": true, "check": true,
+	}
+	sortedFile := hclsort.ProcessAndSortBlocks(file, allowedBlocks)
+	got := string(hclsort.FormatHCLBytes(sortedFile))
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected output for sorted block keys:\n%s", diff)
+	}
+}
+
+func TestSortMovedBlocks(t *testing.T) {
+	const hclInput = `moved {
+  to   = module.network.aws_vpc.main
+  from = aws_vpc.main
+}
+
+moved {
+  from = aws_subnet.b
+  to   = aws_subnet.private
+}
+
+moved {
+  to   = aws_subnet.public
+  from = aws_subnet.a
+}
+`
+	// Blocks are ordered by their from address while every block keeps its attributes in their
+	// original order, whether from or to comes first.
+	const want = `moved {
+  to   = aws_subnet.public
+  from = aws_subnet.a
+}
+
+moved {
+  from = aws_subnet.b
+  to   = aws_subnet.private
+}
+
+moved {
+  to   = module.network.aws_vpc.main
+  from = aws_vpc.main
+}
+`
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.AllowedBlocks = map[string]bool{"moved": true}
+	result, err := ingestor.SortSource("moved.tf", []byte(hclInput))
+	if err != nil {
+		t.Fatalf("SortSource failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output for sorted moved blocks (-want +got):\n%s", diff)
+	}
+}
+
+func TestSortImportBlocks(t *testing.T) {
+	const hclInput = `import {
+  for_each = var.buckets
+  to       = aws_s3_bucket.this[each.key]
+  id       = each.value
+}
+
+import {
+  to = aws_s3_bucket.logs
+  id = "logs-eu"
+}
+
+import {
+  id = "assets"
+  to = aws_s3_bucket.assets
+}
+
+import {
+  to = aws_s3_bucket.logs
+  id = "logs-us"
+}
+
+import {
+  for_each = var.roles
+  to       = aws_iam_role.this[each.key]
+  id       = each.value
+}
+`
+	// Blocks are ordered by their to address, then by their id when they import into the same address;
+	// for_each imports are ordered by the text of their to address like any other.
+	const want = `import {
+  for_each = var.roles
+  to       = aws_iam_role.this[each.key]
+  id       = each.value
+}
+
+import {
+  id = "assets"
+  to = aws_s3_bucket.assets
+}
+
+import {
+  to = aws_s3_bucket.logs
+  id = "logs-eu"
+}
+
+import {
+  to = aws_s3_bucket.logs
+  id = "logs-us"
+}
+
+import {
+  for_each = var.buckets
+  to       = aws_s3_bucket.this[each.key]
+  id       = each.value
+}
+`
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.AllowedBlocks = map[string]bool{"import": true}
+	result, err := ingestor.SortSource("imports.tf", []byte(hclInput))
+	if err != nil {
+		t.Fatalf("SortSource failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output for sorted import blocks (-want +got):\n%s", diff)
+	}
+}
+
+func TestSortRemovedBlocks(t *testing.T) {
+	const hclInput = `moved {
+  from = aws_instance.web
+  to   = aws_instance.app
+}
+
+moved {
+  from = aws_instance.db
+  to   = aws_db_instance.main
+}
+
+removed {
+  from = aws_instance.worker
+
+  lifecycle {
+    destroy = false
+  }
+}
+
+removed {
+  from = aws_instance.cache
+}
+`
+	// Removed blocks are ordered by their from address among themselves and stay after the moved
+	// blocks they follow.
+	const want = `moved {
+  from = aws_instance.db
+  to   = aws_db_instance.main
+}
+
+moved {
+  from = aws_instance.web
+  to   = aws_instance.app
+}
+
+removed {
+  from = aws_instance.cache
+}
+
+removed {
+  from = aws_instance.worker
+
+  lifecycle {
+    destroy = false
+  }
+}
+`
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.AllowedBlocks = map[string]bool{"moved": true, "removed": true}
+	result, err := ingestor.SortSource("refactoring.tf", []byte(hclInput))
+	if err != nil {
+		t.Fatalf("SortSource failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output for sorted removed blocks (-want +got):\n%s", diff)
+	}
+}
+
+func TestSortProviderBlocks(t *testing.T) {
+	const hclInput = `provider "google" {
+  alias = "europe"
+}
+
+provider "aws" {
+  alias  = "west"
+  region = "us-west-2"
+}
+
+provider "google" {}
+
+provider "aws" {
+  alias  = "east"
+  region = "us-east-1"
+}
+
+provider "aws" {
+  region = "eu-west-1"
+}
+`
+	// The default configuration of each provider comes first, followed by its aliases in alphabetical order.
+	const want = `provider "aws" {
+  region = "eu-west-1"
+}
+
+provider "aws" {
+  alias  = "east"
+  region = "us-east-1"
+}
+
+provider "aws" {
+  alias  = "west"
+  region = "us-west-2"
+}
+
+provider "google" {}
+
+provider "google" {
+  alias = "europe"
+}
+`
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.AllowedBlocks = map[string]bool{"provider": true}
+	result, err := ingestor.SortSource("providers.tf", []byte(hclInput))
+	if err != nil {
+		t.Fatalf("SortSource failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output for sorted provider blocks (-want +got):\n%s", diff)
+	}
+}
+
+func TestSortResourceAndDataKeys(t *testing.T) {
+	const hclInput = `resource "aws_s3_bucket" "b" {}
+resource "aws_iam_role" "z" {}
+resource "aws_s3_bucket" "a" {}
+resource "aws_s3_bucket_policy" "a" {}
+data "aws_region" "current" {}
+data "aws_iam_policy_document" "b" {}
+data "aws_iam_policy_document" "a" {}
+`
+	const want = `resource "aws_iam_role" "z" {}
+
+resource "aws_s3_bucket" "a" {}
+
+resource "aws_s3_bucket" "b" {}
+
+resource "aws_s3_bucket_policy" "a" {}
+
+data "aws_iam_policy_document" "a" {}
+
+data "aws_iam_policy_document" "b" {}
+
+data "aws_region" "current" {}
+`
+
+	file, err := hclsort.ParseHCLContent([]byte(hclInput), "test.tf")
+	if err != nil {
+		t.Fatalf("ParseHCLContent failed: %v", err)
+	}
+
+	sortedFile := hclsort.ProcessAndSortBlocks(file, map[string]bool{"resource": true, "data": true})
+	got := string(hclsort.FormatHCLBytes(sortedFile))
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected output for sorted resources and data sources:\n%s", diff)
+	}
+}
+
+func TestComparatorFor(t *testing.T) {
+	names := []string{"subnet_10", "subnet_2", "subnet_1", "subnet_02", "subnet", "subnet_b", "subnet_a10b"}
+
+	tests := map[string]struct {
+		strategy string
+		want     []string
+	}{
+		"Default strategy is lexical": {
+			"",
+			[]string{"subnet", "subnet_02", "subnet_1", "subnet_10", "subnet_2", "subnet_a10b", "subnet_b"},
+		},
+		"Natural strategy compares numbers by value": {
+			hclsort.StrategyNatural,
+			[]string{"subnet", "subnet_1", "subnet_02", "subnet_2", "subnet_10", "subnet_a10b", "subnet_b"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			compare, err := hclsort.ComparatorFor(tc.strategy)
+			if err != nil {
+				t.Fatalf("ComparatorFor failed unexpectedly: %v", err)
+			}
+
+			got := slices.Clone(names)
+			slices.SortFunc(got, compare)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Unexpected order (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("Case-insensitive comparison", func(t *testing.T) {
+		got := []string{"b", "Subnet", "a", "subnet", "B"}
+		slices.SortFunc(got, hclsort.IgnoreCase(strings.Compare))
+		if diff := cmp.Diff([]string{"a", "B", "b", "Subnet", "subnet"}, got); diff != "" {
+			t.Errorf("Unexpected order (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Collation", func(t *testing.T) {
+		tests := map[string]struct {
+			locale   string
+			strategy string
+			names    []string
+			want     []string
+		}{
+			"Danish letters":            {"da", "", []string{"å", "ø", "æ"}, []string{"æ", "ø", "å"}},
+			"German umlauts":            {"de", "", []string{"zebra", "äpfel", "apfel"}, []string{"apfel", "äpfel", "zebra"}},
+			"Natural strategy combined": {"de", hclsort.StrategyNatural, []string{"ö_10", "ö_2"}, []string{"ö_2", "ö_10"}},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				compare, err := hclsort.CollatorFor(tc.locale, tc.strategy)
+				if err != nil {
+					t.Fatalf("CollatorFor failed unexpectedly: %v", err)
+				}
+				got := slices.Clone(tc.names)
+				slices.SortFunc(got, compare)
+				if diff := cmp.Diff(tc.want, got); diff != "" {
+					t.Errorf("Unexpected order (-want +got):\n%s", diff)
+				}
+			})
+		}
+
+		if _, err := hclsort.CollatorFor("not a locale!", ""); err == nil {
+			t.Error("Expected an error for an invalid locale")
+		}
+	})
+
+	t.Run("Pinned names sort first", func(t *testing.T) {
+		got := []string{"vpc_id", "region", "cidr", "environment", "zone"}
+		slices.SortFunc(got, hclsort.PinFirst([]string{"environment", "region"}, hclsort.Reverse(strings.Compare)))
+		if diff := cmp.Diff([]string{"environment", "region", "zone", "vpc_id", "cidr"}, got); diff != "" {
+			t.Errorf("Unexpected order (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Names are grouped into buckets", func(t *testing.T) {
+		got := []string{"aws_s3_bucket", "aws_instance", "aws_iam_role", "aws_s3_bucket_policy", "aws_iam_policy"}
+		patterns := []*regexp.Regexp{regexp.MustCompile(`^aws_iam_`), regexp.MustCompile(`^aws_s3_`)}
+		slices.SortFunc(got, hclsort.GroupBy(patterns, strings.Compare))
+		want := []string{"aws_iam_policy", "aws_iam_role", "aws_s3_bucket", "aws_s3_bucket_policy", "aws_instance"}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Unexpected order (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Unknown strategy", func(t *testing.T) {
+		if _, err := hclsort.ComparatorFor("random"); err == nil {
+			t.Error("Expected an error for an unknown strategy")
+		}
+	})
+
+	t.Run("Natural strategy is used for blocks and locals", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "main.tf")
+		content := "locals {\n  az_10 = 1\n  az_2  = 2\n}\n\nvariable \"subnet_10\" {}\n\nvariable \"subnet_2\" {}\n"
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		ingestor := hclsort.NewIngestor()
+		ingestor.Compare, _ = hclsort.ComparatorFor(hclsort.StrategyNatural)
+		result, err := ingestor.Sort(path, false)
+		if err != nil {
+			t.Fatalf("Sort failed unexpectedly: %v", err)
+		}
+
+		want := "locals {\n  az_2  = 2\n  az_10 = 1\n}\n\nvariable \"subnet_2\" {}\n\nvariable \"subnet_10\" {}\n"
+		if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+			t.Errorf("Unexpected sorted content (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestBlockComparators(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.tf")
+	content := `output "backup_2024_01" {}
+
+variable "b" {}
+
+output "backup_2025_06" {}
+
+variable "a" {}
+
+locals {
+  a = 1
+  b = 2
+}
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.BlockCompare = map[string]hclsort.Comparator{
+		"output": hclsort.Reverse(strings.Compare),
+		"locals": hclsort.Reverse(strings.Compare),
+	}
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+
+	want := `locals {
+  b = 2
+  a = 1
+}
+
+output "backup_2025_06" {}
+
+output "backup_2024_01" {}
+
+variable "a" {}
+
+variable "b" {}
+`
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected sorted content (-want +got):\n%s", diff)
+	}
+}
+
+func TestStableSort(t *testing.T) {
+	const hclInput = `variable "b" {}
+
+variable "a" {
+  default = 1
+}
+
+output "a" {}
+
+variable "A" {}
+
+variable "a" {
+  default = 2
+}
+`
+
+	tests := map[string]struct {
+		compare hclsort.Comparator
+		want    string
+	}{
+		"Duplicate labels keep their original order": {
+			strings.Compare,
+			`variable "A" {}
+
+variable "a" {
+  default = 1
+}
+
+output "a" {}
+
+variable "a" {
+  default = 2
+}
+
+variable "b" {}
+`,
+		},
+		"Labels equal for the comparator keep their original order": {
+			func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) },
+			`variable "a" {
+  default = 1
+}
+
+output "a" {}
+
+variable "A" {}
+
+variable "a" {
+  default = 2
+}
+
+variable "b" {}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.Compare = tc.compare
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected sorted content (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestKeyExprs(t *testing.T) {
+	const hclInput = `resource "aws_instance" "b" {}
+
+resource "aws_instance" "a" {
+  count = 2
+}
+
+resource "aws_iam_role" "c" {}
+
+resource "aws_instance" "d" {
+  lifecycle {}
+}
+`
+	parseExpr := func(t *testing.T, src string) hcl.Expression {
+		t.Helper()
+		expr, diags := hclsyntax.ParseExpression([]byte(src), "key.hcl", hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			t.Fatalf("Failed to parse expression %q: %v", src, diags)
+		}
+		return expr
+	}
+
+	tests := map[string]struct {
+		expr    string
+		want    []string
+		wantErr string
+	}{
+		"Blocks with count first": {
+			expr: `[contains(attributes, "count") ? 0 : 1, labels[1]]`,
+			want: []string{`"a"`, `"b"`, `"c"`, `"d"`},
+		},
+		"Blocks with nested blocks last": {
+			expr: `[length(blocks), upper(labels[1])]`,
+			want: []string{`"a"`, `"b"`, `"c"`, `"d"`},
+		},
+		"Single value": {
+			expr: `labels[0] == "aws_iam_role"`,
+			want: []string{`"b"`, `"a"`, `"d"`, `"c"`},
+		},
+		"Unknown variable": {
+			expr:    `name`,
+			wantErr: `error evaluating sort key of resource "aws_instance" "b"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.AllowedBlocks = map[string]bool{"resource": true}
+			ingestor.KeyExprs = map[string]hcl.Expression{"resource": parseExpr(t, tc.expr)}
+			result, err := ingestor.Sort(path, false)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Expected error containing %q, got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+
+			file, err := hclsort.ParseHCLContent(result.Sorted, "sorted.tf")
+			if err != nil {
+				t.Fatalf("ParseHCLContent failed: %v", err)
+			}
+			got := make([]string, 0, len(tc.want))
+			for _, block := range file.Body().Blocks() {
+				got = append(got, fmt.Sprintf("%q", block.Labels()[1]))
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Unexpected block order (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBodySortPreservesInlineComments(t *testing.T) {
+	const hclInput = `locals {
+  z = 1 # hash z
+  b = 2 // slash b
+  a = { x = 1 } /* block a */
+}
+
+terraform {
+  required_providers {
+    google = { source = "hashicorp/google" } // slash google
+    aws    = { source = "hashicorp/aws" } # hash aws
+  }
+}
+`
+	const want = `locals {
+  a = { x = 1 } /* block a */
+  b = 2 // slash b
+  z = 1 # hash z
+}
+
+terraform {
+  required_providers {
+    aws    = { source = "hashicorp/aws" }    # hash aws
+    google = { source = "hashicorp/google" } // slash google
+  }
+}
+`
+
+	file, err := hclsort.ParseHCLContent([]byte(hclInput), "test.tf")
+	if err != nil {
+		t.Fatalf("ParseHCLContent failed: %v", err)
+	}
+
+	sortedFile := hclsort.ProcessAndSortBlocks(file, hclsort.NewIngestor().AllowedBlocks)
+	got := string(hclsort.FormatHCLBytes(sortedFile))
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected output for inline comments in sorted bodies:\n%s", diff)
+	}
+}
+
+func TestBodySortPreservesComments(t *testing.T) {
+	const hclInput = `locals {
+  # detached comment about z
+
+  z = 1 # trailing z
+  # lead comment for b
+  b = 2
+  /* block comment */ a = 3
+
+  # comment at end
+}
+
+terraform {
+  required_providers {
+    # google is pinned
+    google = {
+      source = "hashicorp/google"
+    }
+
+    # aws docs
+    aws = {
+      source = "hashicorp/aws"
+    }
+  }
+}
+`
+	const want = `locals {
+  /* block comment */ a = 3
+  # lead comment for b
+  b = 2
+  # detached comment about z
+
+  z = 1 # trailing z
+
+  # comment at end
+}
+
+terraform {
+  required_providers {
+    # aws docs
+    aws = {
+      source = "hashicorp/aws"
+    }
+    # google is pinned
+    google = {
+      source = "hashicorp/google"
+    }
+  }
+}
+`
+
+	file, err := hclsort.ParseHCLContent([]byte(hclInput), "test.tf")
+	if err != nil {
+		t.Fatalf("ParseHCLContent failed: %v", err)
+	}
+
+	sortedFile := hclsort.ProcessAndSortBlocks(file, hclsort.NewIngestor().AllowedBlocks)
+	got := string(hclsort.FormatHCLBytes(sortedFile))
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected output for comments in sorted bodies:\n%s", diff)
+	}
+}
+
+func TestTopLevelComments(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"Comments move with the block below them": {
+			input: `# File header
+
+# Paragraph about b
+# second line
+variable "b" {}
+
+# detached note about a
+
+variable "a" {}
+/* block lead */
+output "c" {}
+// slash lead
+output "aa" {}
+
+# trailing note
+`,
+			want: `# File header
+
+# detached note about a
+
+variable "a" {}
+
+// slash lead
+output "aa" {}
+
+# Paragraph about b
+# second line
+variable "b" {}
+
+/* block lead */
+output "c" {}
+
+# trailing note
+`,
+		},
+		"Top-level attributes are kept": {
+			input: `include = "root"
+
+variable "b" {}
+
+# about inputs
+inputs = {
+  a = 1
+}
+
+variable "a" {}
+`,
+			want: `include = "root"
+
+# about inputs
+inputs = {
+  a = 1
+}
+
+variable "a" {}
+
+variable "b" {}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, err := hclsort.ParseHCLContent([]byte(tc.input), "test.tf")
+			if err != nil {
+				t.Fatalf("ParseHCLContent failed: %v", err)
+			}
+
+			sortedFile := hclsort.ProcessAndSortBlocks(file, hclsort.NewIngestor().AllowedBlocks)
+			got := string(hclsort.FormatHCLBytes(sortedFile))
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected output for top-level comments:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSections(t *testing.T) {
+	const hclInput = `### Networking ###
+
+variable "vpc" {}
+
+variable "subnet" {}
+### Compute ###
+variable "zeta" {}
+
+# about alpha
+variable "alpha" {}
+
+# -----------------------------------
+# Outputs
+# -----------------------------------
+
+output "b" {}
+
+output "a" {}
+`
+	tests := map[string]struct {
+		pattern *regexp.Regexp
+		want    string
+	}{
+		"Blocks are sorted within sections": {
+			pattern: regexp.MustCompile(hclsort.DefaultSectionPattern),
+			want: `### Networking ###
+
+variable "subnet" {}
+
+variable "vpc" {}
+
+### Compute ###
+# about alpha
+variable "alpha" {}
+
+variable "zeta" {}
+
+# -----------------------------------
+# Outputs
+# -----------------------------------
+
+output "a" {}
+
+output "b" {}
+`,
+		},
+		"Custom pattern": {
+			pattern: regexp.MustCompile(`^# -+$`),
+			want: `### Networking ###
+
+# about alpha
+variable "alpha" {}
+
+variable "subnet" {}
+
+variable "vpc" {}
+
+### Compute ###
+variable "zeta" {}
+
+# -----------------------------------
+# Outputs
+# -----------------------------------
+
+output "a" {}
+
+output "b" {}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.SectionPattern = tc.pattern
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGroupByBlankLines(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"Groups are sorted on their own": {
+			input: `# header
+
+variable "z" {}
+variable "b" {}
+# about a
+variable "a" {}
+
+variable "y" {}
+variable "x" {}
+`,
+			want: `# header
+
+# about a
+variable "a" {}
+variable "b" {}
+variable "z" {}
+
+variable "x" {}
+variable "y" {}
+`,
+		},
+		"Banners stay above their group": {
+			input: `### Networking ###
+variable "subnet" {}
+variable "cidr" {}
+
+# Storage buckets.
+
+variable "logs" {}
+# The bucket of static assets.
+variable "assets" {}
+`,
+			want: `### Networking ###
+variable "cidr" {}
+variable "subnet" {}
+
+# Storage buckets.
+
+# The bucket of static assets.
+variable "assets" {}
+variable "logs" {}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(tc.input), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.GroupByBlankLines = true
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSectionHeaders(t *testing.T) {
+	const hclInput = `# License
+
+variable "z" {}
+
+output "b" {}
+
+variable "a" {}
+
+output "a" {}
+`
+	tests := map[string]struct {
+		header  string
+		titles  map[string]string
+		want    string
+		wantErr string
+	}{
+		"Default headers": {
+			header: hclsort.DefaultSectionHeader,
+			want: `# License
+
+# --- Variables ---
+
+variable "a" {}
+
+variable "z" {}
+
+# --- Outputs ---
+
+output "a" {}
+
+output "b" {}
+`,
+		},
+		"Custom template and titles": {
+			header: "## {{.Title}} ({{.Type}})",
+			titles: map[string]string{"variable": "Inputs"},
+			want: `# License
+
+## Inputs (variable)
+
+variable "a" {}
+
+variable "z" {}
+
+## Outputs (output)
+
+output "a" {}
+
+output "b" {}
+`,
+		},
+		"Header is not a comment": {
+			header:  "{{.Title}}",
+			wantErr: "must be a single line comment",
+		},
+		"Invalid template": {
+			header:  "# {{.Title",
+			wantErr: "invalid section header template",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			headers, err := hclsort.RenderSectionHeaders(tc.header, []string{"variable", "output"}, tc.titles)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Expected error containing %q, got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenderSectionHeaders failed unexpectedly: %v", err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.SectionHeaders = headers
+			input := hclInput
+			// Sorting the output again must not duplicate the headers.
+			for range 2 {
+				path := filepath.Join(t.TempDir(), "main.tf")
+				if err = os.WriteFile(path, []byte(input), 0600); err != nil {
+					t.Fatalf("Failed to write %s: %v", path, err)
+				}
+				result, sortErr := ingestor.Sort(path, false)
+				if sortErr != nil {
+					t.Fatalf("Sort failed unexpectedly: %v", sortErr)
+				}
+				if diff := cmp.Diff(tc.want, string(result.Sorted)); diff != "" {
+					t.Fatalf("Unexpected output (-want +got):\n%s", diff)
+				}
+				input = string(result.Sorted)
+			}
+		})
+	}
+}
+
+func TestSectionHeadersReplaceBanners(t *testing.T) {
+	const hclInput = `# License
+
+### Outputs ###
+output "b" {}
+
+###########################
+# Variables
+###########################
+# The zone.
+variable "z" {}
+
+# Note about a
+variable "a" {}
+
+output "a" {}
+`
+	const want = `# License
+
+# --- Outputs ---
+
+output "a" {}
+
+output "b" {}
+
+# --- Variables ---
+
+# Note about a
+variable "a" {}
+
+# The zone.
+variable "z" {}
+`
+	headers, err := hclsort.RenderSectionHeaders(hclsort.DefaultSectionHeader, []string{"variable", "output"}, nil)
+	if err != nil {
+		t.Fatalf("RenderSectionHeaders failed unexpectedly: %v", err)
+	}
+	ingestor := hclsort.NewIngestor()
+	ingestor.SectionHeaders = headers
+	result, err := ingestor.SortSource("main.tf", []byte(hclInput))
+	if err != nil {
+		t.Fatalf("SortSource failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestOutputBodyOrder(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"Attributes in canonical order and preconditions last": {
+			input: `output "vpc_id" {
+  depends_on = [aws_vpc.main]
+  # the VPC created by this module
+  value = aws_vpc.main.id
+  precondition {
+    condition     = aws_vpc.main.enable_dns_support
+    error_message = "DNS support must be enabled."
+  }
+  sensitive   = false
+  description = "ID of the VPC."
+}
+`,
+			want: `output "vpc_id" {
+  description = "ID of the VPC."
+  # the VPC created by this module
+  value      = aws_vpc.main.id
+  sensitive  = false
+  depends_on = [aws_vpc.main]
+
+  precondition {
+    condition     = aws_vpc.main.enable_dns_support
+    error_message = "DNS support must be enabled."
+  }
+}
+`,
+		},
+		"Ordered body is unchanged": {
+			input: `output "vpc_id" {
+  description = "ID of the VPC."
+
+  value = aws_vpc.main.id
+}
+
+output "empty" {}
+`,
+			want: `output "empty" {}
+
+output "vpc_id" {
+  description = "ID of the VPC."
+
+  value = aws_vpc.main.id
+}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, err := hclsort.ParseHCLContent([]byte(tc.input), "test.tf")
+			if err != nil {
+				t.Fatalf("ParseHCLContent failed: %v", err)
+			}
+
+			sortedFile := hclsort.ProcessAndSortBlocks(file, hclsort.NewIngestor().AllowedBlocks)
+			got := string(hclsort.FormatHCLBytes(sortedFile))
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected output body order:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortModuleParams(t *testing.T) {
+	const hclInput = `module "vpc" {
+  depends_on = [module.iam]
+  providers = {
+    aws = aws.network
+  }
+  name    = "main"
+  for_each = toset(["a", "b"])
+  cidr    = "10.0.0.0/16"
+  version = "5.0.0"
+  source  = "terraform-aws-modules/vpc/aws"
+}
+`
+	const want = `module "vpc" {
+  source   = "terraform-aws-modules/vpc/aws"
+  version  = "5.0.0"
+  for_each = toset(["a", "b"])
+  cidr     = "10.0.0.0/16"
+  name     = "main"
+  providers = {
+    aws = aws.network
+  }
+  depends_on = [module.iam]
+}
+`
+	file, err := hclsort.ParseHCLContent([]byte(hclInput), "test.tf")
+	if err != nil {
+		t.Fatalf("ParseHCLContent failed: %v", err)
+	}
+
+	sortedFile := hclsort.ProcessAndSortBlocks(file, hclsort.NewIngestor().AllowedBlocks)
+	got := string(hclsort.FormatHCLBytes(sortedFile))
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected module argument order:\n%s", diff)
+	}
+}
+
+func TestSortProviderParams(t *testing.T) {
+	const hclInput = `provider "aws" {
+  default_tags {
+    tags = {
+      team = "platform"
+    }
+  }
+  region = "eu-west-1"
+  assume_role {
+    role_arn = "arn:aws:iam::123456789012:role/deploy"
+  }
+  alias   = "network"
+  profile = "network"
+}
+`
+	const want = `provider "aws" {
+  alias   = "network"
+  profile = "network"
+  region  = "eu-west-1"
+
+  default_tags {
+    tags = {
+      team = "platform"
+    }
+  }
+
+  assume_role {
+    role_arn = "arn:aws:iam::123456789012:role/deploy"
+  }
+}
+`
+	file, err := hclsort.ParseHCLContent([]byte(hclInput), "test.tf")
+	if err != nil {
+		t.Fatalf("ParseHCLContent failed: %v", err)
+	}
+
+	sortedFile := hclsort.ProcessAndSortBlocks(file, hclsort.NewIngestor().AllowedBlocks)
+	got := string(hclsort.FormatHCLBytes(sortedFile))
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected provider body order:\n%s", diff)
+	}
+}
+
+func TestSortCheckBlocks(t *testing.T) {
+	const hclInput = `check "health" {
+  assert {
+    condition     = data.http.health.status_code == 200
+    error_message = "Health check failed."
+  }
+
+  # The endpoint is queried on every plan.
+  data "http" "health" {
+    url = "https://example.com/health"
+  }
+
+  assert {
+    condition     = can(jsondecode(data.http.health.response_body))
+    error_message = "Health check returned invalid JSON."
+  }
+}
+
+check "certificate" {
+  assert {
+    condition     = aws_acm_certificate.main.status == "ISSUED"
+    error_message = "Certificate is not issued."
+  }
+}
+`
+	const want = `check "certificate" {
+  assert {
+    condition     = aws_acm_certificate.main.status == "ISSUED"
+    error_message = "Certificate is not issued."
+  }
+}
+
+check "health" {
+  # The endpoint is queried on every plan.
+  data "http" "health" {
+    url = "https://example.com/health"
+  }
+
+  assert {
+    condition     = data.http.health.status_code == 200
+    error_message = "Health check failed."
+  }
+
+  assert {
+    condition     = can(jsondecode(data.http.health.response_body))
+    error_message = "Health check returned invalid JSON."
+  }
+}
+`
+	file, err := hclsort.ParseHCLContent([]byte(hclInput), "test.tf")
+	if err != nil {
+		t.Fatalf("ParseHCLContent failed: %v", err)
+	}
+
+	sortedFile := hclsort.ProcessAndSortBlocks(file, map[string]bool{"check": true})
+	got := string(hclsort.FormatHCLBytes(sortedFile))
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected check block order:\n%s", diff)
+	}
+}
+
+func TestSortResourceParams(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"Resource": {
+			input: `resource "aws_instance" "web" {
+  depends_on = [aws_iam_role.web]
+  tags = {
+    Name = "web"
+  }
+  lifecycle {
+    create_before_destroy = true
+  }
+  root_block_device {
+    volume_size = 20
+  }
+  instance_type = "t3.micro"
+  count         = 2
+  ami           = "ami-123456"
+}
+`,
+			want: `resource "aws_instance" "web" {
+  count         = 2
+  ami           = "ami-123456"
+  instance_type = "t3.micro"
+  tags = {
+    Name = "web"
+  }
+
+  root_block_device {
+    volume_size = 20
+  }
+
+  lifecycle {
+    create_before_destroy = true
+  }
+
+  depends_on = [aws_iam_role.web]
+}
+`,
+		},
+		"Provisioners": {
+			input: `resource "aws_instance" "web" {
+  lifecycle {
+    ignore_changes = [tags]
+  }
+  provisioner "remote-exec" {
+    inline = ["echo second"]
+  }
+  connection {
+    host = self.public_ip
+  }
+  ebs_block_device {
+    device_name = "/dev/sdb"
+  }
+  provisioner "local-exec" {
+    command = "echo third"
+  }
+  ami = "ami-123456"
+}
+`,
+			want: `resource "aws_instance" "web" {
+  ami = "ami-123456"
+
+  ebs_block_device {
+    device_name = "/dev/sdb"
+  }
+
+  provisioner "remote-exec" {
+    inline = ["echo second"]
+  }
+
+  provisioner "local-exec" {
+    command = "echo third"
+  }
+
+  connection {
+    host = self.public_ip
+  }
+
+  lifecycle {
+    ignore_changes = [tags]
+  }
+}
+`,
+		},
+		"Dynamic blocks": {
+			input: `resource "aws_security_group" "web" {
+  dynamic "ingress" {
+    content {
+      to_port   = ingress.value
+      from_port = ingress.value
+      protocol  = "tcp"
+    }
+    iterator = port
+    for_each = var.ports
+  }
+  name = "web"
+}
+`,
+			want: `resource "aws_security_group" "web" {
+  name = "web"
+
+  dynamic "ingress" {
+    for_each = var.ports
+    iterator = port
+
+    content {
+      from_port = ingress.value
+      protocol  = "tcp"
+      to_port   = ingress.value
+    }
+  }
+}
+`,
+		},
+		"Data source": {
+			input: `data "aws_ami" "ubuntu" {
+  owners   = ["099720109477"]
+  filter {
+    name   = "name"
+    values = ["ubuntu/images/*"]
+  }
+  for_each    = toset(["amd64", "arm64"])
+  most_recent = true
+}
+`,
+			want: `data "aws_ami" "ubuntu" {
+  for_each    = toset(["amd64", "arm64"])
+  most_recent = true
+  owners      = ["099720109477"]
+
+  filter {
+    name   = "name"
+    values = ["ubuntu/images/*"]
+  }
+}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, err := hclsort.ParseHCLContent([]byte(tc.input), "test.tf")
+			if err != nil {
+				t.Fatalf("ParseHCLContent failed: %v", err)
+			}
+
+			sortedFile := hclsort.ProcessAndSortBlocks(file, hclsort.NewIngestor().AllowedBlocks)
+			got := string(hclsort.FormatHCLBytes(sortedFile))
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected resource body order:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortNestedBlocks(t *testing.T) {
+	const hclInput = `resource "aws_security_group" "web" {
+  name = "web"
+  lifecycle {
+    create_before_destroy = true
+  }
+  timeouts {
+    delete = "15m"
+  }
+  ingress {
+    from_port = 443
+  }
+  egress {
+    from_port = 0
+  }
+  ingress {
+    from_port = 80
+  }
+}
+`
+	tests := map[string]struct {
+		sortNestedBlocks bool
+		want             string
+	}{
+		"Nested blocks keep their order by default": {
+			want: `resource "aws_security_group" "web" {
+  name = "web"
+
+  timeouts {
+    delete = "15m"
+  }
+
+  ingress {
+    from_port = 443
+  }
+
+  egress {
+    from_port = 0
+  }
+
+  ingress {
+    from_port = 80
+  }
+
+  lifecycle {
+    create_before_destroy = true
+  }
+}
+`,
+		},
+		"Nested blocks sorted by type": {
+			sortNestedBlocks: true,
+			want: `resource "aws_security_group" "web" {
+  name = "web"
+
+  egress {
+    from_port = 0
+  }
+
+  ingress {
+    from_port = 443
+  }
+
+  ingress {
+    from_port = 80
+  }
+
+  timeouts {
+    delete = "15m"
+  }
+
+  lifecycle {
+    create_before_destroy = true
+  }
+}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.SortNestedBlocks = tc.sortNestedBlocks
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortTerraformBlock(t *testing.T) {
+	const hclInput = `terraform {
+  provider_meta "google" {
+    module_name = "network"
+  }
+  provider_meta "aws" {
+    module_version = "1.0.0"
+    module_name    = "network"
+  }
+  backend "s3" {
+    region         = "eu-west-1"
+    key            = "network/terraform.tfstate"
+    dynamodb_table = "locks"
+    bucket         = "state"
+  }
+  required_providers {
+    random = { source = "hashicorp/random" }
+    aws    = { source = "hashicorp/aws" }
+  }
+  experiments      = [module_variable_optional_attrs]
+  required_version = ">= 1.5"
+}
+`
+	const want = `terraform {
+  required_version = ">= 1.5"
+
+  required_providers {
+    aws    = { source = "hashicorp/aws" }
+    random = { source = "hashicorp/random" }
+  }
+
+  backend "s3" {
+    bucket         = "state"
+    dynamodb_table = "locks"
+    key            = "network/terraform.tfstate"
+    region         = "eu-west-1"
+  }
+
+  experiments = [module_variable_optional_attrs]
+
+  provider_meta "aws" {
+    module_name    = "network"
+    module_version = "1.0.0"
+  }
+
+  provider_meta "google" {
+    module_name = "network"
+  }
+}
+`
+	file, err := hclsort.ParseHCLContent([]byte(hclInput), "test.tf")
+	if err != nil {
+		t.Fatalf("ParseHCLContent failed: %v", err)
+	}
+
+	sortedFile := hclsort.ProcessAndSortBlocks(file, hclsort.NewIngestor().AllowedBlocks)
+	got := string(hclsort.FormatHCLBytes(sortedFile))
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected terraform block order:\n%s", diff)
+	}
+}
+
+func TestSortCloudBlock(t *testing.T) {
+	const hclInput = `terraform {
+  cloud {
+    workspaces {
+      tags    = ["networking", "app", "${var.env}"]
+      project = "platform"
+    }
+    hostname     = "app.terraform.io"
+    organization = "example"
+  }
+}
+`
+	tests := map[string]struct {
+		sortTags bool
+		input    string
+		want     string
+	}{
+		"Workspace settings": {
+			input: hclInput,
+			want: `terraform {
+  cloud {
+    organization = "example"
+    hostname     = "app.terraform.io"
+
+    workspaces {
+      project = "platform"
+      tags    = ["networking", "app", "${var.env}"]
+    }
+  }
+}
+`,
+		},
+		"Tags with interpolations are not sorted": {
+			sortTags: true,
+			input:    hclInput,
+			want: `terraform {
+  cloud {
+    organization = "example"
+    hostname     = "app.terraform.io"
+
+    workspaces {
+      project = "platform"
+      tags    = ["networking", "app", "${var.env}"]
+    }
+  }
+}
+`,
+		},
+		"Literal tags": {
+			sortTags: true,
+			input: `terraform {
+  cloud {
+    organization = "example"
+
+    workspaces {
+      tags = [
+        "networking",
+        "app",
+      ]
+    }
+  }
+}
+`,
+			want: `terraform {
+  cloud {
+    organization = "example"
+
+    workspaces {
+      tags = [
+        "app",
+        "networking",
+      ]
+    }
+  }
+}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(tc.input), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.SortWorkspaceTags = tc.sortTags
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortDependsOn(t *testing.T) {
+	const hclInput = `resource "aws_instance" "web" {
+  ami = "ami-123456"
+  depends_on = [
+    aws_security_group.web,
+    aws_iam_role.web,
+  ]
+}
+
+module "app" {
+  source     = "./app"
+  depends_on = [module.vpc, aws_iam_role.app]
+}
+
+output "url" {
+  value      = module.app.url
+  depends_on = [module.vpc, module.app]
+}
+`
+	tests := map[string]struct {
+		sortDependsOn bool
+		want          string
+	}{
+		"Lists keep their order by default": {
+			want: hclInput,
+		},
+		"References sorted": {
+			sortDependsOn: true,
+			want: `resource "aws_instance" "web" {
+  ami = "ami-123456"
+  depends_on = [
+    aws_iam_role.web,
+    aws_security_group.web,
+  ]
+}
+
+module "app" {
+  source     = "./app"
+  depends_on = [aws_iam_role.app, module.vpc]
+}
+
+output "url" {
+  value      = module.app.url
+  depends_on = [module.app, module.vpc]
+}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.SortDependsOn = tc.sortDependsOn
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortMapAttributes(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"Multi-line tags": {
+			input: `resource "aws_vpc" "main" {
+  cidr_block = "10.0.0.0/16"
+  tags = {
+    Team = "platform"
+    Name = "main"
+    "Cost Center" = "42"
+  }
+}
+`,
+			want: `resource "aws_vpc" "main" {
+  cidr_block = "10.0.0.0/16"
+  tags = {
+    "Cost Center" = "42"
+    Name          = "main"
+    Team          = "platform"
+  }
+}
+`,
+		},
+		"Nested default tags on one line": {
+			input: `provider "aws" {
+  default_tags {
+    tags = { Team = "platform", Env = var.env }
+  }
+}
+`,
+			want: `provider "aws" {
+  default_tags {
+    tags = { Env = var.env, Team = "platform" }
+  }
+}
+`,
+		},
+		"Computed keys are not sorted": {
+			input: `resource "aws_vpc" "main" {
+  tags = {
+    Name           = "main"
+    (var.tag_name) = "value"
+  }
+}
+`,
+			want: `resource "aws_vpc" "main" {
+  tags = {
+    Name           = "main"
+    (var.tag_name) = "value"
+  }
+}
+`,
+		},
+		"Other attributes are not sorted": {
+			input: `resource "aws_vpc" "main" {
+  settings = {
+    b = 2
+    a = 1
+  }
+}
+`,
+			want: `resource "aws_vpc" "main" {
+  settings = {
+    b = 2
+    a = 1
+  }
+}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(tc.input), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.MapAttributes = map[string]bool{}
+			for _, name := range hclsort.DefaultMapAttributes {
+				ingestor.MapAttributes[name] = true
+			}
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortListPaths(t *testing.T) {
+	input := `resource "aws_instance" "web" {
+  security_groups = ["web", "default"]
+  tags_all        = ["b", "a"]
+}
+
+resource "aws_security_group" "web" {
+  ingress {
+    cidr_blocks = [
+      "10.1.0.0/16",
+      "10.0.0.0/16",
+    ]
+  }
+  egress {
+    cidr_blocks = ["10.1.0.0/16", "10.0.0.0/16"]
+  }
+}
+
+resource "aws_security_group" "mixed" {
+  ingress {
+    cidr_blocks = ["10.1.0.0/16", var.cidr_block]
+  }
+}
+`
+	want := `resource "aws_instance" "web" {
+  security_groups = ["default", "web"]
+  tags_all        = ["b", "a"]
+}
+
+resource "aws_security_group" "web" {
+  ingress {
+    cidr_blocks = [
+      "10.0.0.0/16",
+      "10.1.0.0/16",
+    ]
+  }
+  egress {
+    cidr_blocks = ["10.1.0.0/16", "10.0.0.0/16"]
+  }
+}
+
+resource "aws_security_group" "mixed" {
+  ingress {
+    cidr_blocks = ["10.1.0.0/16", var.cidr_block]
+  }
+}
+`
+	path := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(path, []byte(input), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.ListPaths = []string{"security_groups", "aws_security_group.ingress.cidr_blocks"}
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestTargets(t *testing.T) {
+	tests := map[string]struct {
+		targets []hclsort.Target
+		input   string
+		want    string
+	}{
+		"Nested blocks sorted by attribute": {
+			targets: []hclsort.Target{{
+				BlockType: "resource",
+				Label:     regexp.MustCompile(`^aws_security_group$`),
+				Path:      []string{"ingress"},
+				SortBy:    "description",
+			}},
+			input: `resource "aws_security_group" "web" {
+  name = "web"
+
+  ingress {
+    description = "https"
+    from_port   = 443
+  }
+
+  egress {
+    from_port = 0
+  }
+
+  ingress {
+    from_port = 22
+  }
+
+  ingress {
+    description = "http"
+    from_port   = 80
+  }
+}
+`,
+			want: `resource "aws_security_group" "web" {
+  name = "web"
+
+  ingress {
+    description = "http"
+    from_port   = 80
+  }
+
+  egress {
+    from_port = 0
+  }
+
+  ingress {
+    description = "https"
+    from_port   = 443
+  }
+
+  ingress {
+    from_port = 22
+  }
+}
+`,
+		},
+		"Nested attribute elements and keys": {
+			targets: []hclsort.Target{
+				{BlockType: "resource", Path: []string{"ingress", "cidr_blocks"}, SortElements: true},
+				{BlockType: "resource", Path: []string{"settings"}, SortKeys: true},
+			},
+			input: `resource "aws_security_group" "web" {
+  settings = { b = 2, a = 1 }
+
+  ingress {
+    cidr_blocks = ["10.1.0.0/16", "10.0.0.0/16"]
+  }
+}
+`,
+			want: `resource "aws_security_group" "web" {
+  settings = { a = 1, b = 2 }
+
+  ingress {
+    cidr_blocks = ["10.0.0.0/16", "10.1.0.0/16"]
+  }
+}
+`,
+		},
+		"Ignored attributes": {
+			targets: []hclsort.Target{
+				{
+					BlockType: "resource",
+					Label:     regexp.MustCompile(`^aws_instance$`),
+					Path:      []string{"tags"},
+					Ignore:    true,
+				},
+			},
+			input: `resource "aws_instance" "web" {
+  tags = { b = 2, a = 1 }
+}
+
+resource "aws_vpc" "main" {
+  tags = { b = 2, a = 1 }
+}
+`,
+			want: `resource "aws_instance" "web" {
+  tags = { b = 2, a = 1 }
+}
+
+resource "aws_vpc" "main" {
+  tags = { a = 1, b = 2 }
+}
+`,
+		},
+		"Ignored blocks": {
+			targets: []hclsort.Target{{BlockType: "variable", Label: regexp.MustCompile(`^b$`), Ignore: true}},
+			input: `variable "c" {}
+
+variable "b" {}
+
+variable "a" {}
+`,
+			want: `variable "a" {}
+
+variable "b" {}
+
+variable "c" {}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(tc.input), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.MapAttributes = map[string]bool{"tags": true}
+			ingestor.Targets = tc.targets
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortObjectTypes(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"Single-line object": {
+			input: `variable "server" {
+  type = object({ port = optional(number, 80), name = string })
+}
+`,
+			want: `variable "server" {
+  type = object({ name = string, port = optional(number, 80) })
+}
+`,
+		},
+		"Nested objects with comments": {
+			input: `variable "settings" {
+  type = list(object({
+    # Name of the setting.
+    name = string
+    options = optional(object({
+      verbose = bool
+      level   = number # Log level.
+    }), {})
+    enabled = bool
+  }))
+}
+`,
+			want: `variable "settings" {
+  type = list(object({
+    enabled = bool
+    # Name of the setting.
+    name = string
+    options = optional(object({
+      level   = number # Log level.
+      verbose = bool
+    }), {})
+  }))
+}
+`,
+		},
+		"Several attributes on a line": {
+			input: `variable "server" {
+  type = object({
+    port = number, name = string
+    host = string
+  })
+}
+`,
+			want: `variable "server" {
+  type = object({
+    port = number, name = string
+    host = string
+  })
+}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "variables.tf")
+			if err := os.WriteFile(path, []byte(tc.input), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.SortObjectTypes = true
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortVariableDefaults(t *testing.T) {
+	input := `variable "services" {
+  type = map(any)
+  default = {
+    web = {
+      port     = 80
+      replicas = 2
+      env      = { TZ = "UTC", LANG = "C" }
+    }
+    # The API service.
+    api = {
+      port = 8080
+      hosts = [
+        { name = "b", zone = "z1" },
+        { zone = "z2", name = "a" },
+      ]
+    }
+    computed = { for k, v in local.extra : k => v }
+  }
+}
+`
+	want := `variable "services" {
+  type = map(any)
+  default = {
+    # The API service.
+    api = {
+      hosts = [
+        { name = "b", zone = "z1" },
+        { name = "a", zone = "z2" },
+      ]
+      port = 8080
+    }
+    computed = { for k, v in local.extra : k => v }
+    web = {
+      env      = { LANG = "C", TZ = "UTC" }
+      port     = 80
+      replicas = 2
+    }
+  }
+}
+`
+	path := filepath.Join(t.TempDir(), "variables.tf")
+	if err := os.WriteFile(path, []byte(input), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.SortVariableDefaults = true
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestMergeTerraformBlocks(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		want     string
+		warnings []string
+	}{
+		"Blocks merged into the first": {
+			input: `terraform {
+  required_version = ">= 1.5"
+  required_providers {
+    aws = {
+      source = "hashicorp/aws"
+    }
+  }
+}
+
+variable "region" {}
+
+terraform {
+  required_version = ">= 1.5"
+  required_providers {
+    random = {
+      source = "hashicorp/random"
+    }
+  }
+  backend "s3" {
+    bucket = "state"
+  }
+}
+`,
+			want: `terraform {
+  required_version = ">= 1.5"
+  required_providers {
+    aws = {
+      source = "hashicorp/aws"
+    }
+    random = {
+      source = "hashicorp/random"
+    }
+  }
+  backend "s3" {
+    bucket = "state"
+  }
+}
+
+variable "region" {}
+`,
+		},
+		"Conflicting required versions": {
+			input: `terraform {
+  required_version = ">= 1.5"
+}
+
+terraform {
+  required_version = ">= 1.6"
+}
+`,
+			want: `terraform {
+  required_version = ">= 1.5"
+}
+
+terraform {
+  required_version = ">= 1.6"
+}
+`,
+			warnings: []string{
+				"terraform blocks at lines 1 and 5 were not merged: 'required_version' is set to different values",
+			},
+		},
+		"Several backends": {
+			input: `terraform {
+  backend "s3" {
+    bucket = "state"
+  }
+}
+
+terraform {
+  backend "local" {
+    path = "terraform.tfstate"
+  }
+}
+`,
+			want: `terraform {
+  backend "s3" {
+    bucket = "state"
+  }
+}
+
+terraform {
+  backend "local" {
+    path = "terraform.tfstate"
+  }
+}
+`,
+			warnings: []string{
+				"terraform blocks at lines 1 and 7 were not merged: more than one backend or cloud block is configured",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(tc.input), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.MergeTerraformBlocks = true
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.warnings, result.Warnings); diff != "" {
+				t.Errorf("Unexpected warnings (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDuplicateBlockWarnings(t *testing.T) {
+	input := `variable "region" {
+  default = "eu-west-1"
+}
+
+resource "aws_s3_bucket" "logs" {}
+
+variable "region" {
+  default = "us-east-1"
+}
+
+locals {
+  a = 1
+}
+
+locals {
+  b = 2
+}
+
+resource "aws_s3_bucket" "logs" {}
+
+resource "aws_s3_bucket" "state" {}
+
+variable "region" {}
+`
+	path := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(path, []byte(input), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	var printed strings.Builder
+	ingestor := hclsort.NewIngestor()
+	ingestor.Warnings = &printed
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	want := []string{
+		`duplicate variable "region" blocks at lines 1, 7 and 23`,
+		`duplicate resource "aws_s3_bucket" "logs" blocks at lines 5 and 19`,
+	}
+	if diff := cmp.Diff(want, result.Warnings); diff != "" {
+		t.Errorf("Unexpected warnings (-want +got):\n%s", diff)
+	}
+	wantPrinted := "Warning: " + path + ": " + want[0] + "\n" + "Warning: " + path + ": " + want[1] + "\n"
+	if diff := cmp.Diff(wantPrinted, printed.String()); diff != "" {
+		t.Errorf("Unexpected printed warnings (-want +got):\n%s", diff)
+	}
+
+	t.Run("Aliased providers", func(t *testing.T) {
+		providers := filepath.Join(t.TempDir(), "providers.tf")
+		src := "provider \"aws\" {\n  region = \"us-east-1\"\n}\n\n" +
+			"provider \"aws\" {\n  alias  = \"west\"\n  region = \"us-west-2\"\n}\n\n" +
+			"provider \"aws\" {\n  alias  = \"west\"\n  region = \"us-west-1\"\n}\n"
+		if writeErr := os.WriteFile(providers, []byte(src), 0600); writeErr != nil {
+			t.Fatalf("Failed to write %s: %v", providers, writeErr)
+		}
+		var providerWarnings strings.Builder
+		aliased := hclsort.NewIngestor()
+		aliased.Warnings = &providerWarnings
+		sorted, sortErr := aliased.Sort(providers, false)
+		if sortErr != nil {
+			t.Fatalf("Sort failed unexpectedly: %v", sortErr)
+		}
+		warning := `duplicate provider "aws" blocks at lines 5 and 10`
+		if diff := cmp.Diff([]string{warning}, sorted.Warnings); diff != "" {
+			t.Errorf("Unexpected warnings (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff("Warning: "+providers+": "+warning+"\n", providerWarnings.String()); diff != "" {
+			t.Errorf("Unexpected printed warnings (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestResourceGrouping(t *testing.T) {
+	const hclInput = `resource "google_storage_bucket" "logs" {
+  name = "logs"
+}
+resource "aws_s3_bucket" "state" {}
+
+# Access logs.
+resource "aws_s3_bucket" "logs" {}
+resource "azurerm_resource_group" "main" {
+  name = "main"
+}
+
+resource "aws_iam_role" "deploy" {}
+`
+	tests := map[string]struct {
+		grouping string
+		want     string
+	}{
+		"By provider": {
+			grouping: hclsort.ResourceGroupingProvider,
+			want: `resource "aws_iam_role" "deploy" {}
+# Access logs.
+resource "aws_s3_bucket" "logs" {}
+resource "aws_s3_bucket" "state" {}
+
+resource "azurerm_resource_group" "main" {
+  name = "main"
+}
+
+resource "google_storage_bucket" "logs" {
+  name = "logs"
+}
+`,
+		},
+		"By type": {
+			grouping: hclsort.ResourceGroupingType,
+			want: `resource "aws_iam_role" "deploy" {}
+
+# Access logs.
+resource "aws_s3_bucket" "logs" {}
+resource "aws_s3_bucket" "state" {}
+
+resource "azurerm_resource_group" "main" {
+  name = "main"
+}
+
+resource "google_storage_bucket" "logs" {
+  name = "logs"
+}
+`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.AllowedBlocks = map[string]bool{"resource": true}
+			ingestor.ResourceGrouping = tc.grouping
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBlockOrder(t *testing.T) {
+	const hclInput = `output "vpc_id" {
+  value = aws_vpc.main.id
+}
+
+variable "region" {}
+
+resource "aws_vpc" "main" {}
+
+terraform {
+  required_version = ">= 1.5"
+}
+
+variable "cidr" {}
+
+provider "aws" {}
+
+check "health" {}
+
+resource "aws_subnet" "private" {}
+`
+	const want = `terraform {
+  required_version = ">= 1.5"
+}
+
+provider "aws" {}
+
+resource "aws_vpc" "main" {}
+
+resource "aws_subnet" "private" {}
+
+variable "cidr" {}
+
+variable "region" {}
+
+output "vpc_id" {
+  value = aws_vpc.main.id
+}
+
+check "health" {}
+`
+	path := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.BlockOrder = []string{
+		"terraform", "provider", "locals", "data", "resource", "module", "variable", "output",
+	}
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.tf": `# Module entry point.
+
+terraform {
+  required_version = ">= 1.5"
+}
+
+# The deployment region.
+variable "region" {}
+
+resource "aws_s3_bucket" "logs" {}
+
+output "bucket" {
+  value = aws_s3_bucket.logs.id
+}
+
+# tfsort:ignore
+variable "pinned" {}
+`,
+		"variables.tf": `variable "zone" {}
+`,
+		"network.tf": `variable "cidr" {}
+`,
+		"main_override.tf": `variable "region" {
+  default = "eu-west-1"
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	results, err := hclsort.NewIngestor().Split(dir, hclsort.DefaultSplitFiles)
+	if err != nil {
+		t.Fatalf("Split failed unexpectedly: %v", err)
+	}
+
+	got := map[string]*string{}
+	for _, result := range results {
+		var content *string
+		if result.Sorted != nil {
+			sorted := string(result.Sorted)
+			content = &sorted
+		}
+		got[filepath.Base(result.Path)] = content
+	}
+	text := func(s string) *string { return &s }
+	want := map[string]*string{
+		"main.tf": text(`# Module entry point.
+
+resource "aws_s3_bucket" "logs" {}
+
+# tfsort:ignore
+variable "pinned" {}
+`),
+		"network.tf": nil,
+		"outputs.tf": text(`output "bucket" {
+  value = aws_s3_bucket.logs.id
+}
+`),
+		"variables.tf": text(`variable "cidr" {}
+
+# The deployment region.
+variable "region" {}
+
+variable "zone" {}
+`),
+		"versions.tf": text(`terraform {
+  required_version = ">= 1.5"
+}
+`),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected results (-want +got):\n%s", diff)
+	}
+}
+
+func TestConsolidate(t *testing.T) {
+	destinations := map[string]string{"variable": "variables.tf", "output": "outputs.tf"}
+	writeModule := func(t *testing.T, files map[string]string) string {
+		t.Helper()
+		dir := t.TempDir()
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+		}
+		return dir
+	}
+
+	t.Run("Gathers variables and outputs", func(t *testing.T) {
+		dir := writeModule(t, map[string]string{
+			"main.tf": `terraform {}
+
+variable "region" {}
+
+output "id" {
+  value = 1
+}
+`,
+			"network.tf": `variable "cidr" {}
+`,
+		})
+
+		results, err := hclsort.NewIngestor().Consolidate(dir, destinations)
+		if err != nil {
+			t.Fatalf("Consolidate failed unexpectedly: %v", err)
+		}
+		got := map[string]string{}
+		for _, result := range results {
+			got[filepath.Base(result.Path)] = string(result.Sorted)
+		}
+		want := map[string]string{
+			"main.tf":    "terraform {}\n",
+			"network.tf": "",
+			"outputs.tf": "output \"id\" {\n  value = 1\n}\n",
+			"variables.tf": `variable "cidr" {}
+
+variable "region" {}
+`,
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Unexpected results (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("OpenTofu files", func(t *testing.T) {
+		dir := writeModule(t, map[string]string{
+			"main.tofu":      "terraform {}\n\nvariable \"region\" {}\n",
+			"main.tf":        "variable \"ignored\" {}\n",
+			"variables.tofu": "variable \"cidr\" {}\n",
+		})
+
+		results, err := hclsort.NewIngestor().Consolidate(dir, destinations)
+		if err != nil {
+			t.Fatalf("Consolidate failed unexpectedly: %v", err)
+		}
+		got := map[string]string{}
+		for _, result := range results {
+			got[filepath.Base(result.Path)] = string(result.Sorted)
+		}
+		want := map[string]string{
+			"main.tofu":      "terraform {}\n",
+			"variables.tofu": "variable \"cidr\" {}\n\nvariable \"region\" {}\n",
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Unexpected results (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Duplicate definitions", func(t *testing.T) {
+		dir := writeModule(t, map[string]string{
+			"main.tf":          "terraform {}\n\nvariable \"region\" {}\n",
+			"network.tf":       "variable \"region\" {}\n",
+			"main_override.tf": "variable \"region\" {\n  default = \"eu-west-1\"\n}\n",
+		})
+
+		_, err := hclsort.NewIngestor().Consolidate(dir, destinations)
+		if err == nil || !strings.Contains(err.Error(), `variable "region" is defined in main.tf:3, network.tf:1`) {
+			t.Errorf("Expected duplicate definitions error, got: %v", err)
+		}
+	})
+}
+
+func TestMinimalMoves(t *testing.T) {
+	const hclInput = `variable "a" {}
+variable "b" {}
+
+
+variable "e" {}
+# about c
+variable "c" {}
+variable "f" {}
+
+variable "g" {
+  type = string
+}
+`
+	const want = `variable "a" {}
+variable "b" {}
+
+# about c
+variable "c" {}
+
+
+variable "e" {}
+variable "f" {}
+
+variable "g" {
+  type = string
+}
+`
+	path := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.MinimalMoves = true
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestPreserveFormatting(t *testing.T) {
+	const hclInput = `variable "b" {
+  type=string
+  default  =   "x"
+}
+
+resource "aws_instance" "x" {
+  ami     = "x"
+  count = 2
+  depends_on = [aws_iam_role.b,   aws_iam_role.a]
+}
+
+variable "a" {
+  description   = "a"
+}
+`
+	const want = `resource "aws_instance" "x" {
+  count = 2
+  ami     = "x"
+  depends_on = [aws_iam_role.a, aws_iam_role.b]
+}
+
+variable "a" {
+  description   = "a"
+}
+
+variable "b" {
+  type=string
+  default  =   "x"
+}
+`
+	path := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.PreserveFormatting = true
+	ingestor.SortDependsOn = true
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestAlignment(t *testing.T) {
+	const hclInput = `variable "b" {
+  type = string
+  default     = "x"
+  description = "b"
+}
+
+variable "a" {
+  description = "a"
+  type = string
+}
+`
+	tests := []struct {
+		name      string
+		alignment string
+		want      string
+	}{
+		{
+			name:      "Align",
+			alignment: hclsort.AlignmentAlign,
+			want: `variable "a" {
+  description = "a"
+  type        = string
+}
+
+variable "b" {
+  type        = string
+  default     = "x"
+  description = "b"
+}
+`,
+		},
+		{
+			name:      "Never",
+			alignment: hclsort.AlignmentNever,
+			want: `variable "a" {
+  description = "a"
+  type = string
+}
+
+variable "b" {
+  type = string
+  default = "x"
+  description = "b"
+}
+`,
+		},
+		{
+			name:      "Preserve",
+			alignment: hclsort.AlignmentPreserve,
+			want: `variable "a" {
+  description = "a"
+  type = string
+}
+
+variable "b" {
+  type = string
+  default     = "x"
+  description = "b"
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.Alignment = tt.alignment
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBlankLines(t *testing.T) {
+	const hclInput = `output "b" {
+  value = 1
+}
+variable "b" {}
+variable "a" {}
+`
+	const want = `variable "a" {}
+
+variable "b" {}
+
+
+
+output "b" {
+  value = 1
+}
+`
+	path := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.BlockOrder = []string{"variable", "output"}
+	ingestor.TypeBlankLines = 3
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestNormalizeVersions(t *testing.T) {
+	const hclInput = `module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "!=5.1.0,~>5.0"
+}
+
+terraform {
+  required_version = "<2.0.0,>=1.5.0"
+  required_providers {
+    google = "~>4.0"
+    aws = {
+      source  = "hashicorp/aws"
+      version = "< 6.0,  >=5.0"
+    }
+    random = {
+      source  = "hashicorp/random"
+      version = "${var.random_version}"
+    }
+  }
+}
+`
+	const want = `module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "~> 5.0, != 5.1.0"
+}
+
+terraform {
+  required_version = ">= 1.5.0, < 2.0.0"
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = ">= 5.0, < 6.0"
+    }
+    google = "~> 4.0"
+    random = {
+      source  = "hashicorp/random"
+      version = "${var.random_version}"
+    }
+  }
+}
+`
+	path := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.NormalizeVersions = true
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestProviderSource(t *testing.T) {
+	const hclInput = `terraform {
+  required_providers {
+    aws = {
+      source  = "HashiCorp/AWS"
+      version = "~> 5.0"
+    }
+    null = {
+      source = "registry.terraform.io/hashicorp/null"
+    }
+    random = { source = "registry.opentofu.org/hashicorp/random" }
+  }
+}
+`
+	tests := []struct {
+		name string
+		form string
+		want string
+	}{
+		{
+			name: "Short",
+			form: hclsort.ProviderSourceShort,
+			want: `terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+    null = {
+      source = "hashicorp/null"
+    }
+    random = { source = "registry.opentofu.org/hashicorp/random" }
+  }
+}
+`,
+		},
+		{
+			name: "Qualified",
+			form: hclsort.ProviderSourceQualified,
+			want: `terraform {
+  required_providers {
+    aws = {
+      source  = "registry.terraform.io/hashicorp/aws"
+      version = "~> 5.0"
+    }
+    null = {
+      source = "registry.terraform.io/hashicorp/null"
+    }
+    random = { source = "registry.opentofu.org/hashicorp/random" }
+  }
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.ProviderSource = tt.form
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortTfvars(t *testing.T) {
+	const hclInput = `zone   = "a"
+region = "eu-west-1"
+# Tags applied to every resource.
+tags = {
+  team = "platform"
+  env  = "prod"
+}
+
+instance_count = 2 # per zone
+`
+	tests := []struct {
+		name       string
+		sortValues bool
+		want       string
+	}{
+		{
+			name: "Assignments",
+			want: `instance_count = 2 # per zone
+region         = "eu-west-1"
+
+# Tags applied to every resource.
+tags = {
+  team = "platform"
+  env  = "prod"
+}
+
+zone = "a"
+`,
+		},
+		{
+			name:       "Values",
+			sortValues: true,
+			want: `instance_count = 2 # per zone
+region         = "eu-west-1"
+
+# Tags applied to every resource.
+tags = {
+  env  = "prod"
+  team = "platform"
+}
+
+zone = "a"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "prod.auto.tfvars")
+			if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.SortTfvarsValues = tt.sortValues
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortJSON(t *testing.T) {
+	t.Run("Sorts labels and required providers", func(t *testing.T) {
+		const jsonInput = `{
+    "variable": {
+        "zone": {"type": "string"},
+        "region": {"default": "eu-west-1", "validation": {"condition": "${var.region != \"\"}"}},
+        "//": "Inputs of the module."
+    },
+    "terraform": {
+        "required_providers": {
+            "random": {"source": "hashicorp/random"},
+            "aws": {"source": "hashicorp/aws", "version": ">= 5.0"}
+        }
+    }
+}
+`
+		const want = `{
+    "variable": {
+        "//": "Inputs of the module.",
+        "region": {
+            "default": "eu-west-1",
+            "validation": {
+                "condition": "${var.region != \"\"}"
+            }
+        },
+        "zone": {
+            "type": "string"
+        }
+    },
+    "terraform": {
+        "required_providers": {
+            "aws": {
+                "source": "hashicorp/aws",
+                "version": ">= 5.0"
+            },
+            "random": {
+                "source": "hashicorp/random"
+            }
+        }
+    }
+}
+`
+		path := filepath.Join(t.TempDir(), "main.tf.json")
+		if err := os.WriteFile(path, []byte(jsonInput), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		result, err := hclsort.NewIngestor().Sort(path, false)
+		if err != nil {
+			t.Fatalf("Sort failed unexpectedly: %v", err)
+		}
+		if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+			t.Errorf("Unexpected output (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Keeps sorted files unchanged", func(t *testing.T) {
+		const jsonInput = `{"variable": {"a": {}, "b": {"default": 1.50}}}` + "\n"
+		path := filepath.Join(t.TempDir(), "main.tf.json")
+		if err := os.WriteFile(path, []byte(jsonInput), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		result, err := hclsort.NewIngestor().Sort(path, false)
+		if err != nil {
+			t.Fatalf("Sort failed unexpectedly: %v", err)
+		}
+		if diff := cmp.Diff(jsonInput, string(result.Sorted)); diff != "" {
+			t.Errorf("Unexpected output (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "main.tf.json")
+		if err := os.WriteFile(path, []byte(`{"variable": {`), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		if _, err := hclsort.NewIngestor().Sort(path, false); err == nil ||
+			!strings.Contains(err.Error(), "error parsing JSON content") {
+			t.Errorf("Expected JSON parse error, got: %v", err)
+		}
+	})
+}
+
+func TestSortTestFiles(t *testing.T) {
+	const hclInput = `variables {
+  region = "eu-west-1"
+  bucket = "logs"
+}
+
+provider "aws" {
+  region = var.region
+  alias  = "test"
+}
+
+run "setup" {
+  variables {
+    zone = "a"
+    name = "setup"
+  }
+}
+
+run "apply" {
+  command = apply
+}
+`
+	const want = `variables {
+  bucket = "logs"
+  region = "eu-west-1"
+}
+
+provider "aws" {
+  alias  = "test"
+  region = var.region
+}
+
+run "setup" {
+  variables {
+    name = "setup"
+    zone = "a"
+  }
+}
+
+run "apply" {
+  command = apply
+}
+`
+	path := filepath.Join(t.TempDir(), "main.tftest.hcl")
+	if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.AllowedBlocks["run"] = true
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestSortGeneratedContents(t *testing.T) {
+	const hclInput = `generate "provider" {
+  path      = "provider.tf"
+  if_exists = "overwrite_terragrunt"
+  contents  = <<-EOF
+    provider "aws" {
+      region = "${local.region}"
+      alias = "main"
+    }
+    terraform {
+      required_providers {
+        random = { source = "hashicorp/random" }
+        aws    = { source = "hashicorp/aws" }
+      }
+    }
+  EOF
+}
+
+generate "versions" {
+  path     = "versions.tf"
+  contents = <<EOF
+variable "region" {
+  default = ${local.region}
+}
+EOF
+}
+`
+	const want = `generate "provider" {
+  path      = "provider.tf"
+  if_exists = "overwrite_terragrunt"
+  contents  = <<-EOF
+    provider "aws" {
+      alias  = "main"
+      region = "${local.region}"
+    }
+
+    terraform {
+      required_providers {
+        aws    = { source = "hashicorp/aws" }
+        random = { source = "hashicorp/random" }
+      }
+    }
+  EOF
+}
+
+generate "versions" {
+  path     = "versions.tf"
+  contents = <<EOF
+variable "region" {
+  default = ${local.region}
+}
+EOF
+}
+`
+	path := filepath.Join(t.TempDir(), "terragrunt.hcl")
+	if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	result, err := hclsort.NewIngestor().Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestSortPackerTemplates(t *testing.T) {
+	const hclInput = `source "docker" "alpine" {
+  image = "alpine"
+}
+
+build {
+  sources = ["source.amazon-ebs.ubuntu"]
+
+  provisioner "shell" {
+    inline = ["echo b"]
+  }
+
+  provisioner "file" {
+    source = "a"
+  }
+}
+
+source "amazon-ebs" "ubuntu" {
+  ami_name = "ubuntu"
+}
+
+variable "region" {}
+
+source "amazon-ebs" "debian" {
+  ami_name = "debian"
+}
+
+variable "ami" {}
+`
+	const want = `source "amazon-ebs" "debian" {
+  ami_name = "debian"
+}
+
+build {
+  sources = ["source.amazon-ebs.ubuntu"]
+
+  provisioner "shell" {
+    inline = ["echo b"]
+  }
+
+  provisioner "file" {
+    source = "a"
+  }
+}
+
+source "amazon-ebs" "ubuntu" {
+  ami_name = "ubuntu"
+}
+
+source "docker" "alpine" {
+  image = "alpine"
+}
+
+variable "ami" {}
+
+variable "region" {}
+`
+	path := filepath.Join(t.TempDir(), "image.pkr.hcl")
+	if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	result, err := hclsort.NewIngestor().Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestNomadDialect(t *testing.T) {
+	const hclInput = `job "web" {
+  type        = "service"
+  datacenters = ["dc1"]
+
+  group "frontend" {
+    count = 2
+
+    task "server" {
+      user   = "web"
+      driver = "docker"
+
+      env {
+        PORT = "8080"
+        HOST = "0.0.0.0"
+      }
+    }
+
+    task "sidecar" {
+      driver = "exec"
+    }
+  }
+
+  group "backend" {
+    count = 1
+  }
+}
+`
+	const want = `job "web" {
+  datacenters = ["dc1"]
+  type        = "service"
+
+  group "frontend" {
+    count = 2
+
+    task "server" {
+      driver = "docker"
+      user   = "web"
+
+      env {
+        HOST = "0.0.0.0"
+        PORT = "8080"
+      }
+    }
+
+    task "sidecar" {
+      driver = "exec"
+    }
+  }
+
+  group "backend" {
+    count = 1
+  }
+}
+`
+	tests := []struct {
+		name    string
+		dialect string
+		want    string
+	}{
+		{name: "Nomad", dialect: hclsort.DialectNomad, want: want},
+		{name: "Terraform", dialect: hclsort.DialectTerraform, want: hclInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "web.nomad.hcl")
+			if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.Dialect = tt.dialect
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortBakeFiles(t *testing.T) {
+	const hclInput = `target "web" {
+  tags       = ["app/web:latest", "app/web:edge"]
+  dockerfile = "web.Dockerfile"
+  context    = "."
+  args = {
+    NODE_VERSION = "20"
+    APP          = "web"
+  }
+}
+
+group "default" {
+  targets = ["web", "api"]
+}
+
+target "api" {
+  inherits = ["base"]
+  context  = "api"
+}
+
+variable "TAG" {
+  default = "latest"
+}
+`
+	const want = `target "api" {
+  context  = "api"
+  inherits = ["base"]
+}
+
+target "web" {
+  args = {
+    APP          = "web"
+    NODE_VERSION = "20"
+  }
+  context    = "."
+  dockerfile = "web.Dockerfile"
+  tags       = ["app/web:edge", "app/web:latest"]
+}
+
+group "default" {
+  targets = ["web", "api"]
+}
+
+variable "TAG" {
+  default = "latest"
+}
+`
+	path := filepath.Join(t.TempDir(), "docker-bake.hcl")
+	if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	result, err := hclsort.NewIngestor().Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestVaultDialect(t *testing.T) {
+	const hclInput = `# Manage secrets
+path "secret/*" {
+  capabilities = ["update", "read", "create"]
+}
+
+path "auth/token/lookup-self" {
+  capabilities = ["read"]
+}
+`
+	const want = `path "auth/token/lookup-self" {
+  capabilities = ["read"]
+}
+
+# Manage secrets
+path "secret/*" {
+  capabilities = ["create", "read", "update"]
+}
+`
+	tests := []struct {
+		name    string
+		dialect string
+		want    string
+	}{
+		{name: "Vault", dialect: hclsort.DialectVault, want: want},
+		{name: "Terraform", dialect: "", want: hclInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "admin.hcl")
+			if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.Dialect = tt.dialect
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAgentConfigDialects(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  string
+		hclInput string
+		want     string
+	}{
+		{
+			name:    "Vault server",
+			dialect: hclsort.DialectVaultServer,
+			hclInput: `ui           = true
+api_addr     = "https://127.0.0.1:8200"
+cluster_addr = "https://127.0.0.1:8201"
+
+listener "unix" {
+  address = "/run/vault.sock"
+}
+
+listener "tcp" {
+  address = "0.0.0.0:8200"
+}
+`,
+			want: `api_addr     = "https://127.0.0.1:8200"
+cluster_addr = "https://127.0.0.1:8201"
+ui           = true
+
+listener "tcp" {
+  address = "0.0.0.0:8200"
+}
+
+listener "unix" {
+  address = "/run/vault.sock"
+}
+`,
+		},
+		{
+			name:    "Consul",
+			dialect: hclsort.DialectConsul,
+			hclInput: `server     = true
+datacenter = "dc1"
+
+ports {
+  grpc = 8502
+}
+
+data_dir = "/opt/consul"
+`,
+			want: `data_dir   = "/opt/consul"
+datacenter = "dc1"
+
+ports {
+  grpc = 8502
+}
+
+server = true
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "agent.hcl")
+			if err := os.WriteFile(path, []byte(tt.hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.Dialect = tt.dialect
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortWaypointFiles(t *testing.T) {
+	const hclInput = `project = "shop"
+
+app "web" {
+  build {
+    use "pack" {}
+
+    hook {
+      when    = "before"
+      command = ["make", "assets"]
+    }
+  }
+}
+
+variable "region" {}
+
+app "api" {
+  build {
+    use "docker" {}
+  }
+}
+`
+	const want = `project = "shop"
+
+app "api" {
+  build {
+    use "docker" {}
+  }
+}
+
+app "web" {
+  build {
+    use "pack" {}
+
+    hook {
+      when    = "before"
+      command = ["make", "assets"]
+    }
+  }
+}
+
+variable "region" {}
+`
+	path := filepath.Join(t.TempDir(), "waypoint.hcl")
+	if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	// Waypoint files are recognized by their name, whatever the dialect of the other files.
+	ingestor := hclsort.NewIngestor()
+	ingestor.AllowedBlocks = map[string]bool{"output": true}
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestGenericDialect(t *testing.T) {
+	const hclInput = `locals {
+  b = 1
+  a = 2
+}
+
+stage "test" {
+  name    = "Test"
+  timeout = 30
+  image   = "golang"
+}
+
+env "eu" "prod" {}
+
+stage "build" {
+  name  = "Build"
+  image = "golang"
+}
+
+env "us" "dev" {}
+
+env "eu" "dev" {}
+`
+	const want = `locals {
+  b = 1
+  a = 2
+}
+
+stage "build" {
+  name  = "Build"
+  image = "golang"
+}
+
+stage "test" {
+  name    = "Test"
+  image   = "golang"
+  timeout = 30
+}
+
+env "eu" "dev" {}
+
+env "us" "dev" {}
+
+env "eu" "prod" {}
+`
+	path := filepath.Join(t.TempDir(), "pipeline.hcl")
+	if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.Dialect = hclsort.DialectGeneric
+	ingestor.BlockSchemas = map[string]hclsort.BlockSchema{
+		"stage": {KeyLabels: []int{0}, Pinned: []string{"name"}},
+		"env":   {KeyLabels: []int{1, 0}},
+	}
+	result, err := ingestor.Sort(path, false)
+	if err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestSortTerramateFiles(t *testing.T) {
+	const hclInput = `stack {
+  after       = ["/stacks/network"]
+  tags        = ["prod"]
+  description = "Application stack"
+  name        = "app"
+  id          = "7b9b2a5c"
+}
+
+globals {
+  region = "eu-west-1"
+  env    = "prod"
+}
+
+globals "app" {
+  version = "1.2.0"
+  name    = "shop"
+}
+`
+	const want = `stack {
+  id          = "7b9b2a5c"
+  name        = "app"
+  description = "Application stack"
+  tags        = ["prod"]
+  after       = ["/stacks/network"]
+}
+
+globals {
+  env    = "prod"
+  region = "eu-west-1"
+}
+
+globals "app" {
+  name    = "shop"
+  version = "1.2.0"
+}
+`
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{name: "Terramate file", file: "stack.tm.hcl", want: want},
+		{name: "Other file", file: "stack.hcl", want: hclInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			result, err := hclsort.NewIngestor().Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOverrideFiles(t *testing.T) {
+	const hclInput = `output "region" {
+  value       = var.region
+  description = "Region"
+}
+
+output "environment" {}
+`
+	const contentsSorted = `output "region" {
+  description = "Region"
+  value       = var.region
+}
+
+output "environment" {}
+`
+	const sorted = `output "environment" {}
+
+output "region" {
+  description = "Region"
+  value       = var.region
+}
+`
+	tests := []struct {
+		name        string
+		file        string
+		handling    string
+		want        string
+		wantSkipped bool
+	}{
+		{name: "Contents by default", file: "main_override.tf", want: contentsSorted},
+		{name: "Override file", file: "override.tf", handling: hclsort.OverrideFilesContents, want: contentsSorted},
+		{
+			name:        "Skip",
+			file:        "main_override.tf",
+			handling:    hclsort.OverrideFilesSkip,
+			want:        hclInput,
+			wantSkipped: true,
+		},
+		{name: "Sort", file: "main_override.tf", handling: hclsort.OverrideFilesSort, want: sorted},
+		{name: "Other file", file: "main.tf", handling: hclsort.OverrideFilesSkip, want: sorted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(hclInput), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			ingestor := hclsort.NewIngestor()
+			ingestor.OverrideFiles = tt.handling
+			result, err := ingestor.Sort(path, false)
+			if err != nil {
+				t.Fatalf("Sort failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(result.Sorted)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+			if result.Skipped != tt.wantSkipped {
+				t.Errorf("Expected Skipped to be %v, got %v", tt.wantSkipped, result.Skipped)
+			}
+		})
+	}
+}
+
+func TestSortContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ingestor := hclsort.NewIngestor()
+
+	_, err := ingestor.SortSourceContext(ctx, "main.tf", []byte("variable \"a\" {}\n"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("SortSourceContext: expected context.Canceled, got: %v", err)
+	}
+	if _, err = ingestor.SortContext(ctx, validFilePath, false); !errors.Is(err, context.Canceled) {
+		t.Errorf("SortContext: expected context.Canceled, got: %v", err)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), outputFile)
+	if err = ingestor.ParseContext(ctx, validFilePath, outputPath, false, false); !errors.Is(err, context.Canceled) {
+		t.Errorf("ParseContext: expected context.Canceled, got: %v", err)
+	}
+	if _, err = os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Errorf("Expected %s not to be written, got: %v", outputPath, err)
+	}
+}
+
+func TestParseErrorDiagnostics(t *testing.T) {
+	_, err := hclsort.ParseHCLContent([]byte("variable \"a\" {\n  default = 1\n"), "main.tf")
+	var parseErr *hclsort.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected a *ParseError, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "error parsing HCL content from 'main.tf'") {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+
+	want := []hclsort.Diagnostic{{
+		Filename: "main.tf",
+		Start:    hclsort.Position{Line: 1, Column: 14},
+		End:      hclsort.Position{Line: 1, Column: 15},
+		Severity: hclsort.SeverityError,
+		Code:     "unclosed-configuration-block",
+		Summary:  "Unclosed configuration block",
+		Detail: "There is no closing brace for this block before the end of the file. " +
+			"This may be caused by incorrect brace nesting elsewhere in this file.",
+	}}
+	if diff := cmp.Diff(want, parseErr.Diagnostics); diff != "" {
+		t.Errorf("Unexpected diagnostics (-want +got):\n%s", diff)
+	}
+	got := parseErr.Diagnostics[0].String()
+	if !strings.HasPrefix(got, "main.tf:1,14-15: Unclosed configuration block; ") {
+		t.Errorf("Unexpected diagnostic description: %s", got)
+	}
+}
+
+// TestPluginHelperProcess is not a real test: it is run as the plugin of TestPlugins, reversing the
+// attributes of the blocks it is sent and prefixing the files it is sent with a comment. Files holding
+// "hang" make it sleep past the timeout of the plugin.
+func TestPluginHelperProcess(t *testing.T) {
+	if os.Getenv("TFSORT_TEST_PLUGIN") != "1" {
+		t.Skip("helper process for TestPlugins")
+	}
+	type block struct {
+		Type   string `json:"type"`
+		Source string `json:"source"`
+	}
+	var request struct {
+		Path   string  `json:"path"`
+		Source string  `json:"source"`
+		Blocks []block `json:"blocks"`
+	}
+	if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	response := map[string]any{}
+	switch {
+	case strings.Contains(request.Source, "hang"):
+		time.Sleep(time.Minute)
+	case strings.Contains(request.Source, "empty"):
+		response["source"] = ""
+	case strings.Contains(request.Source, "mangle"):
+		response["source"] = "mangled {\n"
+	case request.Source != "":
+		response["source"] = "# Sorted by plugin\n" + request.Source
+	case slices.ContainsFunc(request.Blocks, func(b block) bool { return b.Type == "broken" }):
+		response["error"] = "cannot sort broken blocks"
+	default:
+		var blocks []map[string]string
+		for _, b := range request.Blocks {
+			file, err := hclsort.ParseHCLContent([]byte(b.Source), request.Path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			reversed := func(a, b string) int { return strings.Compare(b, a) }
+			hclsort.SortAttributes(file.Body().Blocks()[0].Body(), reversed)
+			blocks = append(blocks, map[string]string{"source": string(file.Bytes())})
+		}
+		response["blocks"] = blocks
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(response); err != nil {
+		os.Exit(2)
+	}
+	os.Exit(0)
+}
+
+func TestPlugins(t *testing.T) {
+	t.Setenv("TFSORT_TEST_PLUGIN", "1")
+	plugin := hclsort.Plugin{
+		Name:       "test",
+		Command:    []string{os.Args[0], "-test.run=^TestPluginHelperProcess$"},
+		BlockTypes: []string{"widget", "broken"},
+		FileTypes:  []string{"acme"},
+	}
+	ingestor := hclsort.NewIngestor()
+	ingestor.Plugins = []hclsort.Plugin{plugin}
+	ingestor.AllowedBlocks["widget"] = true
+
+	t.Run("Block types", func(t *testing.T) {
+		src := "widget \"b\" {\n  a = 1\n  b = 2\n}\n\nwidget \"a\" {\n  a = 1\n  c = 3\n}\n\n" +
+			"locals {\n  b = 1\n  a = 2\n}\n\n# tfsort:ignore\nwidget \"c\" {\n  a = 1\n  b = 2\n}\n"
+		result, err := ingestor.SortSource("main.tf", []byte(src))
+		if err != nil {
+			t.Fatalf("SortSource failed unexpectedly: %v", err)
+		}
+		want := "locals {\n  a = 2\n  b = 1\n}\n\nwidget \"a\" {\n  c = 3\n  a = 1\n}\n\n" +
+			"widget \"b\" {\n  b = 2\n  a = 1\n}\n\n# tfsort:ignore\nwidget \"c\" {\n  a = 1\n  b = 2\n}\n"
+		if diff := cmp.Diff(want, string(result.Sorted)); diff != "" {
+			t.Errorf("Unexpected output (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("File types", func(t *testing.T) {
+		result, err := ingestor.SortSource("main.acme", []byte("b = 1\n"))
+		if err != nil {
+			t.Fatalf("SortSource failed unexpectedly: %v", err)
+		}
+		if diff := cmp.Diff("# Sorted by plugin\nb = 1\n", string(result.Sorted)); diff != "" {
+			t.Errorf("Unexpected output (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Invalid file responses", func(t *testing.T) {
+		for src, want := range map[string]string{
+			"empty = 1\n":  "plugin 'test' returned no source",
+			"mangle = 1\n": "plugin 'test' returned invalid source",
+		} {
+			_, err := ingestor.SortSource("main.acme", []byte(src))
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected an error containing %q for %q, got: %v", want, src, err)
+			}
+		}
+	})
+
+	t.Run("Plugin error", func(t *testing.T) {
+		_, err := ingestor.SortSource("main.tf", []byte("broken {\n  a = 1\n}\n"))
+		if err == nil || !strings.Contains(err.Error(), "plugin 'test' failed: cannot sort broken blocks") {
+			t.Errorf("Expected the error of the plugin, got: %v", err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		hanging := plugin
+		hanging.Timeout = 100 * time.Millisecond
+		timed := hclsort.NewIngestor()
+		timed.Plugins = []hclsort.Plugin{hanging}
+		start := time.Now()
+		_, err := timed.SortSource("main.acme", []byte("hang = 1\n"))
+		if err == nil || !strings.Contains(err.Error(), "plugin 'test' did not finish within 100ms") {
+			t.Errorf("Expected the plugin to be stopped, got: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("Expected the plugin to stop after its timeout, took %v", elapsed)
+		}
+	})
+
+	t.Run("Missing command", func(t *testing.T) {
+		missing := hclsort.NewIngestor()
+		missing.Plugins = []hclsort.Plugin{
+			{Name: "missing", Command: []string{"tfsort-missing-plugin"}, FileTypes: []string{"acme"}},
+		}
+		_, err := missing.SortSource("main.acme", []byte("a\n"))
+		if err == nil || !strings.Contains(err.Error(), "plugin 'missing' failed") {
+			t.Errorf("Expected the plugin to fail, got: %v", err)
+		}
+	})
+}
+
+func TestWasmComparator(t *testing.T) {
+	reverse := filepath.Join(testDataBaseDir, "wasm", "reverse.wasm")
+	rule, err := hclsort.NewWasmComparator(context.Background(), reverse)
+	if err != nil {
+		t.Fatalf("NewWasmComparator failed unexpectedly: %v", err)
+	}
+	t.Cleanup(func() { _ = rule.Close() })
+	names := []string{"b", "abc", "c", "ab", ""}
+	slices.SortFunc(names, rule.Compare)
+	if diff := cmp.Diff([]string{"c", "b", "abc", "ab", ""}, names); diff != "" {
+		t.Errorf("Unexpected order (-want +got):\n%s", diff)
+	}
+
+	ingestor := hclsort.NewIngestor()
+	ingestor.Compare = rule.Compare
+	result, err := ingestor.SortSource("main.tf", []byte("variable \"a\" {}\n\nvariable \"b\" {}\n"))
+	if err != nil {
+		t.Fatalf("SortSource failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff("variable \"b\" {}\n\nvariable \"a\" {}\n", string(result.Sorted)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+
+	t.Run("Missing exports", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "empty.wasm")
+		if writeErr := os.WriteFile(path, []byte("\x00asm\x01\x00\x00\x00"), 0o600); writeErr != nil {
+			t.Fatal(writeErr)
+		}
+		_, loadErr := hclsort.NewWasmComparator(context.Background(), path)
+		if loadErr == nil || !strings.Contains(loadErr.Error(), "the module must export memory") {
+			t.Errorf("Expected a missing export error, got: %v", loadErr)
+		}
+	})
+
+	t.Run("Invalid module", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "invalid.wasm")
+		if writeErr := os.WriteFile(path, []byte("not wasm"), 0o600); writeErr != nil {
+			t.Fatal(writeErr)
+		}
+		_, loadErr := hclsort.NewWasmComparator(context.Background(), path)
+		if loadErr == nil || !strings.Contains(loadErr.Error(), "invalid sorting rule") {
+			t.Errorf("Expected an invalid module error, got: %v", loadErr)
+		}
+	})
+
+	t.Run("Looping module", func(t *testing.T) {
+		start := time.Now()
+		loop := filepath.Join(testDataBaseDir, "wasm", "loop.wasm")
+		_, loadErr := hclsort.NewWasmComparator(context.Background(), loop)
+		if loadErr == nil || !strings.Contains(loadErr.Error(), "tfsort_compare failed") {
+			t.Errorf("Expected the comparison to be stopped, got: %v", loadErr)
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("Expected the comparison to stop after its timeout, took %v", elapsed)
+		}
+	})
+
+	t.Run("Cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelled, loadErr := hclsort.NewWasmComparator(ctx, reverse)
+		if loadErr != nil {
+			t.Fatalf("NewWasmComparator failed unexpectedly: %v", loadErr)
+		}
+		t.Cleanup(func() { _ = cancelled.Close() })
+		cancel()
+		if got := cancelled.Compare("a", "b"); got >= 0 {
+			t.Errorf("Expected names to be compared by their bytes once ctx is done, got %d", got)
+		}
+	})
+
+	t.Run("Closed comparator", func(t *testing.T) {
+		closed, loadErr := hclsort.NewWasmComparator(context.Background(), reverse)
+		if loadErr != nil {
+			t.Fatalf("NewWasmComparator failed unexpectedly: %v", loadErr)
+		}
+		if got := closed.Compare("a", "b"); got <= 0 {
+			t.Errorf("Expected the module to reverse the order of names, got %d", got)
+		}
+		if closeErr := closed.Close(); closeErr != nil {
+			t.Fatalf("Close failed unexpectedly: %v", closeErr)
+		}
+		if got := closed.Compare("a", "b"); got >= 0 {
+			t.Errorf("Expected names to be compared by their bytes once closed, got %d", got)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package hclsort
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// isVariablesFile reports whether path names a variable definitions file, such as terraform.tfvars,
+// prod.auto.tfvars or a Packer prod.pkrvars.hcl file, whose top-level assignments are sorted by name.
+func isVariablesFile(path string) bool {
+	return filepath.Ext(path) == ".tfvars" || strings.HasSuffix(path, ".pkrvars.hcl")
+}
+
+// sortAssignmentValues sorts the keys of the object literals assigned to the top-level attributes of
+// body, at every nesting level, using compare.
+func sortAssignmentValues(body *hclwrite.Body, compare Comparator) {
+	for name := range body.Attributes() {
+		sortObjectAttribute(body, name, compare, objectLiteralOpening, anyDepth)
+	}
+}
+
+// sortAssignments orders the top-level attributes among items by name using compare, leaving the
+// other items in place. Comments above an attribute move with it.
+func sortAssignments(items []*topLevelItem, compare Comparator) {
+	var assignments []*topLevelItem
+	var slots []int
+	for i, item := range items {
+		if isAssignment(item) {
+			assignments = append(assignments, item)
+			slots = append(slots, i)
+		}
+	}
+	slices.SortStableFunc(assignments, func(a, b *topLevelItem) int {
+		return compare(assignmentName(a), assignmentName(b))
+	})
+	for i, slot := range slots {
+		items[slot] = assignments[i]
+	}
+}
+
+// joinAssignments keeps consecutive top-level attributes on adjacent lines, unless either spans
+// multiple lines or the second has comments above it.
+func joinAssignments(items []*topLevelItem) {
+	for i := 0; i < len(items)-1; i++ {
+		current, next := items[i], items[i+1]
+		if isAssignment(current) && isAssignment(next) && isSingleLine(current) && isSingleLine(next) {
+			current.joinNext = true
+		}
+	}
+}
+
+// isSingleLine reports whether the top-level attribute of item, including comments above it, is on
+// a single line.
+func isSingleLine(item *topLevelItem) bool {
+	if len(item.lead) > 0 || leadTokenCount(item.tokens) > 0 {
+		return false
+	}
+	newlines := 0
+	for _, token := range item.tokens {
+		if endsLine(token) {
+			newlines++
+		}
+	}
+	return newlines <= 1
+}
+
+// isAssignment reports whether the item is a top-level attribute.
+func isAssignment(item *topLevelItem) bool {
+	return item.block == nil && item.verbatim == nil && !item.pinned && len(item.tokens) > 0
+}
+
+// assignmentName returns the name of the top-level attribute of item, which follows the comments
+// above it.
+func assignmentName(item *topLevelItem) string {
+	if count := leadTokenCount(item.tokens); count < len(item.tokens) {
+		return string(item.tokens[count].Bytes)
+	}
+	return ""
+}
@@ -0,0 +1,237 @@
+package hclsort
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// tokenRange is a half-open range of indices into a token stream.
+type tokenRange struct {
+	start int
+	end   int
+}
+
+// overlaps reports whether two ranges share at least one token.
+func (r tokenRange) overlaps(other tokenRange) bool {
+	return r.start < other.end && other.start < r.end
+}
+
+// topLevelItems splits a body into the blocks, attributes, comments and verbatim regions that are
+// moved as units. Blocks that overlap a tfsort:off/tfsort:on region become part of that region,
+// except for a block whose lead comments merely end the region: it keeps the tokens after the marker.
+// Comments between two items move with the item below them. Comments before the first item that
+// are separated from it by a blank line, such as a license header, and comments after the last item
+// become pinned items so they stay at the top and the bottom of the body.
+func topLevelItems(body *hclwrite.Body) []*topLevelItem {
+	tokens := body.BuildTokens(nil)
+	blocks := body.Blocks()
+
+	blockRanges := make([]tokenRange, len(blocks))
+	leadRanges := make([]tokenRange, len(blocks))
+	position := 0
+	for i, block := range blocks {
+		blockTokens := block.BuildTokens(nil)
+		for position < len(tokens) && tokens[position] != blockTokens[0] {
+			position++
+		}
+		blockRanges[i] = tokenRange{start: position, end: position + len(blockTokens)}
+		leadRanges[i] = tokenRange{start: position, end: position + leadTokenCount(blockTokens)}
+		position = blockRanges[i].end
+	}
+
+	regions := verbatimRegions(tokens, blockRanges, leadRanges)
+
+	items := make([]*topLevelItem, 0, len(blocks))
+	regionIndex := 0
+	for i, block := range blocks {
+		for regionIndex < len(regions) && regions[regionIndex].end <= blockRanges[i].start {
+			regionIndex++
+		}
+		if regionIndex >= len(regions) || !regions[regionIndex].overlaps(blockRanges[i]) {
+			items = append(items, &topLevelItem{block: block, start: blockRanges[i].start, end: blockRanges[i].end})
+			continue
+		}
+
+		region := &regions[regionIndex]
+		if region.end <= leadRanges[i].end && region.start <= blockRanges[i].start {
+			// The region ends within this block's lead comments.
+			items = append(items, &topLevelItem{
+				block:      block,
+				start:      region.end,
+				end:        blockRanges[i].end,
+				skipTokens: region.end - blockRanges[i].start,
+			})
+			continue
+		}
+
+		region.start = min(region.start, blockRanges[i].start)
+		region.end = max(region.end, blockRanges[i].end)
+	}
+
+	for _, region := range regions {
+		items = append(items, &topLevelItem{
+			start:    region.start,
+			end:      region.end,
+			verbatim: tokens[region.start:region.end].Bytes(),
+			pinned:   true,
+		})
+	}
+	items = append(items, attributeItems(tokens, body, regions)...)
+	slices.SortFunc(items, func(a, b *topLevelItem) int {
+		return a.start - b.start
+	})
+
+	return attachComments(tokens, items)
+}
+
+// attributeItems returns an item for every top-level attribute of body that is not part of a region.
+func attributeItems(tokens hclwrite.Tokens, body *hclwrite.Body, regions []tokenRange) []*topLevelItem {
+	starts := make(map[*hclwrite.Token]int, len(tokens))
+	for i, token := range tokens {
+		starts[token] = i
+	}
+
+	var items []*topLevelItem
+	for _, attr := range body.Attributes() {
+		attrTokens := attr.BuildTokens(nil)
+		attrRange := tokenRange{start: starts[attrTokens[0]], end: starts[attrTokens[0]] + len(attrTokens)}
+		if slices.ContainsFunc(regions, attrRange.overlaps) {
+			continue
+		}
+		items = append(items, &topLevelItem{
+			tokens: attrTokens,
+			start:  attrRange.start,
+			end:    attrRange.end,
+		})
+	}
+	return items
+}
+
+// attachComments assigns the tokens between items, which are comments and newlines, to the item
+// below them. The comments before the first blank line above the first item and the comments after
+// the last item are returned as pinned items instead.
+func attachComments(tokens hclwrite.Tokens, items []*topLevelItem) []*topLevelItem {
+	result := make([]*topLevelItem, 0, len(items)+2)
+	position := 0
+	for i, item := range items {
+		gap := tokens[position:item.start]
+		position = item.end
+		item.blankBefore = lastBlankLine(gap) > 0
+
+		if i == 0 {
+			split := lastBlankLine(gap)
+			if header := trimNewlines(gap[:split]); len(header) > 0 {
+				result = append(result, &topLevelItem{tokens: header, start: 0, end: split, pinned: true})
+			}
+			gap = gap[split:]
+		}
+		item.lead = trimLeadingNewlines(gap)
+		if i > 0 {
+			item.separator = gap[:len(gap)-len(item.lead)]
+		}
+		result = append(result, item)
+	}
+
+	if trailing := trimNewlines(tokens[position:]); len(trailing) > 0 {
+		result = append(result, &topLevelItem{
+			tokens:      trailing,
+			start:       position,
+			end:         len(tokens),
+			pinned:      true,
+			blankBefore: lastBlankLine(tokens[position:]) > 0,
+		})
+	}
+	return result
+}
+
+// lastBlankLine returns the index after the last newline token in tokens that ends a blank line,
+// or zero when tokens contain no blank line. A newline token following another newline token or
+// a line comment, which includes its own newline, ends a blank line.
+func lastBlankLine(tokens hclwrite.Tokens) int {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if tokens[i].Type != hclsyntax.TokenNewline {
+			continue
+		}
+		if i == 0 || endsLine(tokens[i-1]) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// endsLine reports whether a token ends the line it is on.
+func endsLine(token *hclwrite.Token) bool {
+	return token.Type == hclsyntax.TokenNewline ||
+		(token.Type == hclsyntax.TokenComment && bytes.HasSuffix(token.Bytes, []byte("\n")))
+}
+
+// trimLeadingNewlines returns tokens without leading newline tokens.
+func trimLeadingNewlines(tokens hclwrite.Tokens) hclwrite.Tokens {
+	for len(tokens) > 0 && tokens[0].Type == hclsyntax.TokenNewline {
+		tokens = tokens[1:]
+	}
+	return tokens
+}
+
+// verbatimRegions finds the ranges fenced by top-level tfsort:off and tfsort:on comments.
+// A region without a closing tfsort:on extends to the end of the body.
+func verbatimRegions(tokens hclwrite.Tokens, blockRanges, leadRanges []tokenRange) []tokenRange {
+	var regions []tokenRange
+	open := -1
+
+	for i, token := range tokens {
+		if token.Type != hclsyntax.TokenComment || !isTopLevelToken(i, blockRanges, leadRanges) {
+			continue
+		}
+
+		switch commentDirective(token.Bytes) {
+		case directiveOff:
+			if open < 0 {
+				open = i
+			}
+		case directiveOn:
+			if open < 0 {
+				continue
+			}
+			end := i + 1
+			// Unlike line comments, block comments do not include their trailing newline.
+			if !bytes.HasSuffix(token.Bytes, []byte("\n")) && end < len(tokens) &&
+				tokens[end].Type == hclsyntax.TokenNewline {
+				end++
+			}
+			regions = append(regions, tokenRange{start: open, end: end})
+			open = -1
+		}
+	}
+
+	if open >= 0 {
+		regions = append(regions, tokenRange{start: open, end: len(tokens)})
+	}
+	return regions
+}
+
+// isTopLevelToken reports whether the token at index is outside every block body.
+// Lead comments of blocks are considered top-level.
+func isTopLevelToken(index int, blockRanges, leadRanges []tokenRange) bool {
+	for i, blockRange := range blockRanges {
+		if index >= blockRange.start && index < blockRange.end {
+			return index < leadRanges[i].end
+		}
+	}
+	return true
+}
+
+// leadTokenCount returns the number of comment and newline tokens at the start of a block's tokens.
+func leadTokenCount(tokens hclwrite.Tokens) int {
+	count := 0
+	for _, token := range tokens {
+		if token.Type != hclsyntax.TokenComment && token.Type != hclsyntax.TokenNewline {
+			break
+		}
+		count++
+	}
+	return count
+}
@@ -1,18 +1,234 @@
 package hclsort
 
-import "github.com/hashicorp/hcl/v2/hclwrite"
+import (
+	"bytes"
+	"io"
+	"regexp"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
 
 // StdInPathIdentifier is a marker for when input is read from stdin.
 const StdInPathIdentifier = "<stdin>"
 
+// DefaultGeneratedPattern matches the header comments of generated files, such as
+// "# Code generated by terraform-provider-foo. DO NOT EDIT.".
+const DefaultGeneratedPattern = `^(#|//|/\*)\s*Code generated .*DO NOT EDIT`
+
+// DefaultSectionPattern matches banner comments that delimit the sections of a file, such as
+// "### Networking ###" or "# --- Variables ---".
+const DefaultSectionPattern = `^(#{3,}|(#|//)\s*[-=*#]{3,})`
+
 // Ingestor is a struct that contains the logic for parsing Terraform files.
 type Ingestor struct {
 	AllowedTypes  map[string]bool
 	AllowedBlocks map[string]bool
+	// ExcludedBlocks lists block types that are left untouched, including the contents of
+	// locals and terraform blocks that are otherwise always sorted.
+	ExcludedBlocks map[string]bool
+	// Compare orders block labels and attribute names; nil compares them lexically.
+	Compare Comparator
+	// BlockCompare overrides Compare for the labels and contents of individual block types.
+	BlockCompare map[string]Comparator
+	// KeyExprs holds HCL expressions computing the sort keys of individual block types.
+	// They are evaluated with the variables type, labels, attributes and blocks; see keyExprContext.
+	KeyExprs map[string]hcl.Expression
+	// GeneratedPattern matches header comments of generated files, which are left untouched.
+	// A nil pattern processes generated files like any other file.
+	GeneratedPattern *regexp.Regexp
+	// SectionPattern matches banner comments that partition a file into sections. Blocks are sorted
+	// within their section and never moved across banners. A nil pattern sorts the file as a whole.
+	SectionPattern *regexp.Regexp
+	// GroupByBlankLines sorts blocks only within groups of adjacent lines, so blocks are never moved
+	// across a blank line. The comments above the first block of a group stay at the top of the group.
+	GroupByBlankLines bool
+	// SectionHeaders holds the comments inserted above the blocks of each listed type, such as
+	// "# --- Variables ---"; see RenderSectionHeaders. Previously inserted headers are replaced, as are
+	// banner comments matching DefaultSectionPattern above blocks unless SectionPattern is set.
+	SectionHeaders map[string]string
+	// SortNestedBlocks sorts the nested blocks of resource, data and module bodies by type and labels.
+	// Provisioner, connection and lifecycle blocks keep their places at the end of resource bodies.
+	SortNestedBlocks bool
+	// SortWorkspaceTags sorts lists of literal tags in the workspaces block of cloud blocks.
+	SortWorkspaceTags bool
+	// SortDependsOn sorts the references in the depends_on lists of resources, data sources, modules
+	// and outputs.
+	SortDependsOn bool
+	// MapAttributes lists attributes, such as tags, whose map literals are sorted by key wherever
+	// they appear in a block; see DefaultMapAttributes.
+	MapAttributes map[string]bool
+	// SortObjectTypes sorts the attributes of object type constraints in the types of variables,
+	// including nested objects. optional() wrappers and comments move with their attribute.
+	SortObjectTypes bool
+	// SortVariableDefaults sorts the keys of the map and object literals in the defaults of variables,
+	// including nested ones.
+	SortVariableDefaults bool
+	// LocalsDepth sorts the keys of the object literals assigned to locals, up to this many levels of
+	// nesting. Zero sorts only the assignments themselves.
+	LocalsDepth int
+	// LocalsOrder is the order of the assignments in locals blocks, LocalsOrderAlphabetical or
+	// LocalsOrderTopological. Empty sorts them alphabetically.
+	LocalsOrder string
+	// MergeTerraformBlocks consolidates the terraform blocks of a file into the first one, combining
+	// their required_providers blocks. Conflicting blocks are left apart with a warning.
+	MergeTerraformBlocks bool
+	// ResourceGrouping groups sorted resource blocks by ResourceGroupingProvider or
+	// ResourceGroupingType, and sorts them within their group. The resources of a group are kept on
+	// adjacent lines, with blank lines between groups. Empty leaves resources ungrouped.
+	ResourceGrouping string
+	// BlockOrder lists top-level block types in the order they are placed in files, such as terraform,
+	// provider, variable. Blocks are moved next to the other blocks of their type, and block types
+	// missing from the list follow in the order they first appear. Variables and outputs are then
+	// sorted separately. Empty keeps the block types interleaved as they appear.
+	BlockOrder []string
+	// MinimalMoves relocates only the blocks outside the longest run of blocks that are already in
+	// sorted order, keeping the original blank lines above the others, so diffs show only the
+	// blocks that move.
+	MinimalMoves bool
+	// PreserveFormatting keeps the original spacing of the tokens of sorted files instead of formatting
+	// them with hclwrite, so only the reordering shows in diffs. Tokens that are created or moved next
+	// to other tokens while sorting, such as reordered list elements, are still spaced as formatted.
+	PreserveFormatting bool
+	// NormalizeVersions normalizes the literal version constraints of required_version, required_providers
+	// and module calls, such as ">=1.5.0,<2.0.0" to ">= 1.5.0, < 2.0.0", ordering their conditions by
+	// operator.
+	NormalizeVersions bool
+	// ProviderSource rewrites the literal source addresses of required providers in lower case and in
+	// the given form, ProviderSourceShort or ProviderSourceQualified, unless it is empty.
+	ProviderSource string
+	// Dialect is the dialect of the sorted files, DialectTerraform when empty. With DialectNomad, the
+	// arguments of job blocks and the blocks nested in them are sorted, while groups, tasks and other
+	// nested blocks keep their order. With DialectVault, the path blocks of policies are sorted by path
+	// and their capabilities alphabetically. With DialectVaultServer and DialectConsul, the top-level
+	// attributes of agent configurations are sorted by name, and so are blocks such as the listener
+	// and storage blocks of Vault servers. Files with a well-known name, such as waypoint.hcl, are
+	// always sorted in their own dialect. With DialectGeneric, only the block types of BlockSchemas
+	// are sorted.
+	Dialect string
+	// OverrideFiles controls how override files, such as override.tf and main_override.tf, are sorted:
+	// OverrideFilesContents or an empty string keeps their blocks in place and sorts their contents,
+	// OverrideFilesSkip leaves them untouched, and OverrideFilesSort sorts them like other files.
+	OverrideFiles string
+	// BlockSchemas declares how the blocks of individual types are sorted with DialectGeneric.
+	BlockSchemas map[string]BlockSchema
+	// BlockSorters sorts the contents of the top-level blocks of individual types, replacing the
+	// sorting tfsort applies to them.
+	BlockSorters map[string]BlockSortFunc
+	// Plugins sorts the contents of blocks of some types, or whole files of some types, with external
+	// programs; see Plugin.
+	Plugins []Plugin
+	// SortTfvarsValues sorts the keys of the map and object literals assigned in variable definitions
+	// files, such as terraform.tfvars, at every nesting level. Their assignments are always sorted.
+	SortTfvarsValues bool
+	// BlankLines is the number of blank lines between top-level blocks, one when zero. Blocks kept
+	// together, such as by GroupByBlankLines or ResourceGrouping, stay on adjacent lines.
+	BlankLines int
+	// TypeBlankLines is the number of blank lines between top-level blocks of different types, such as
+	// the last variable and the first output, BlankLines when zero.
+	TypeBlankLines int
+	// Alignment controls the spaces before the equals signs of attributes and object elements in sorted
+	// files: AlignmentAlign or an empty string aligns them, AlignmentNever puts a single space before
+	// each, and AlignmentPreserve keeps them as written. It has no effect with PreserveFormatting.
+	Alignment string
+	// ListPaths lists attribute paths, such as security_groups or aws_iam_role.managed_policy_arns,
+	// whose list literals are sorted when all their elements are literal strings. Segments before
+	// the attribute name match the type or first label of the enclosing blocks.
+	ListPaths []string
+	// Targets applies sort behaviors to the attributes and nested blocks at paths within top-level
+	// blocks; see Target.
+	Targets []Target
+	// Warnings receives the warnings printed by Parse and Sort about the files they read, such as
+	// duplicate blocks; nil prints them to stderr.
+	Warnings io.Writer
 }
 
 // SortableBlock holds information needed for sorting.
 type SortableBlock struct {
-	Name  string
+	Name string
+	// Key holds the values the block is compared by, most significant first. Name is its first element.
+	Key   []string
 	Block *hclwrite.Block
+
+	// exprKey is the result of a sort key expression, which replaces Key when set.
+	exprKey []cty.Value
+}
+
+// topLevelItem is a block, attribute, comment or verbatim region that is moved as a unit when
+// rebuilding a body.
+type topLevelItem struct {
+	block *hclwrite.Block
+	// verbatim holds the original text of a tfsort:off/tfsort:on region; block is nil for regions.
+	verbatim []byte
+	// tokens holds the tokens of a top-level attribute, or of the comments before the first or after
+	// the last item of a body; block is nil for them.
+	tokens hclwrite.Tokens
+	// lead holds the comments above the item that are not part of it, such as comments separated
+	// from it by a blank line, so they move with the item.
+	lead hclwrite.Tokens
+	// skipTokens is the number of leading block tokens that belong to a preceding region.
+	skipTokens int
+	// start and end are the indices of the item's first token and the token after its last one
+	// in the original body.
+	start int
+	end   int
+	// pinned items keep their position and contents.
+	pinned bool
+	// blankBefore is set when the item was separated from the previous item by a blank line.
+	blankBefore bool
+	// joinNext items are followed by the next item without a blank line in between.
+	joinNext bool
+	// groupHeader items hold the comments heading a group of items sorted on their own, which keep the
+	// blank line below them.
+	groupHeader bool
+	// separator holds the newlines between the previous item and the item's lead in the original body.
+	// It is nil for the first item.
+	separator hclwrite.Tokens
+	// keepSeparator items are preceded by their original separator instead of a blank line.
+	keepSeparator bool
+}
+
+// appendTo appends the item's tokens to body.
+func (item *topLevelItem) appendTo(body *hclwrite.Body) {
+	if len(item.lead) > 0 {
+		body.AppendUnstructuredTokens(item.lead)
+	}
+
+	switch {
+	case item.verbatim != nil:
+		// A single opaque token keeps hclwrite from reformatting the region's lines.
+		body.AppendUnstructuredTokens(hclwrite.Tokens{{Type: hclsyntax.TokenComment, Bytes: item.verbatim}})
+	case item.tokens != nil:
+		body.AppendUnstructuredTokens(item.tokens)
+	case item.skipTokens > 0:
+		body.AppendUnstructuredTokens(item.block.BuildTokens(nil)[item.skipTokens:])
+	default:
+		body.AppendBlock(item.block)
+	}
+}
+
+// pinnedItem is an item that keeps its original position among the top-level items.
+type pinnedItem struct {
+	index int
+	item  *topLevelItem
+}
+
+// Result holds the original and sorted content of a single processed input.
+type Result struct {
+	Path     string
+	Original []byte
+	Sorted   []byte
+	// Skipped is set when the input opted out of sorting, is generated or is a dependency lock file; Sorted
+	// then equals Original.
+	Skipped bool
+	// Warnings describes duplicate blocks and blocks that could not be processed as requested, such as
+	// conflicting terraform blocks that were not merged. They are also printed to stderr.
+	Warnings []string
+}
+
+// Changed reports whether sorting altered the original content.
+func (r *Result) Changed() bool {
+	return !bytes.Equal(r.Original, r.Sorted)
 }
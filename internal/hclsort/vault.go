@@ -0,0 +1,10 @@
+package hclsort
+
+import "github.com/hashicorp/hcl/v2/hclwrite"
+
+// sortPolicyBlock sorts the capabilities granted by a path block of a Vault policy using compare.
+// Vault applies the most specific matching path regardless of the order of the blocks, and the order
+// of capabilities has no meaning either.
+func sortPolicyBlock(block *hclwrite.Block, compare Comparator) {
+	sortListAttribute(block.Body(), "capabilities", compare, true)
+}
@@ -0,0 +1,115 @@
+package hclsort
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// versionOperators lists the operators of version constraints, longer ones first so that they are
+// matched before their prefixes.
+//
+//nolint:gochecknoglobals // Read-only table
+var versionOperators = []string{"~>", ">=", "<=", "!=", ">", "<", "="}
+
+// normalizeVersions normalizes the literal version constraints of a block: the required_version and
+// the versions of the required_providers of terraform blocks, and the version of module calls.
+// See normalizeConstraint.
+func normalizeVersions(block *hclwrite.Block) {
+	switch block.Type() {
+	case "terraform":
+		normalizeVersionAttribute(block.Body(), "required_version")
+		for _, nested := range block.Body().Blocks() {
+			if nested.Type() != "required_providers" {
+				continue
+			}
+			for _, attr := range nested.Body().Attributes() {
+				normalizeProviderVersion(attr.Expr().BuildTokens(nil))
+			}
+		}
+	case "module":
+		normalizeVersionAttribute(block.Body(), "version")
+	}
+}
+
+// normalizeVersionAttribute normalizes the version constraint assigned to the named attribute of body
+// when it is a literal string.
+func normalizeVersionAttribute(body *hclwrite.Body, name string) {
+	if attr := body.GetAttribute(name); attr != nil {
+		normalizeConstraintTokens(trimNewlines(attr.Expr().BuildTokens(nil)))
+	}
+}
+
+// normalizeProviderVersion normalizes the version of a required provider, given the tokens of its
+// requirement: an object such as { source = "hashicorp/aws", version = "~> 5.0" }, or a version
+// string in the legacy form.
+func normalizeProviderVersion(tokens hclwrite.Tokens) {
+	if !normalizeConstraintTokens(trimNewlines(tokens)) {
+		normalizeConstraintTokens(requirementArgument(tokens, "version"))
+	}
+}
+
+// normalizeConstraintTokens normalizes the version constraint in tokens in place when they form a
+// literal string, and reports whether they do.
+func normalizeConstraintTokens(tokens hclwrite.Tokens) bool {
+	if len(tokens) != 3 || !isStringLiteral(tokens) {
+		return false
+	}
+	if normalized, ok := normalizeConstraint(string(tokens[1].Bytes)); ok {
+		tokens[1].Bytes = []byte(normalized)
+	}
+	return true
+}
+
+// normalizeConstraint returns the version constraint with each of its conditions written as the
+// operator and the version separated by a space, such as ">= 1.5.0, < 2.0.0" for ">=1.5.0,<2.0.0".
+// Conditions are ordered by operator: exact versions first, then pessimistic constraints, lower
+// bounds, upper bounds and exclusions, keeping their order otherwise. It fails for constraints that
+// cannot be parsed.
+func normalizeConstraint(constraint string) (string, bool) {
+	type condition struct {
+		operator, version string
+	}
+	var conditions []condition
+	for part := range strings.SplitSeq(constraint, ",") {
+		part = strings.TrimSpace(part)
+		operator := ""
+		for _, candidate := range versionOperators {
+			if strings.HasPrefix(part, candidate) {
+				operator = candidate
+				break
+			}
+		}
+		version := strings.TrimSpace(strings.TrimPrefix(part, operator))
+		if version == "" || strings.ContainsAny(version, " \t<>=!~") {
+			return "", false
+		}
+		conditions = append(conditions, condition{operator: operator, version: version})
+	}
+	slices.SortStableFunc(conditions, func(a, b condition) int {
+		return operatorRank(a.operator) - operatorRank(b.operator)
+	})
+
+	parts := make([]string, len(conditions))
+	for i, c := range conditions {
+		parts[i] = strings.TrimSpace(c.operator + " " + c.version)
+	}
+	return strings.Join(parts, ", "), true
+}
+
+// operatorRank returns the position of conditions using the operator in normalized constraints.
+func operatorRank(operator string) int {
+	switch operator {
+	case "", "=":
+		return 0
+	case "~>":
+		return 1
+	case ">", ">=":
+		return 2
+	case "<", "<=":
+		return 3
+	default:
+		return 4
+	}
+}
@@ -0,0 +1,179 @@
+package hclsort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// WasmABIVersion is the version of the sorting rule ABI implemented by WebAssembly modules; see
+// NewWasmComparator.
+const WasmABIVersion = 1
+
+// wasmMemoryLimitPages bounds the memory of sorting rule modules to 16 MiB.
+const wasmMemoryLimitPages = 256
+
+// wasmMaxNameBytes bounds the combined length of the names compared by sorting rule modules.
+const wasmMaxNameBytes = 1 << 20
+
+// wasmCallTimeout bounds how long a function of a sorting rule module may run, so modules that loop
+// forever cannot hang tfsort.
+const wasmCallTimeout = time.Second
+
+// WasmComparator compares names with a sorting rule implemented by a WebAssembly module, distributed
+// as a portable plugin; see NewWasmComparator. Its functions are called one at a time.
+type WasmComparator struct {
+	path    string
+	ctx     context.Context
+	mu      sync.Mutex
+	runtime wazero.Runtime
+	module  api.Module
+	// failed is set once a call failed or the comparator was closed, after which the module is no
+	// longer called.
+	failed   bool
+	alloc    api.Function
+	compare  api.Function
+	buffer   uint32
+	capacity uint32
+}
+
+// NewWasmComparator loads the WebAssembly module at path, a sorting rule distributed as a portable
+// plugin, into a runtime of its own. Modules run sandboxed: they cannot import any function, so they
+// have no access to files, the network or the clock, their memory is limited to 16 MiB, and each call
+// is stopped after a second or once ctx is done. The comparator must be closed once it is no longer
+// used to release the runtime.
+//
+// Modules export their memory as "memory" and the following functions:
+//
+//	tfsort_abi_version() i32 returns WasmABIVersion.
+//	tfsort_alloc(size i32) i32 returns the offset of a buffer of at least size bytes in memory, which
+//	  may be reused for later comparisons; it is called again when a larger buffer is needed.
+//	tfsort_compare(a, a_len, b, b_len i32) i32 compares the names copied into the buffer at offsets a
+//	  and b like a Comparator, returning a negative number when a sorts before b, a positive number
+//	  when it sorts after b and zero when they are equal.
+//
+// Comparisons are checked once when the module is loaded. Once a call fails, such as when it times out,
+// a warning is printed and names are compared by their bytes.
+func NewWasmComparator(ctx context.Context, path string) (*WasmComparator, error) {
+	binary, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading sorting rule '%s': %w", path, err)
+	}
+	// Closing modules once the context of a call is done stops them even in the middle of a loop.
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(wasmMemoryLimitPages).
+		WithCloseOnContextDone(true)
+	c := &WasmComparator{path: path, ctx: ctx, runtime: wazero.NewRuntimeWithConfig(ctx, config)}
+	if err = c.load(binary); err != nil {
+		// Closing the runtime closes the module too, whether it was instantiated or not.
+		_ = c.runtime.Close(context.Background())
+		return nil, fmt.Errorf("invalid sorting rule '%s': %w", path, err)
+	}
+	return c, nil
+}
+
+// load instantiates the sorting rule module binary and checks that it implements the ABI.
+func (c *WasmComparator) load(binary []byte) error {
+	module, err := c.runtime.InstantiateWithConfig(c.ctx, binary, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		return fmt.Errorf("error loading module: %w", err)
+	}
+	c.module = module
+	c.alloc = module.ExportedFunction("tfsort_alloc")
+	c.compare = module.ExportedFunction("tfsort_compare")
+	version := module.ExportedFunction("tfsort_abi_version")
+	if module.ExportedMemory("memory") == nil || version == nil || c.alloc == nil || c.compare == nil {
+		return errors.New("the module must export memory, tfsort_abi_version, tfsort_alloc and tfsort_compare")
+	}
+
+	callCtx, cancel := context.WithTimeout(c.ctx, wasmCallTimeout)
+	results, err := version.Call(callCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("tfsort_abi_version failed: %w", err)
+	}
+	if got := api.DecodeI32(results[0]); got != WasmABIVersion {
+		return fmt.Errorf("unsupported ABI version %d, expected %d", got, WasmABIVersion)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.call("a", "b")
+	return err
+}
+
+// Close releases the runtime of the module. Names are compared by their bytes afterwards.
+func (c *WasmComparator) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failed = true
+	if err := c.runtime.Close(context.Background()); err != nil {
+		return fmt.Errorf("error closing sorting rule '%s': %w", c.path, err)
+	}
+	return nil
+}
+
+// Compare is the Comparator of the module, comparing names by their bytes once a call failed.
+func (c *WasmComparator) Compare(a, b string) int {
+	if len(a)+len(b) > wasmMaxNameBytes {
+		return strings.Compare(a, b)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failed {
+		return strings.Compare(a, b)
+	}
+	result, err := c.call(a, b)
+	if err != nil {
+		// Modules are closed when a call is stopped, so they cannot be called again.
+		c.failed = true
+		if c.ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Warning: sorting rule '%s' failed, comparing names by bytes: %v\n", c.path, err)
+		}
+		return strings.Compare(a, b)
+	}
+	return result
+}
+
+// call compares a and b with the module, each function call stopping after wasmCallTimeout or once the
+// context of the comparator is done. The caller holds c.mu.
+func (c *WasmComparator) call(a, b string) (int, error) {
+	size := len(a) + len(b)
+	if size > wasmMaxNameBytes {
+		return 0, fmt.Errorf("names longer than %d bytes cannot be compared", wasmMaxNameBytes)
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, wasmCallTimeout)
+	defer cancel()
+	if uint32(size) > c.capacity {
+		results, err := c.alloc.Call(ctx, api.EncodeU32(uint32(size)))
+		if err != nil {
+			return 0, fmt.Errorf("tfsort_alloc failed: %w", err)
+		}
+		c.buffer, c.capacity = api.DecodeU32(results[0]), uint32(size)
+	}
+
+	memory := c.module.ExportedMemory("memory")
+	second := c.buffer + uint32(len(a))
+	if !memory.WriteString(c.buffer, a) || !memory.WriteString(second, b) {
+		return 0, errors.New("tfsort_alloc returned a buffer outside of the memory")
+	}
+	results, err := c.compare.Call(
+		ctx,
+		api.EncodeU32(c.buffer),
+		api.EncodeU32(uint32(len(a))),
+		api.EncodeU32(second),
+		api.EncodeU32(uint32(len(b))),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("tfsort_compare failed: %w", err)
+	}
+	return int(api.DecodeI32(results[0])), nil
+}
@@ -0,0 +1,191 @@
+package ignore
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const (
+	// TfsortIgnoreFile is the name of the tfsort-specific ignore file.
+	TfsortIgnoreFile = ".tfsortignore"
+	// GitIgnoreFile is the name of git's ignore file.
+	GitIgnoreFile = ".gitignore"
+)
+
+// rule is a single gitignore-style pattern read from an ignore file.
+type rule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// dirRules holds the rules defined by the ignore files of a single directory.
+type dirRules struct {
+	rules      []rule
+	isRepoRoot bool
+}
+
+// Matcher reports whether paths are excluded by gitignore-style ignore files.
+// Every directory from the repository root (or filesystem root) down to a path
+// may contribute rules, with rules from the nearest directory taking precedence.
+type Matcher struct {
+	fileNames []string
+	cache     map[string]*dirRules
+}
+
+// NewMatcher returns a Matcher that reads the ignore files with the given names.
+// When a directory has several of them, rules are applied in the order of fileNames.
+func NewMatcher(fileNames ...string) *Matcher {
+	return &Matcher{
+		fileNames: fileNames,
+		cache:     map[string]*dirRules{},
+	}
+}
+
+// Ignored reports whether path is excluded by the ignore files of its ancestor directories.
+func (m *Matcher) Ignored(path string, isDir bool) (bool, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false, fmt.Errorf("error resolving path '%s': %w", path, err)
+	}
+
+	dirs, err := m.ancestors(filepath.Dir(absPath))
+	if err != nil {
+		return false, err
+	}
+
+	ignored := false
+	for _, dir := range dirs {
+		rel, relErr := filepath.Rel(dir, absPath)
+		if relErr != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		for _, r := range m.cache[dir].rules {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if matched, _ := doublestar.Match(r.pattern, rel); matched {
+				ignored = !r.negate
+			}
+		}
+	}
+
+	return ignored, nil
+}
+
+// AddPatterns registers gitignore-style patterns relative to dir.
+// They are applied after the rules from the ignore files in dir.
+func (m *Matcher) AddPatterns(dir string, patterns []string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("error resolving directory '%s': %w", dir, err)
+	}
+
+	rules, err := m.load(absDir)
+	if err != nil {
+		return err
+	}
+	rules.rules = append(rules.rules, parseRules([]byte(strings.Join(patterns, "\n")))...)
+
+	return nil
+}
+
+// ancestors returns dir and its parents up to the repository root, outermost first.
+func (m *Matcher) ancestors(dir string) ([]string, error) {
+	var dirs []string
+	for {
+		rules, err := m.load(dir)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, dir)
+
+		parent := filepath.Dir(dir)
+		if rules.isRepoRoot || parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	for i, j := 0, len(dirs)-1; i < j; i, j = i+1, j-1 {
+		dirs[i], dirs[j] = dirs[j], dirs[i]
+	}
+	return dirs, nil
+}
+
+// load reads and caches the ignore rules of a single directory.
+func (m *Matcher) load(dir string) (*dirRules, error) {
+	if cached, ok := m.cache[dir]; ok {
+		return cached, nil
+	}
+
+	result := &dirRules{}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		result.isRepoRoot = true
+	}
+
+	for _, name := range m.fileNames {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading ignore file '%s': %w", filepath.Join(dir, name), err)
+		}
+		result.rules = append(result.rules, parseRules(content)...)
+	}
+
+	m.cache[dir] = result
+	return result, nil
+}
+
+// parseRules parses the content of a gitignore-style ignore file.
+func parseRules(content []byte) []rule {
+	var rules []rule
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		r := rule{}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+		// A leading backslash escapes a literal "#" or "!".
+		line = strings.TrimPrefix(line, `\`)
+
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// Patterns without an inner slash match at any depth below the ignore file.
+		if strings.HasPrefix(line, "/") {
+			line = line[1:]
+		} else if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+
+		r.pattern = line
+		rules = append(rules, r)
+	}
+
+	return rules
+}
@@ -0,0 +1,127 @@
+package ignore_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlexNabokikh/tfsort/internal/ignore"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestMatcherIgnored(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git directory: %v", err)
+	}
+
+	writeFile(t, filepath.Join(root, ignore.TfsortIgnoreFile), `
+# comments and blank lines are skipped
+examples/
+generated
+/top_only.tf
+*.gen.tf
+!keep.gen.tf
+`)
+	writeFile(t, filepath.Join(root, "modules", "legacy", ignore.TfsortIgnoreFile), `
+old.tf
+!generated
+`)
+
+	matcher := ignore.NewMatcher(ignore.TfsortIgnoreFile)
+
+	tests := []struct {
+		name  string
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"directory-only pattern matches directory", "examples", true, true},
+		{"directory-only pattern does not match file", "examples", false, false},
+		{"directory-only pattern matches nested directory", "modules/examples", true, true},
+		{"unanchored pattern matches at any depth", "modules/network/generated", true, true},
+		{"anchored pattern matches at the root", "top_only.tf", false, true},
+		{"anchored pattern does not match nested file", "modules/top_only.tf", false, false},
+		{"wildcard pattern matches", "modules/main.gen.tf", false, true},
+		{"negated pattern re-includes file", "modules/keep.gen.tf", false, false},
+		{"nearest ignore file adds rules", "modules/legacy/old.tf", false, true},
+		{"nearest ignore file rules do not leak to siblings", "modules/network/old.tf", false, false},
+		{"nearest ignore file overrides parent rules", "modules/legacy/generated", true, false},
+		{"unmatched path is not ignored", "main.tf", false, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := matcher.Ignored(filepath.Join(root, filepath.FromSlash(tc.path)), tc.isDir)
+			if err != nil {
+				t.Fatalf("Ignored returned an unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Ignored(%q, %v) = %v, want %v", tc.path, tc.isDir, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatcherStopsAtRepositoryRoot(t *testing.T) {
+	outer := t.TempDir()
+	writeFile(t, filepath.Join(outer, ignore.TfsortIgnoreFile), "*.tf\n")
+
+	repo := filepath.Join(outer, "repo")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git directory: %v", err)
+	}
+
+	matcher := ignore.NewMatcher(ignore.TfsortIgnoreFile)
+	got, err := matcher.Ignored(filepath.Join(repo, "main.tf"), false)
+	if err != nil {
+		t.Fatalf("Ignored returned an unexpected error: %v", err)
+	}
+	if got {
+		t.Error("Expected ignore files outside the repository root to be ignored")
+	}
+}
+
+func TestMatcherCombinesIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git directory: %v", err)
+	}
+	writeFile(t, filepath.Join(root, ignore.GitIgnoreFile), "vendor/\nbuild/\n")
+	writeFile(t, filepath.Join(root, ignore.TfsortIgnoreFile), "!build/\n")
+
+	withGit := ignore.NewMatcher(ignore.GitIgnoreFile, ignore.TfsortIgnoreFile)
+	withoutGit := ignore.NewMatcher(ignore.TfsortIgnoreFile)
+
+	tests := []struct {
+		name    string
+		matcher *ignore.Matcher
+		path    string
+		want    bool
+	}{
+		{".gitignore rules apply", withGit, "vendor", true},
+		{".tfsortignore rules take precedence over .gitignore", withGit, "build", false},
+		{".gitignore rules are skipped when not requested", withoutGit, "vendor", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.matcher.Ignored(filepath.Join(root, tc.path), true)
+			if err != nil {
+				t.Fatalf("Ignored returned an unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Ignored(%q) = %v, want %v", tc.path, got, tc.want)
+			}
+		})
+	}
+}
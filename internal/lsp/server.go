@@ -0,0 +1,354 @@
+// Package lsp implements a Language Server Protocol server formatting documents with tfsort, so
+// editors can sort files on save through a single long-running process.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Error codes of JSON-RPC and the Language Server Protocol.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeRequestFailed  = -32803
+)
+
+// textDocumentSyncFull makes clients send the full content of documents on every change.
+const textDocumentSyncFull = 1
+
+// SortFunc returns src, the content of the file at path, sorted.
+type SortFunc func(path string, src []byte) ([]byte, error)
+
+// Server is a Language Server Protocol server providing the textDocument/formatting and
+// textDocument/rangeFormatting requests. Open documents are kept in memory, so unsaved changes are
+// formatted.
+type Server struct {
+	sort    SortFunc
+	version string
+	// documents holds the content of the open documents by URI.
+	documents map[string]string
+	shutdown  bool
+}
+
+// NewServer returns a Server formatting documents with sort and reporting version to clients.
+func NewServer(sort SortFunc, version string) *Server {
+	return &Server{sort: sort, version: version, documents: map[string]string{}}
+}
+
+// message is a JSON-RPC request, notification or response.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+// responseError is the error of a JSON-RPC response.
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements error.
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+// position is a position in a document: a line and a character offset in UTF-16 code units, both
+// counted from 0.
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// textRange is a range of a document, including start and excluding end.
+type textRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+// textEdit replaces a range of a document with new text.
+type textEdit struct {
+	Range   textRange `json:"range"`
+	NewText string    `json:"newText"`
+}
+
+// textDocumentItem is a document opened by the client.
+type textDocumentItem struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+// textDocumentIdentifier identifies a document.
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+// Serve reads messages from r and writes responses to w until the client sends the exit notification
+// or ctx is done. It fails when the client exits without requesting a shutdown first, or r cannot be
+// read.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	// Messages are read in the background, so the server stops once ctx is done even while waiting for
+	// the client.
+	bodies := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(r)
+		for {
+			body, err := readMessage(reader)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case bodies <- body:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		var body []byte
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			if errors.Is(err, io.EOF) {
+				return errors.New("the client disconnected without exiting")
+			}
+			return err
+		case body = <-bodies:
+		}
+
+		var request message
+		if err := json.Unmarshal(body, &request); err != nil {
+			// Responses to messages that cannot be decoded carry a null ID, as required by JSON-RPC.
+			null := json.RawMessage("null")
+			parseErr := &responseError{Code: codeParseError, Message: err.Error()}
+			if err = writeMessage(w, message{ID: &null, Error: parseErr}); err != nil {
+				return err
+			}
+			continue
+		}
+		if request.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("the client exited without requesting a shutdown")
+			}
+			return nil
+		}
+
+		result, err := s.handle(request.Method, request.Params)
+		if request.ID == nil {
+			// Notifications are never answered, not even with errors.
+			continue
+		}
+		response := message{ID: request.ID, Result: result}
+		if err != nil {
+			var rpcErr *responseError
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &responseError{Code: codeRequestFailed, Message: err.Error()}
+			}
+			response = message{ID: request.ID, Error: rpcErr}
+		} else if result == nil {
+			response.Result = json.RawMessage("null")
+		}
+		if err = writeMessage(w, response); err != nil {
+			return err
+		}
+	}
+}
+
+// handle returns the result of the request or notification method with params.
+func (s *Server) handle(method string, params json.RawMessage) (any, error) {
+	if s.shutdown && method != "shutdown" {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "the server is shut down"}
+	}
+
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":                textDocumentSyncFull,
+				"documentFormattingProvider":      true,
+				"documentRangeFormattingProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "tfsort", "version": s.version},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument textDocumentItem `json:"textDocument"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		s.documents[p.TextDocument.URI] = p.TextDocument.Text
+		return nil, nil
+	case "textDocument/didChange":
+		var p struct {
+			TextDocument   textDocumentIdentifier `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		// Changes hold the full content of the document, as requested by textDocumentSyncFull.
+		if len(p.ContentChanges) > 0 {
+			s.documents[p.TextDocument.URI] = p.ContentChanges[len(p.ContentChanges)-1].Text
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var p struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		delete(s.documents, p.TextDocument.URI)
+		return nil, nil
+	case "textDocument/formatting":
+		var p struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.format(p.TextDocument.URI, nil)
+	case "textDocument/rangeFormatting":
+		var p struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+			Range        textRange              `json:"range"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.format(p.TextDocument.URI, &p.Range)
+	case "initialized", "$/cancelRequest", "$/setTrace", "textDocument/didSave":
+		return nil, nil
+	default:
+		message := fmt.Sprintf("method '%s' is not supported", method)
+		return nil, &responseError{Code: codeMethodNotFound, Message: message}
+	}
+}
+
+// decodeParams decodes the params of a request into target.
+func decodeParams(params json.RawMessage, target any) error {
+	if err := json.Unmarshal(params, target); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// format returns the edits sorting the open document at uri, or the whole lines spanned by lines when
+// it is set. The lines of a range are sorted on their own, so they must hold complete blocks.
+func (s *Server) format(uri string, lines *textRange) ([]textEdit, error) {
+	text, ok := s.documents[uri]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("document '%s' is not open", uri)}
+	}
+	path, err := uriPath(uri)
+	if err != nil {
+		return nil, &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+
+	documentLines := strings.SplitAfter(text, "\n")
+	replaced := textRange{End: endOf(documentLines)}
+	if lines != nil {
+		end := lines.End.Line
+		if lines.End.Character == 0 && end > lines.Start.Line {
+			end--
+		}
+		end = min(end, len(documentLines)-1)
+		replaced = textRange{Start: position{Line: lines.Start.Line}, End: position{Line: end + 1}}
+		if end+1 >= len(documentLines) {
+			replaced.End = endOf(documentLines)
+		}
+		if lines.Start.Line < 0 || lines.Start.Line > end {
+			return nil, &responseError{Code: codeInvalidParams, Message: "the range is outside of the document"}
+		}
+		text = strings.Join(documentLines[lines.Start.Line:end+1], "")
+	}
+
+	sorted, err := s.sort(path, []byte(text))
+	if err != nil {
+		return nil, err
+	}
+	if string(sorted) == text {
+		return []textEdit{}, nil
+	}
+	return []textEdit{{Range: replaced, NewText: string(sorted)}}, nil
+}
+
+// endOf returns the position at the end of a document made of lines, as split by SplitAfter.
+func endOf(lines []string) position {
+	last := len(lines) - 1
+	return position{Line: last, Character: len(utf16.Encode([]rune(lines[last])))}
+}
+
+// uriPath returns the path of the file named by a file URI.
+func uriPath(uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return "", fmt.Errorf("'%s' is not a file URI", uri)
+	}
+	path := parsed.Path
+	// Windows paths are written like "/C:/Users".
+	if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// readMessage reads the body of the next message from r, framed by a Content-Length header.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("error reading message header: %w", err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header '%s'", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("error reading message: %w", err)
+	}
+	return body, nil
+}
+
+// writeMessage writes msg to w, framed by a Content-Length header.
+func writeMessage(w io.Writer, msg message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("error encoding message: %w", err)
+	}
+	if _, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		return fmt.Errorf("error writing message: %w", err)
+	}
+	return nil
+}
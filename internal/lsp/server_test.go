@@ -0,0 +1,196 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/AlexNabokikh/tfsort/internal/lsp"
+)
+
+// frame returns msg encoded as a JSON-RPC message with a Content-Length header.
+func frame(t *testing.T, msg map[string]any) string {
+	t.Helper()
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Failed to encode message: %v", err)
+	}
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+type response struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code int `json:"code"`
+	} `json:"error"`
+}
+
+// readResponses decodes the messages written by the server.
+func readResponses(t *testing.T, out []byte) []response {
+	t.Helper()
+	reader := bufio.NewReader(bytes.NewReader(out))
+	var responses []response
+	for {
+		header, err := textproto.NewReader(reader).ReadMIMEHeader()
+		if errors.Is(err, io.EOF) {
+			return responses
+		}
+		if err != nil {
+			t.Fatalf("Failed to read header: %v", err)
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			t.Fatalf("Invalid Content-Length: %v", err)
+		}
+		body := make([]byte, length)
+		if _, err = io.ReadFull(reader, body); err != nil {
+			t.Fatalf("Failed to read body: %v", err)
+		}
+		var resp response
+		if err = json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("Failed to decode response %s: %v", body, err)
+		}
+		responses = append(responses, resp)
+	}
+}
+
+// reverseLines is a sort function reversing the order of the lines of a document.
+func reverseLines(path string, src []byte) ([]byte, error) {
+	if path != "/work/main.tf" {
+		return nil, fmt.Errorf("unexpected path '%s'", path)
+	}
+	if bytes.Contains(src, []byte("invalid")) {
+		return nil, errors.New("invalid content")
+	}
+	lines := strings.Fields(string(src))
+	slices.Reverse(lines)
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+func TestServe(t *testing.T) {
+	const uri = "file:///work/main.tf"
+	doc := map[string]any{"uri": uri}
+	messages := []map[string]any{
+		{"id": 1, "method": "initialize", "params": map[string]any{}},
+		{"method": "initialized", "params": map[string]any{}},
+		{"method": "textDocument/didOpen", "params": map[string]any{
+			"textDocument": map[string]any{"uri": uri, "text": "a\nb\nc\nd\n"},
+		}},
+		{"id": 2, "method": "textDocument/formatting", "params": map[string]any{"textDocument": doc}},
+		{"id": 3, "method": "textDocument/rangeFormatting", "params": map[string]any{
+			"textDocument": doc,
+			"range": map[string]any{
+				"start": map[string]int{"line": 1, "character": 0},
+				"end":   map[string]int{"line": 3, "character": 0},
+			},
+		}},
+		{"method": "textDocument/didChange", "params": map[string]any{
+			"textDocument":   doc,
+			"contentChanges": []map[string]string{{"text": "a\n"}},
+		}},
+		{"id": 4, "method": "textDocument/formatting", "params": map[string]any{"textDocument": doc}},
+		{"method": "textDocument/didChange", "params": map[string]any{
+			"textDocument":   doc,
+			"contentChanges": []map[string]string{{"text": "invalid\n"}},
+		}},
+		{"id": 5, "method": "textDocument/formatting", "params": map[string]any{"textDocument": doc}},
+		{"method": "textDocument/didClose", "params": map[string]any{"textDocument": doc}},
+		{"id": 6, "method": "textDocument/formatting", "params": map[string]any{"textDocument": doc}},
+		{"id": 7, "method": "textDocument/hover", "params": map[string]any{}},
+		{"id": 8, "method": "shutdown"},
+		{"method": "exit"},
+	}
+	var in strings.Builder
+	for _, msg := range messages {
+		in.WriteString(frame(t, msg))
+	}
+
+	var out bytes.Buffer
+	server := lsp.NewServer(reverseLines, "1.0.0")
+	if err := server.Serve(context.Background(), strings.NewReader(in.String()), &out); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+
+	responses := readResponses(t, out.Bytes())
+	if len(responses) != 8 {
+		t.Fatalf("Serve() wrote %d responses, want 8", len(responses))
+	}
+	var initResult struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	if err := json.Unmarshal(responses[0].Result, &initResult); err != nil {
+		t.Fatalf("Failed to decode initialize result: %v", err)
+	}
+	if initResult.Capabilities["documentFormattingProvider"] != true ||
+		initResult.Capabilities["documentRangeFormattingProvider"] != true {
+		t.Errorf("initialize capabilities = %v, want formatting providers", initResult.Capabilities)
+	}
+
+	wantResults := map[int]string{
+		2: `[{"range":{"start":{"line":0,"character":0},"end":{"line":4,"character":0}},"newText":"d\nc\nb\na\n"}]`,
+		3: `[{"range":{"start":{"line":1,"character":0},"end":{"line":3,"character":0}},"newText":"c\nb\n"}]`,
+		4: `[]`,
+		8: `null`,
+	}
+	wantErrors := map[int]int{5: -32803, 6: -32602, 7: -32601}
+	for _, resp := range responses[1:] {
+		if want, ok := wantErrors[resp.ID]; ok {
+			if resp.Error == nil || resp.Error.Code != want {
+				t.Errorf("response %d error = %+v, want code %d", resp.ID, resp.Error, want)
+			}
+			continue
+		}
+		if resp.Error != nil {
+			t.Errorf("response %d error code = %d, want none", resp.ID, resp.Error.Code)
+		}
+		if got := string(resp.Result); got != wantResults[resp.ID] {
+			t.Errorf("response %d result = %s, want %s", resp.ID, got, wantResults[resp.ID])
+		}
+	}
+}
+
+func TestServeExitWithoutShutdown(t *testing.T) {
+	in := frame(t, map[string]any{"method": "exit"})
+	server := lsp.NewServer(reverseLines, "1.0.0")
+	if err := server.Serve(context.Background(), strings.NewReader(in), io.Discard); err == nil {
+		t.Error("Serve() error = nil, want an error when exiting without a shutdown")
+	}
+}
+
+func TestServeContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	server := lsp.NewServer(reverseLines, "1.0.0")
+	if err := server.Serve(ctx, reader, io.Discard); !errors.Is(err, context.Canceled) {
+		t.Errorf("Serve() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestServeParseError(t *testing.T) {
+	body := "{not json"
+	in := fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+	in += frame(t, map[string]any{"id": 1, "method": "shutdown"}) + frame(t, map[string]any{"method": "exit"})
+
+	var out bytes.Buffer
+	server := lsp.NewServer(reverseLines, "1.0.0")
+	if err := server.Serve(context.Background(), strings.NewReader(in), &out); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	if !strings.Contains(out.String(), `{"jsonrpc":"2.0","id":null,"error":{"code":-32700`) {
+		t.Errorf("Serve() wrote %s, want a parse error with a null id", out.String())
+	}
+}
@@ -0,0 +1,128 @@
+// Package watch reports the files of a directory tree once they stop changing, so they can be sorted
+// after every save without sorting files that are still being written.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDelay is how long files must stay unchanged before they are reported.
+const DefaultDelay = 300 * time.Millisecond
+
+// Watcher watches the directories of a tree for files being created or written.
+type Watcher struct {
+	fsw     *fsnotify.Watcher
+	delay   time.Duration
+	skipDir func(path string) bool
+}
+
+// New returns a Watcher watching root and its subdirectories, including the directories created while
+// it runs, except the directories for which skipDir returns true. Files are reported once they stayed
+// unchanged for delay.
+func New(root string, delay time.Duration, skipDir func(path string) bool) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start watching files: %w", err)
+	}
+	w := &Watcher{fsw: fsw, delay: delay, skipDir: skipDir}
+	if err = w.addTree(root, true); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// addTree watches dir and its subdirectories. The root of the tree is watched even when skipDir
+// returns true for it.
+func (w *Watcher) addTree(dir string, root bool) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrPermission) || errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if (!root || path != dir) && w.skipDir(path) {
+			return filepath.SkipDir
+		}
+		if err = w.fsw.Add(path); err != nil {
+			return fmt.Errorf("failed to watch '%s': %w", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking directory '%s': %w", dir, err)
+	}
+	return nil
+}
+
+// Run calls handle with the path of every file created or written in the watched directories, once it
+// stayed unchanged for the delay of w, until ctx is done or watching fails. Calls to handle never
+// overlap, and files changed again while handle runs are reported again. Run closes w when it returns.
+func (w *Watcher) Run(ctx context.Context, handle func(path string)) error {
+	defer w.fsw.Close()
+	// Cancelling ctx releases the timers waiting to report their file.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	timers := map[string]*time.Timer{}
+	defer func() {
+		for _, timer := range timers {
+			timer.Stop()
+		}
+	}()
+	ready := make(chan string)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("error watching files: %w", err)
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+				if event.Has(fsnotify.Create) && !w.skipDir(event.Name) {
+					if err = w.addTree(event.Name, false); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			path := filepath.Clean(event.Name)
+			if timer := timers[path]; timer != nil {
+				timer.Reset(w.delay)
+				continue
+			}
+			timers[path] = time.AfterFunc(w.delay, func() {
+				select {
+				case ready <- path:
+				case <-ctx.Done():
+				}
+			})
+		case path := <-ready:
+			delete(timers, path)
+			handle(path)
+		}
+	}
+}
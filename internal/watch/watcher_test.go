@@ -0,0 +1,86 @@
+package watch_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlexNabokikh/tfsort/internal/watch"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// next returns the next path reported on paths, failing the test after a timeout.
+func next(t *testing.T, paths <-chan string) string {
+	t.Helper()
+	select {
+	case path := <-paths:
+		return path
+	case <-time.After(5 * time.Second):
+		t.Fatal("No file was reported")
+		return ""
+	}
+}
+
+func TestWatcherRun(t *testing.T) {
+	root := t.TempDir()
+	skipped := filepath.Join(root, "skipped")
+	if err := os.Mkdir(skipped, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	watcher, err := watch.New(root, 50*time.Millisecond, func(path string) bool {
+		return filepath.Base(path) == "skipped"
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	paths := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- watcher.Run(ctx, func(path string) { paths <- path })
+	}()
+
+	// Writes in quick succession are reported once.
+	main := filepath.Join(root, "main.tf")
+	for _, content := range []string{"a", "ab", "abc"} {
+		writeFile(t, main, content)
+	}
+	if got := next(t, paths); got != main {
+		t.Errorf("Run() reported %s, want %s", got, main)
+	}
+
+	writeFile(t, filepath.Join(skipped, "ignored.tf"), "a")
+	nested := filepath.Join(root, "new", "nested")
+	if err = os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	// Directories created while running are watched once their events are handled.
+	time.Sleep(200 * time.Millisecond)
+	nestedFile := filepath.Join(nested, "nested.tf")
+	writeFile(t, nestedFile, "a")
+	if got := next(t, paths); got != nestedFile {
+		t.Errorf("Run() reported %s, want %s", got, nestedFile)
+	}
+
+	select {
+	case path := <-paths:
+		t.Errorf("Run() reported %s, want no more files", path)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	cancel()
+	if err = <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,22 @@
+package tfsort
+
+import (
+	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+)
+
+// Severities of diagnostics.
+const (
+	SeverityError   = hclsort.SeverityError
+	SeverityWarning = hclsort.SeverityWarning
+)
+
+// ParseError is returned when the content of a file cannot be parsed; retrieve it with errors.As.
+// Its Diagnostics describe each problem found.
+type ParseError = hclsort.ParseError
+
+// Diagnostic describes a problem found in a file at a range of positions, with its severity and a
+// machine-readable code.
+type Diagnostic = hclsort.Diagnostic
+
+// Position is a location in a file, with lines and columns counted from 1.
+type Position = hclsort.Position
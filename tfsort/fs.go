@@ -0,0 +1,81 @@
+package tfsort
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+
+	"github.com/AlexNabokikh/tfsort/internal/config"
+	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+)
+
+// Result holds the original and sorted content of a file sorted by SortFS.
+type Result = hclsort.Result
+
+// Results holds the files sorted by SortFS, in lexical order of their paths.
+type Results []*Result
+
+// Changed returns the results whose sorted content differs from the original.
+func (r Results) Changed() Results {
+	var changed Results
+	for _, result := range r {
+		if result.Changed() {
+			changed = append(changed, result)
+		}
+	}
+	return changed
+}
+
+// SortFS sorts the Terraform and HCL files of fsys, such as an os.DirFS, an embed.FS or an in-memory
+// fstest.MapFS, according to opts and returns a result for each of them without writing anything.
+// Like the tfsort command, it skips version control and provider cache directories as well as tfsort
+// configuration and dependency lock files. Result paths are the slash-separated paths of the files in
+// fsys, which select how each of them is sorted; Options.Filename is ignored. It fails on the first
+// file that cannot be read or parsed.
+func SortFS(fsys fs.FS, opts Options) (Results, error) {
+	return SortFSContext(context.Background(), fsys, opts)
+}
+
+// SortFSContext is like SortFS, but stops walking fsys once ctx is done, failing with the error of ctx,
+// so long runs can be cancelled or bounded by a deadline.
+func SortFSContext(ctx context.Context, fsys fs.FS, opts Options) (Results, error) {
+	ingestor, err := opts.ingestor()
+	if err != nil {
+		return nil, err
+	}
+
+	var results Results
+	err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != "." && hclsort.IsSkippedDir(d.Name()) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == config.FileName || hclsort.IsSkippedFile(d.Name()) ||
+			!ingestor.AllowedTypes[hclsort.FileType(path)] {
+			return nil
+		}
+
+		src, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return fmt.Errorf("error reading file '%s': %w", path, err)
+		}
+		result, err := ingestor.SortSourceContext(ctx, path, src)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
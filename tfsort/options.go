@@ -0,0 +1,349 @@
+package tfsort
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+)
+
+// Orders of the assignments of locals blocks; see WithLocalsOrder.
+const (
+	LocalsOrderAlphabetical = hclsort.LocalsOrderAlphabetical
+	LocalsOrderTopological  = hclsort.LocalsOrderTopological
+)
+
+// Groupings of sorted resources; see WithResourceGrouping.
+const (
+	ResourceGroupingProvider = hclsort.ResourceGroupingProvider
+	ResourceGroupingType     = hclsort.ResourceGroupingType
+)
+
+// Forms of provider source addresses; see WithProviderSource.
+const (
+	ProviderSourceShort     = hclsort.ProviderSourceShort
+	ProviderSourceQualified = hclsort.ProviderSourceQualified
+)
+
+// Alignments of the equals signs of attributes; see WithAlignment.
+const (
+	AlignmentAlign    = hclsort.AlignmentAlign
+	AlignmentNever    = hclsort.AlignmentNever
+	AlignmentPreserve = hclsort.AlignmentPreserve
+)
+
+// Dialects of the sorted files; see WithDialect.
+const (
+	DialectTerraform   = hclsort.DialectTerraform
+	DialectNomad       = hclsort.DialectNomad
+	DialectVault       = hclsort.DialectVault
+	DialectVaultServer = hclsort.DialectVaultServer
+	DialectConsul      = hclsort.DialectConsul
+	DialectWaypoint    = hclsort.DialectWaypoint
+	DialectGeneric     = hclsort.DialectGeneric
+)
+
+// Plugin is an external program sorting the contents of block types or whole files of some types;
+// see WithPlugin.
+type Plugin = hclsort.Plugin
+
+// BlockSchema declares how the blocks of a type are sorted with DialectGeneric; see WithBlockSchema.
+type BlockSchema = hclsort.BlockSchema
+
+// Handlings of override files; see WithOverrideFiles.
+const (
+	OverrideFilesContents = hclsort.OverrideFilesContents
+	OverrideFilesSkip     = hclsort.OverrideFilesSkip
+	OverrideFilesSort     = hclsort.OverrideFilesSort
+)
+
+// Option configures how SortBytes sorts a file, like one of the flags of the tfsort command.
+type Option func(*Options)
+
+// NewOptions returns the Options configured by opts, applied in order, so later options override
+// earlier ones.
+func NewOptions(opts ...Option) Options {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// set returns an Option applying fn to the Ingestor sorting the file.
+func set(fn func(*hclsort.Ingestor)) Option {
+	return func(o *Options) {
+		o.settings = append(o.settings, func(ingestor *hclsort.Ingestor) error {
+			fn(ingestor)
+			return nil
+		})
+	}
+}
+
+// setValidated returns an Option applying fn to the Ingestor sorting the file once validate accepts
+// value. An invalid value makes SortBytes fail with an error naming the setting.
+func setValidated(setting, value string, validate func(string) error, fn func(*hclsort.Ingestor)) Option {
+	return func(o *Options) {
+		o.settings = append(o.settings, func(ingestor *hclsort.Ingestor) error {
+			if err := validate(value); err != nil {
+				return fmt.Errorf("invalid %s: %w", setting, err)
+			}
+			fn(ingestor)
+			return nil
+		})
+	}
+}
+
+// WithFilename sets the name of the file the source was read from; see Options.Filename.
+func WithFilename(name string) Option {
+	return func(o *Options) {
+		o.Filename = name
+	}
+}
+
+// WithBlockTypes sorts the top-level blocks of the given types by their labels, like --types.
+func WithBlockTypes(blockTypes ...string) Option {
+	return func(o *Options) {
+		o.BlockTypes = blockTypes
+	}
+}
+
+// WithExcludedTypes leaves the blocks of the given types untouched, including the contents of locals
+// and terraform blocks that are otherwise always sorted, like --exclude-types.
+func WithExcludedTypes(blockTypes ...string) Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.ExcludedBlocks = make(map[string]bool, len(blockTypes))
+		for _, blockType := range blockTypes {
+			ingestor.ExcludedBlocks[blockType] = true
+		}
+	})
+}
+
+// WithNaturalSort compares runs of digits by their numeric value, so "subnet_2" sorts before
+// "subnet_10", like --sort-strategy natural.
+func WithNaturalSort() Option {
+	return func(o *Options) {
+		o.strategy = hclsort.StrategyNatural
+	}
+}
+
+// WithIgnoreCase compares names regardless of their case, like --ignore-case.
+func WithIgnoreCase() Option {
+	return func(o *Options) {
+		o.ignoreCase = true
+	}
+}
+
+// WithCollation orders names with the collation rules of a locale, such as "da", like --collation.
+func WithCollation(locale string) Option {
+	return func(o *Options) {
+		o.collation = locale
+	}
+}
+
+// WithGeneratedFiles sorts generated files instead of returning them unchanged, like
+// --include-generated.
+func WithGeneratedFiles() Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.GeneratedPattern = nil
+	})
+}
+
+// WithSections sorts blocks only within the sections delimited by banner comments, like --sections.
+func WithSections() Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.SectionPattern = regexp.MustCompile(hclsort.DefaultSectionPattern)
+	})
+}
+
+// WithGroupByBlankLines sorts blocks only within groups of adjacent lines, like --group-by-blank-lines.
+func WithGroupByBlankLines() Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.GroupByBlankLines = true
+	})
+}
+
+// WithSectionHeaders inserts a header comment above the blocks of each sorted block type, like
+// --section-headers.
+func WithSectionHeaders() Option {
+	return func(o *Options) {
+		o.sectionHeaders = true
+	}
+}
+
+// WithSortNestedBlocks sorts the nested blocks of resource, data and module bodies by type and labels,
+// like --sort-nested-blocks.
+func WithSortNestedBlocks() Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.SortNestedBlocks = true
+	})
+}
+
+// WithSortDependsOn sorts the references in depends_on lists, like --sort-depends-on.
+func WithSortDependsOn() Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.SortDependsOn = true
+	})
+}
+
+// WithSortMapKeys sorts the keys of the map literals assigned to tags, labels and default_tags, like
+// --sort-map-keys.
+func WithSortMapKeys() Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.MapAttributes = make(map[string]bool, len(hclsort.DefaultMapAttributes))
+		for _, name := range hclsort.DefaultMapAttributes {
+			ingestor.MapAttributes[name] = true
+		}
+	})
+}
+
+// WithSortObjectTypes sorts the attributes of object type constraints in the types of variables, like
+// --sort-object-types.
+func WithSortObjectTypes() Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.SortObjectTypes = true
+	})
+}
+
+// WithSortVariableDefaults sorts the keys of the maps and objects in the defaults of variables, like
+// --sort-variable-defaults.
+func WithSortVariableDefaults() Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.SortVariableDefaults = true
+	})
+}
+
+// WithLocalsDepth sorts the keys of the object literals assigned to locals up to depth levels of
+// nesting, like --locals-depth.
+func WithLocalsDepth(depth int) Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.LocalsDepth = depth
+	})
+}
+
+// WithLocalsOrder orders the assignments of locals blocks, LocalsOrderAlphabetical or
+// LocalsOrderTopological, like --locals-order.
+func WithLocalsOrder(order string) Option {
+	return setValidated("locals order", order, hclsort.ValidateLocalsOrder, func(ingestor *hclsort.Ingestor) {
+		ingestor.LocalsOrder = order
+	})
+}
+
+// WithMergeTerraformBlocks consolidates the terraform blocks of a file into one, like
+// --merge-terraform-blocks.
+func WithMergeTerraformBlocks() Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.MergeTerraformBlocks = true
+	})
+}
+
+// WithResourceGrouping groups sorted resources, ResourceGroupingProvider or ResourceGroupingType, like
+// --group-resources.
+func WithResourceGrouping(grouping string) Option {
+	return setValidated(
+		"resource grouping",
+		grouping,
+		hclsort.ValidateResourceGrouping,
+		func(ingestor *hclsort.Ingestor) {
+			ingestor.ResourceGrouping = grouping
+		},
+	)
+}
+
+// WithMinimalMoves moves only the blocks that are out of order, like --minimal-moves.
+func WithMinimalMoves() Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.MinimalMoves = true
+	})
+}
+
+// WithPreserveFormatting keeps the original spacing of sorted files instead of formatting them, like
+// --preserve-formatting.
+func WithPreserveFormatting() Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.PreserveFormatting = true
+	})
+}
+
+// WithSortTfvarsValues sorts the keys of the map and object literals assigned in .tfvars files, like
+// --sort-tfvars-values.
+func WithSortTfvarsValues() Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.SortTfvarsValues = true
+	})
+}
+
+// WithNormalizeVersions normalizes the spacing and order of version constraints, like
+// --normalize-versions.
+func WithNormalizeVersions() Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.NormalizeVersions = true
+	})
+}
+
+// WithProviderSource rewrites provider source addresses in lower case and in the given form,
+// ProviderSourceShort or ProviderSourceQualified, like --provider-source.
+func WithProviderSource(form string) Option {
+	return setValidated("provider source", form, hclsort.ValidateProviderSource, func(ingestor *hclsort.Ingestor) {
+		ingestor.ProviderSource = form
+	})
+}
+
+// WithDialect sorts the file in the given dialect, such as DialectNomad, like --dialect.
+func WithDialect(dialect string) Option {
+	return setValidated("dialect", dialect, hclsort.ValidateDialect, func(ingestor *hclsort.Ingestor) {
+		ingestor.Dialect = dialect
+	})
+}
+
+// WithBlockSchema declares how the blocks of blockType are sorted with DialectGeneric, like the block
+// declaration of the configuration file.
+func WithBlockSchema(blockType string, schema BlockSchema) Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		if ingestor.BlockSchemas == nil {
+			ingestor.BlockSchemas = map[string]hclsort.BlockSchema{}
+		}
+		ingestor.BlockSchemas[blockType] = schema
+	})
+}
+
+// WithOverrideFiles controls how override files are sorted, such as OverrideFilesSkip, like
+// --override-files.
+func WithOverrideFiles(handling string) Option {
+	return setValidated("override files", handling, hclsort.ValidateOverrideFiles, func(ingestor *hclsort.Ingestor) {
+		ingestor.OverrideFiles = handling
+	})
+}
+
+// WithBlankLines separates top-level blocks by the given number of blank lines, and blocks of
+// different types by typeBlankLines, like --blank-lines and --type-blank-lines. Zero keeps the default
+// of one blank line.
+func WithBlankLines(blankLines, typeBlankLines int) Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.BlankLines = blankLines
+		ingestor.TypeBlankLines = typeBlankLines
+	})
+}
+
+// WithAlignment controls the alignment of the equals signs of attributes, AlignmentAlign,
+// AlignmentNever or AlignmentPreserve, like --align-equals.
+func WithAlignment(alignment string) Option {
+	return setValidated("alignment", alignment, hclsort.ValidateAlignment, func(ingestor *hclsort.Ingestor) {
+		ingestor.Alignment = alignment
+	})
+}
+
+// WithBlockOrder places the top-level blocks of the given types in this order, like --block-order.
+func WithBlockOrder(blockTypes ...string) Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.BlockOrder = blockTypes
+	})
+}
+
+// WithPlugin sorts the contents of the block types or the whole files claimed by plugin with the
+// external program it runs, like a plugin block of the configuration file.
+func WithPlugin(plugin Plugin) Option {
+	return set(func(ingestor *hclsort.Ingestor) {
+		ingestor.Plugins = append(ingestor.Plugins, plugin)
+	})
+}
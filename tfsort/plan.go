@@ -0,0 +1,30 @@
+package tfsort
+
+import (
+	"context"
+
+	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+)
+
+// Move describes how sorting moves a top-level block of a file; see Plan.
+type Move = hclsort.Move
+
+// SortPlan describes the changes sorting a file makes; see Plan.
+type SortPlan = hclsort.SortPlan
+
+// Plan returns the moves of the top-level blocks of src that SortBytes would make with opts, without
+// the sorted content, so tools can show what will change or reject specific moves. It fails if src
+// cannot be parsed or is a JSON configuration file.
+func Plan(src []byte, opts Options) (*SortPlan, error) {
+	return PlanContext(context.Background(), src, opts)
+}
+
+// PlanContext is like Plan, but fails with the error of ctx when it is done before src is sorted, which
+// also stops the plugins sorting it.
+func PlanContext(ctx context.Context, src []byte, opts Options) (*SortPlan, error) {
+	ingestor, err := opts.ingestor()
+	if err != nil {
+		return nil, err
+	}
+	return ingestor.PlanSourceContext(ctx, opts.filename(), src)
+}
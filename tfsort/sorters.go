@@ -0,0 +1,70 @@
+package tfsort
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Comparator compares two names. It returns a negative number when a sorts before b, a positive
+// number when a sorts after b and zero when they are equal.
+type Comparator = hclsort.Comparator
+
+// BlockSortFunc sorts the contents of a top-level block in place, comparing names with compare, the
+// comparator configured by the Options the file is sorted with.
+type BlockSortFunc = hclsort.BlockSortFunc
+
+//nolint:gochecknoglobals // Registry shared by every sorted file
+var (
+	blockSortersMu sync.RWMutex
+	blockSorters   = map[string]BlockSortFunc{}
+)
+
+// RegisterBlockSorter registers fn to sort the contents of the top-level blocks of blockType in every
+// file sorted afterwards, replacing the sorting tfsort applies to them, so block types tfsort does not
+// understand can be sorted too. Whether the blocks themselves are ordered by their labels is still
+// selected by Options.BlockTypes. Registering another function for blockType replaces the previous
+// one, and a nil fn removes it. It is safe to call concurrently with sorting.
+func RegisterBlockSorter(blockType string, fn BlockSortFunc) {
+	blockSortersMu.Lock()
+	defer blockSortersMu.Unlock()
+	if fn == nil {
+		delete(blockSorters, blockType)
+		return
+	}
+	blockSorters[blockType] = fn
+}
+
+// registeredBlockSorters returns a snapshot of the registered block sorters, or nil when there are
+// none.
+func registeredBlockSorters() map[string]BlockSortFunc {
+	blockSortersMu.RLock()
+	defer blockSortersMu.RUnlock()
+	if len(blockSorters) == 0 {
+		return nil
+	}
+	return maps.Clone(blockSorters)
+}
+
+// SortAttributes sorts the attributes of body by name using compare. Nested blocks keep their
+// positions, and comments travel with the attribute below them. The values of reordered attributes can
+// no longer be sorted afterwards, so lists and maps are sorted first.
+func SortAttributes(body *hclwrite.Body, compare Comparator) {
+	hclsort.SortAttributes(body, compare)
+}
+
+// SortListAttribute sorts the elements of the list literal assigned to the named attribute of body
+// using compare. Missing attributes, other expressions and lists containing comments are left
+// untouched.
+func SortListAttribute(body *hclwrite.Body, name string, compare Comparator) {
+	hclsort.SortListAttribute(body, name, compare)
+}
+
+// SortMapAttribute sorts the keys of the map literal assigned to the named attribute of body using
+// compare. Missing attributes, maps whose keys are not all literal names or strings and maps containing
+// comments are left untouched.
+func SortMapAttribute(body *hclwrite.Body, name string, compare Comparator) {
+	hclsort.SortMapAttribute(body, name, compare)
+}
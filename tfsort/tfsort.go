@@ -0,0 +1,146 @@
+// Package tfsort sorts the blocks of Terraform, OpenTofu and other HCL files in memory, for Go
+// programs that embed the sorter instead of running the tfsort command.
+package tfsort
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/AlexNabokikh/tfsort/internal/hclsort"
+)
+
+// Options controls how SortBytes sorts a file. The zero value sorts the variable and output blocks
+// of a Terraform file, like the tfsort command without flags; NewOptions configures the other settings
+// of the command.
+type Options struct {
+	// Filename is the name of the file the source was read from, such as "main.tf", "terraform.tfvars"
+	// or "main.tf.json". It selects how the file is sorted and appears in error messages. An empty name
+	// sorts the source as a Terraform file read from stdin.
+	Filename string
+	// BlockTypes lists the top-level block types sorted by their labels, such as "variable" and
+	// "resource". Variable and output blocks are sorted when it is nil.
+	BlockTypes []string
+
+	// settings configure the Ingestor sorting the file, in the order of the options setting them.
+	settings []func(*hclsort.Ingestor) error
+	// strategy, collation and ignoreCase select how names are compared.
+	strategy   string
+	collation  string
+	ignoreCase bool
+	// sectionHeaders inserts header comments above the blocks of the sorted block types.
+	sectionHeaders bool
+}
+
+// SortBytes returns src, the content of a file, with its blocks sorted according to opts and
+// formatted like terraform fmt. Files that opt out of sorting, such as generated files or files with a
+// "# tfsort:skip-file" directive, are returned unchanged. It fails if src cannot be parsed.
+func SortBytes(src []byte, opts Options) ([]byte, error) {
+	return SortBytesContext(context.Background(), src, opts)
+}
+
+// SortBytesContext is like SortBytes, but fails with the error of ctx when it is done before src is
+// parsed or sorted, which also stops the plugins sorting it.
+func SortBytesContext(ctx context.Context, src []byte, opts Options) ([]byte, error) {
+	ingestor, err := opts.ingestor()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := ingestor.SortSourceContext(ctx, opts.filename(), src)
+	if err != nil {
+		return nil, err
+	}
+	return result.Sorted, nil
+}
+
+// filename returns the name of the file sorted with o; an empty Filename names stdin.
+func (o Options) filename() string {
+	if o.Filename == "" {
+		return hclsort.StdInPathIdentifier
+	}
+	return o.Filename
+}
+
+// ingestor returns the Ingestor sorting files as configured by o.
+func (o Options) ingestor() (*hclsort.Ingestor, error) {
+	ingestor := hclsort.NewIngestor()
+	ingestor.BlockSorters = registeredBlockSorters()
+	if o.BlockTypes != nil {
+		ingestor.AllowedBlocks = make(map[string]bool, len(o.BlockTypes))
+		for _, blockType := range o.BlockTypes {
+			ingestor.AllowedBlocks[blockType] = true
+		}
+	}
+
+	for _, setting := range o.settings {
+		if err := setting(ingestor); err != nil {
+			return nil, err
+		}
+	}
+	if err := o.applyComparison(ingestor); err != nil {
+		return nil, err
+	}
+	if o.sectionHeaders {
+		blockTypes := slices.DeleteFunc(slices.Sorted(maps.Keys(ingestor.AllowedBlocks)), func(blockType string) bool {
+			return ingestor.ExcludedBlocks[blockType]
+		})
+		headers, err := hclsort.RenderSectionHeaders(hclsort.DefaultSectionHeader, blockTypes, nil)
+		if err != nil {
+			return nil, err
+		}
+		ingestor.SectionHeaders = headers
+	}
+	return ingestor, nil
+}
+
+// Sort reads the content of a file from r, sorts it like SortBytes and writes the result to w, so
+// content from network streams, archives or editor buffers is sorted without temporary files. Nothing
+// is written when reading or sorting fails.
+func Sort(r io.Reader, w io.Writer, opts Options) error {
+	return SortContext(context.Background(), r, w, opts)
+}
+
+// SortContext is like Sort, but fails with the error of ctx when it is done before the content is
+// sorted; nothing is written then.
+func SortContext(ctx context.Context, r io.Reader, w io.Writer, opts Options) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error reading source: %w", err)
+	}
+	sorted, err := SortBytesContext(ctx, src, opts)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(sorted); err != nil {
+		return fmt.Errorf("error writing sorted content: %w", err)
+	}
+	return nil
+}
+
+// applyComparison sets the comparator of ingestor from the comparison settings of o.
+func (o Options) applyComparison(ingestor *hclsort.Ingestor) error {
+	strategy := o.strategy
+	if strategy == "" {
+		strategy = hclsort.StrategyLexical
+	}
+	var (
+		compare hclsort.Comparator
+		err     error
+	)
+	if o.collation != "" {
+		compare, err = hclsort.CollatorFor(o.collation, strategy)
+	} else {
+		compare, err = hclsort.ComparatorFor(strategy)
+	}
+	if err != nil {
+		return err
+	}
+	if o.ignoreCase {
+		compare = hclsort.IgnoreCase(compare)
+	}
+	ingestor.Compare = compare
+	return nil
+}
@@ -0,0 +1,337 @@
+package tfsort_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/AlexNabokikh/tfsort/tfsort"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+func TestSortBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		opts tfsort.Options
+		want string
+	}{
+		{
+			name: "Default block types",
+			src:  "output \"b\" {}\nvariable \"a\" {}\nresource \"x\" \"b\" {}\nresource \"x\" \"a\" {}\n",
+			want: "resource \"x\" \"b\" {}\n\nresource \"x\" \"a\" {}\n\nvariable \"a\" {}\n\noutput \"b\" {}\n",
+		},
+		{
+			name: "Selected block types",
+			src:  "resource \"x\" \"b\" {}\nresource \"x\" \"a\" {}\n",
+			opts: tfsort.Options{BlockTypes: []string{"resource"}},
+			want: "resource \"x\" \"a\" {}\n\nresource \"x\" \"b\" {}\n",
+		},
+		{
+			name: "Variable definitions",
+			src:  "region = \"eu-west-1\"\nenvironment = \"prod\"\n",
+			opts: tfsort.Options{Filename: "terraform.tfvars"},
+			want: "environment = \"prod\"\nregion      = \"eu-west-1\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tfsort.SortBytes([]byte(tt.src), tt.opts)
+			if err != nil {
+				t.Fatalf("SortBytes failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("Invalid source", func(t *testing.T) {
+		_, err := tfsort.SortBytes([]byte("variable \"a\" {"), tfsort.Options{Filename: "main.tf"})
+		if err == nil || !strings.Contains(err.Error(), "main.tf") {
+			t.Errorf("Expected a parse error mentioning main.tf, got: %v", err)
+		}
+	})
+}
+
+func TestNewOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		opts []tfsort.Option
+		want string
+	}{
+		{
+			name: "Natural sort",
+			src:  "variable \"subnet_10\" {}\nvariable \"subnet_2\" {}\n",
+			opts: []tfsort.Option{tfsort.WithNaturalSort()},
+			want: "variable \"subnet_2\" {}\n\nvariable \"subnet_10\" {}\n",
+		},
+		{
+			name: "Ignore case",
+			src:  "variable \"b\" {}\nvariable \"A\" {}\n",
+			opts: []tfsort.Option{tfsort.WithIgnoreCase()},
+			want: "variable \"A\" {}\n\nvariable \"b\" {}\n",
+		},
+		{
+			name: "Block types and excluded types",
+			src:  "resource \"x\" \"b\" {}\nresource \"x\" \"a\" {}\nlocals {\n  b = 1\n  a = 2\n}\n",
+			opts: []tfsort.Option{tfsort.WithBlockTypes("resource"), tfsort.WithExcludedTypes("locals")},
+			want: "locals {\n  b = 1\n  a = 2\n}\n\nresource \"x\" \"a\" {}\n\nresource \"x\" \"b\" {}\n",
+		},
+		{
+			name: "Filename",
+			src:  "region = \"eu-west-1\"\nenvironment = \"prod\"\n",
+			opts: []tfsort.Option{tfsort.WithFilename("terraform.tfvars"), tfsort.WithAlignment(tfsort.AlignmentNever)},
+			want: "environment = \"prod\"\nregion = \"eu-west-1\"\n",
+		},
+		{
+			name: "Generic dialect",
+			src:  "widget \"b\" {}\nwidget \"a\" {}\n",
+			opts: []tfsort.Option{
+				tfsort.WithDialect(tfsort.DialectGeneric),
+				tfsort.WithBlockSchema("widget", tfsort.BlockSchema{KeyLabels: []int{0}}),
+			},
+			want: "widget \"a\" {}\n\nwidget \"b\" {}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tfsort.SortBytes([]byte(tt.src), tfsort.NewOptions(tt.opts...))
+			if err != nil {
+				t.Fatalf("SortBytes failed unexpectedly: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("Invalid setting", func(t *testing.T) {
+		_, err := tfsort.SortBytes([]byte("variable \"a\" {}\n"), tfsort.NewOptions(tfsort.WithAlignment("sideways")))
+		if err == nil || !strings.Contains(err.Error(), "invalid alignment") {
+			t.Errorf("Expected an invalid alignment error, got: %v", err)
+		}
+	})
+}
+
+func TestSort(t *testing.T) {
+	var out bytes.Buffer
+	src := strings.NewReader("variable \"b\" {}\nvariable \"a\" {}\n")
+	if err := tfsort.Sort(src, &out, tfsort.Options{}); err != nil {
+		t.Fatalf("Sort failed unexpectedly: %v", err)
+	}
+	if diff := cmp.Diff("variable \"a\" {}\n\nvariable \"b\" {}\n", out.String()); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+
+	t.Run("Invalid source", func(t *testing.T) {
+		var partial bytes.Buffer
+		if err := tfsort.Sort(strings.NewReader("variable \"a\" {"), &partial, tfsort.Options{}); err == nil {
+			t.Error("Expected a parse error, got nil")
+		}
+		if partial.Len() > 0 {
+			t.Errorf("Expected nothing to be written, got: %q", partial.String())
+		}
+	})
+}
+
+func TestSortFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.tf":                     {Data: []byte("variable \"b\" {}\nvariable \"a\" {}\n")},
+		"outputs.tf":                  {Data: []byte("output \"a\" {}\n")},
+		"terraform.tfvars":            {Data: []byte("region = \"eu-west-1\"\nenvironment = \"prod\"\n")},
+		"README.md":                   {Data: []byte("# Module\n")},
+		".tfsort.hcl":                 {Data: []byte("types = [\"resource\"]\n")},
+		".terraform/modules/vpc/x.tf": {Data: []byte("variable \"b\" {}\nvariable \"a\" {}\n")},
+		"modules/vpc/main.tf":         {Data: []byte("output \"b\" {}\noutput \"a\" {}\n")},
+	}
+
+	results, err := tfsort.SortFS(fsys, tfsort.Options{})
+	if err != nil {
+		t.Fatalf("SortFS failed unexpectedly: %v", err)
+	}
+	got := map[string]string{}
+	for _, result := range results {
+		got[result.Path] = string(result.Sorted)
+	}
+	want := map[string]string{
+		"main.tf":             "variable \"a\" {}\n\nvariable \"b\" {}\n",
+		"outputs.tf":          "output \"a\" {}\n",
+		"terraform.tfvars":    "environment = \"prod\"\nregion      = \"eu-west-1\"\n",
+		"modules/vpc/main.tf": "output \"a\" {}\n\noutput \"b\" {}\n",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected results (-want +got):\n%s", diff)
+	}
+	if changed := len(results.Changed()); changed != 3 {
+		t.Errorf("Expected 3 changed files, got %d", changed)
+	}
+
+	t.Run("Invalid file", func(t *testing.T) {
+		invalid := fstest.MapFS{"main.tf": {Data: []byte("variable \"a\" {")}}
+		_, sortErr := tfsort.SortFS(invalid, tfsort.Options{})
+		if sortErr == nil || !strings.Contains(sortErr.Error(), "main.tf") {
+			t.Errorf("Expected a parse error mentioning main.tf, got: %v", sortErr)
+		}
+	})
+}
+
+func TestSortFSContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fsys := fstest.MapFS{"main.tf": {Data: []byte("variable \"b\" {}\nvariable \"a\" {}\n")}}
+	if _, err := tfsort.SortFSContext(ctx, fsys, tfsort.Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestContextVariants(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	src := []byte("variable \"b\" {}\nvariable \"a\" {}\n")
+
+	if _, err := tfsort.SortBytesContext(ctx, src, tfsort.Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("SortBytesContext: expected context.Canceled, got: %v", err)
+	}
+	var out bytes.Buffer
+	if err := tfsort.SortContext(ctx, bytes.NewReader(src), &out, tfsort.Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("SortContext: expected context.Canceled, got: %v", err)
+	}
+	if out.Len() > 0 {
+		t.Errorf("SortContext: expected nothing to be written, got: %q", out.String())
+	}
+	if _, err := tfsort.PlanContext(ctx, src, tfsort.Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("PlanContext: expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestRegisterBlockSorter(t *testing.T) {
+	tfsort.RegisterBlockSorter("widget", func(block *hclwrite.Block, compare tfsort.Comparator) {
+		tfsort.SortListAttribute(block.Body(), "zones", compare)
+		tfsort.SortMapAttribute(block.Body(), "labels", compare)
+		tfsort.SortAttributes(block.Body(), compare)
+	})
+	t.Cleanup(func() { tfsort.RegisterBlockSorter("widget", nil) })
+
+	src := "widget \"b\" {\n  zones = [\"b\", \"a\"]\n  labels = { z = 1, a = 2 }\n}\n\n" +
+		"widget \"a\" {\n  size = 1\n}\n"
+	got, err := tfsort.SortBytes([]byte(src), tfsort.NewOptions(tfsort.WithBlockTypes("widget")))
+	if err != nil {
+		t.Fatalf("SortBytes failed unexpectedly: %v", err)
+	}
+	want := "widget \"a\" {\n  size = 1\n}\n\n" +
+		"widget \"b\" {\n  labels = { a = 2, z = 1 }\n  zones  = [\"a\", \"b\"]\n}\n"
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+
+	t.Run("Removed sorter", func(t *testing.T) {
+		tfsort.RegisterBlockSorter("widget", nil)
+		unsorted := "widget \"a\" {\n  b = 1\n  a = 2\n}\n"
+		unchanged, sortErr := tfsort.SortBytes([]byte(unsorted), tfsort.Options{})
+		if sortErr != nil {
+			t.Fatalf("SortBytes failed unexpectedly: %v", sortErr)
+		}
+		if diff := cmp.Diff(unsorted, string(unchanged)); diff != "" {
+			t.Errorf("Unexpected output (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestPlan(t *testing.T) {
+	src := "variable \"b\" {}\n\n# Comment\nvariable \"a\" {}\n\noutput \"c\" {}\n"
+	plan, err := tfsort.Plan([]byte(src), tfsort.Options{Filename: "main.tf"})
+	if err != nil {
+		t.Fatalf("Plan failed unexpectedly: %v", err)
+	}
+	want := &tfsort.SortPlan{
+		Path: "main.tf",
+		Moves: []tfsort.Move{
+			{
+				Address:  `variable "b"`,
+				Type:     "variable",
+				Labels:   []string{"b"},
+				OldIndex: 0,
+				NewIndex: 1,
+				OldLine:  1,
+				NewLine:  4,
+			},
+			{
+				Address:  `variable "a"`,
+				Type:     "variable",
+				Labels:   []string{"a"},
+				OldIndex: 1,
+				NewIndex: 0,
+				OldLine:  4,
+				NewLine:  2,
+			},
+		},
+		Changed: true,
+	}
+	if diff := cmp.Diff(want, plan); diff != "" {
+		t.Errorf("Unexpected plan (-want +got):\n%s", diff)
+	}
+	if got := plan.Moves[0].String(); got != `variable "b" moves from line 1 to line 4` {
+		t.Errorf("Unexpected move description: %s", got)
+	}
+
+	t.Run("Merged blocks", func(t *testing.T) {
+		terraform := "terraform {\n  required_version = \">= 1.0\"\n}\n\nterraform {\n  backend \"s3\" {}\n}\n"
+		merged, planErr := tfsort.Plan([]byte(terraform), tfsort.NewOptions(tfsort.WithMergeTerraformBlocks()))
+		if planErr != nil {
+			t.Fatalf("Plan failed unexpectedly: %v", planErr)
+		}
+		if len(merged.Moves) != 1 || merged.Moves[0].OldIndex != 1 || merged.Moves[0].NewIndex != -1 {
+			t.Errorf("Expected the second terraform block to be merged, got: %+v", merged.Moves)
+		}
+	})
+
+	t.Run("Blocks sharing their labels", func(t *testing.T) {
+		shared := "provider \"aws\" {\n  alias = \"west\"\n}\n\nprovider \"aws\" {}\n\n" +
+			"moved {\n  from = b\n  to   = c\n}\n\nmoved {\n  from = a\n  to   = c\n}\n"
+		sharedPlan, planErr := tfsort.Plan([]byte(shared), tfsort.Options{BlockTypes: []string{"provider", "moved"}})
+		if planErr != nil {
+			t.Fatalf("Plan failed unexpectedly: %v", planErr)
+		}
+		var moves []string
+		for _, move := range sharedPlan.Moves {
+			moves = append(moves, fmt.Sprintf("%s %d->%d", move.Address, move.OldIndex, move.NewIndex))
+		}
+		wantMoves := []string{`provider "aws" 0->1`, `provider "aws" 1->0`, "moved 2->3", "moved 3->2"}
+		if diff := cmp.Diff(wantMoves, moves); diff != "" {
+			t.Errorf("Unexpected moves (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Sorted source", func(t *testing.T) {
+		empty, planErr := tfsort.Plan([]byte("variable \"a\" {}\n"), tfsort.Options{})
+		if planErr != nil {
+			t.Fatalf("Plan failed unexpectedly: %v", planErr)
+		}
+		if empty.Changed || len(empty.Moves) > 0 {
+			t.Errorf("Expected an empty plan, got: %+v", empty)
+		}
+	})
+}
+
+func TestParseError(t *testing.T) {
+	_, err := tfsort.SortBytes([]byte("variable \"a\" {\n"), tfsort.Options{Filename: "main.tf"})
+	var parseErr *tfsort.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected a *ParseError, got: %v", err)
+	}
+	if len(parseErr.Diagnostics) != 1 {
+		t.Fatalf("Expected one diagnostic, got: %+v", parseErr.Diagnostics)
+	}
+	got := parseErr.Diagnostics[0]
+	if got.Filename != "main.tf" || got.Start != (tfsort.Position{Line: 1, Column: 14}) ||
+		got.Severity != tfsort.SeverityError || got.Code != "unclosed-configuration-block" {
+		t.Errorf("Unexpected diagnostic: %+v", got)
+	}
+}